@@ -103,14 +103,15 @@ func (a *Authenticate) updateProvider(cfg *config.Config) error {
 	// configure our identity provider
 	provider, err := identity.NewAuthenticator(
 		oauth.Options{
-			RedirectURL:     redirectURL,
-			ProviderName:    cfg.Options.Provider,
-			ProviderURL:     cfg.Options.ProviderURL,
-			ClientID:        cfg.Options.ClientID,
-			ClientSecret:    cfg.Options.ClientSecret,
-			Scopes:          cfg.Options.Scopes,
-			ServiceAccount:  cfg.Options.ServiceAccount,
-			AuthCodeOptions: cfg.Options.RequestParams,
+			RedirectURL:      redirectURL,
+			ProviderName:     cfg.Options.Provider,
+			ProviderURL:      cfg.Options.ProviderURL,
+			ClientID:         cfg.Options.ClientID,
+			ClientSecret:     cfg.Options.ClientSecret,
+			Scopes:           cfg.Options.Scopes,
+			ServiceAccount:   cfg.Options.ServiceAccount,
+			AuthCodeOptions:  cfg.Options.RequestParams,
+			AllowedAudiences: cfg.Options.IDPAllowedAudiences,
 		})
 	if err != nil {
 		return err
@@ -19,6 +19,7 @@ import (
 	"golang.org/x/oauth2"
 	"gopkg.in/square/go-jose.v2/jwt"
 
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/identity/manager"
 	"github.com/pomerium/pomerium/internal/identity/oidc"
@@ -44,6 +45,16 @@ func (a *Authenticate) Handler() http.Handler {
 func (a *Authenticate) Mount(r *mux.Router) {
 	r.StrictSlash(true)
 	r.Use(middleware.SetHeaders(httputil.HeadersContentSecurityPolicy))
+	// service account credentials are never sent implicitly by a browser, so
+	// exchanging them for a session doesn't need CSRF protection
+	r.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == serviceAccountSessionPath {
+				r = csrf.UnsafeSkipCheck(r)
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
 	r.Use(func(h http.Handler) http.Handler {
 		options := a.options.Load()
 		state := a.state.Load()
@@ -77,9 +88,7 @@ func (a *Authenticate) Mount(r *mux.Router) {
 		AllowedHeaders:   []string{"*"},
 	})
 	v.Use(c.Handler)
-	v.Use(func(h http.Handler) http.Handler {
-		return sessions.RetrieveSession(a.state.Load().sessionLoaders...)(h)
-	})
+	v.Use(a.retrieveSession)
 	v.Use(a.VerifySession)
 	v.Path("/").Handler(httputil.HandlerFunc(a.Dashboard))
 	v.Path("/sign_in").Handler(httputil.HandlerFunc(a.SignIn))
@@ -88,13 +97,24 @@ func (a *Authenticate) Mount(r *mux.Router) {
 
 	wk := r.PathPrefix("/.well-known/pomerium").Subrouter()
 	wk.Path("/jwks.json").Handler(httputil.HandlerFunc(a.jwks)).Methods(http.MethodGet)
+	wk.Path("/session_loaders").Handler(httputil.HandlerFunc(a.sessionLoaderHealth)).Methods(http.MethodGet)
 	wk.Path("/").Handler(httputil.HandlerFunc(a.wellKnown)).Methods(http.MethodGet)
 
 	// programmatic access api endpoint
 	api := r.PathPrefix("/api").Subrouter()
-	api.Use(func(h http.Handler) http.Handler {
-		return sessions.RetrieveSession(a.state.Load().sessionLoaders...)(h)
-	})
+	api.Use(a.retrieveSession)
+	api.Path(strings.TrimPrefix(serviceAccountSessionPath, "/api")).
+		Handler(httputil.HandlerFunc(a.ServiceAccountSession)).Methods(http.MethodPost)
+}
+
+// retrieveSession adds the session found by the session loaders to the
+// request's context.
+func (a *Authenticate) retrieveSession(h http.Handler) http.Handler {
+	loaders := a.state.Load().sessionLoaders
+	if a.options.Load().ConcurrentSessionLoaders {
+		return sessions.RetrieveSessionConcurrently(loaders...)(h)
+	}
+	return sessions.RetrieveSession(loaders...)(h)
 }
 
 // Well-Known Uniform Resource Identifiers (URIs)
@@ -122,16 +142,67 @@ func (a *Authenticate) wellKnown(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// jwks serves the public keys used to sign JWTs. The document is cached, and
+// clients may revalidate it with If-None-Match.
 func (a *Authenticate) jwks(w http.ResponseWriter, r *http.Request) error {
 	state := a.state.Load()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	jBytes, err := json.Marshal(state.jwk)
+	w.Header().Set("ETag", state.jwksETag)
+	if maxAge := a.options.Load().JWKSCacheMaxAge; maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), state.jwksETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(state.jwksJSON)
+	return nil
+}
+
+// etagMatches reports whether an If-None-Match header matches an etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionLoaderHealth reports the health of each session loader, so operators
+// can see when a loader's backend is degraded. Details of backend errors are
+// only logged.
+func (a *Authenticate) sessionLoaderHealth(w http.ResponseWriter, r *http.Request) error {
+	state := a.state.Load()
+
+	type loaderStatus struct {
+		Name    string `json:"name"`
+		Healthy bool   `json:"healthy"`
+	}
+	statuses := make([]loaderStatus, 0, len(state.sessionLoaders))
+	code := http.StatusOK
+	for _, loader := range state.sessionLoaders {
+		health := loader.Health(r.Context())
+		if health.Err != nil {
+			log.FromRequest(r).Warn().Err(health.Err).Str("loader", health.Name).Msg("authenticate: session loader unhealthy")
+			code = http.StatusServiceUnavailable
+		}
+		statuses = append(statuses, loaderStatus{Name: health.Name, Healthy: health.Err == nil})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	jBytes, err := json.Marshal(statuses)
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(code)
 	fmt.Fprintf(w, "%s", jBytes)
 	return nil
 }
@@ -188,6 +259,9 @@ func (a *Authenticate) SignIn(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return httputil.NewError(http.StatusBadRequest, err)
 	}
+	if !options.IsAllowedRedirect(redirectURL) {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("authenticate: redirect not allowed: %s", redirectURL))
+	}
 
 	jwtAudience := []string{state.redirectURL.Host, redirectURL.Host}
 
@@ -198,6 +272,9 @@ func (a *Authenticate) SignIn(w http.ResponseWriter, r *http.Request) error {
 		if err != nil {
 			return httputil.NewError(http.StatusBadRequest, err)
 		}
+		if !options.IsAllowedRedirect(callbackURL) {
+			return httputil.NewError(http.StatusBadRequest, fmt.Errorf("authenticate: callback not allowed: %s", callbackURL))
+		}
 		jwtAudience = append(jwtAudience, callbackURL.Host)
 	} else {
 		// otherwise, assume callback is the same host as redirect
@@ -281,12 +358,17 @@ func (a *Authenticate) SignOut(w http.ResponseWriter, r *http.Request) error {
 
 	// no matter what happens, we want to clear the session store
 	state.sessionStore.ClearSession(w, r)
+	options := a.options.Load()
 	redirectString := ""
-	if sru := a.options.Load().SignOutRedirectURL; sru != nil {
+	if sru := options.SignOutRedirectURL; sru != nil {
 		redirectString = sru.String()
 	}
 	if uri := r.FormValue(urlutil.QueryRedirectURI); uri != "" {
-		redirectString = uri
+		if u, err := urlutil.ParseAndValidateURL(uri); err == nil && options.IsAllowedRedirect(u) {
+			redirectString = u.String()
+		} else {
+			log.FromRequest(r).Warn().Str("redirect_uri", uri).Msg("authenticate: sign out redirect not allowed")
+		}
 	}
 
 	endSessionURL, err := a.provider.Load().LogOut()
@@ -402,13 +484,29 @@ func (a *Authenticate) getOAuthCallback(w http.ResponseWriter, r *http.Request)
 	//
 	// Exchange the supplied Authorization Code for a valid user session.
 	s := sessions.State{ID: uuid.New().String()}
-	accessToken, err := a.provider.Load().Authenticate(ctx, code, &s)
+	idp := &idpClaims{State: &s}
+	accessToken, err := a.provider.Load().Authenticate(ctx, code, idp)
 	if err != nil {
 		return nil, fmt.Errorf("error redeeming authenticate code: %w", err)
 	}
 
-	err = a.saveSessionToDataBroker(r.Context(), &s, accessToken)
+	s.CustomClaims, err = state.claimTemplates.Execute(idp.claims)
+	if err != nil {
+		log.FromRequest(r).Warn().Err(err).Msg("authenticate: failed to evaluate some session claims")
+	}
+	s.CustomClaims, err = a.options.Load().GetSessionClaimsLimit().Apply(s.CustomClaims)
 	if err != nil {
+		return nil, httputil.NewError(http.StatusBadRequest, fmt.Errorf("identity provider returned too many claims: %w", err))
+	}
+
+	if err := limitRefreshToken(a.options.Load(), accessToken); err != nil {
+		return nil, httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	err = a.saveSessionToDataBroker(r.Context(), &s, accessToken)
+	if errors.Is(err, sessions.ErrClaimsLimitExceeded) {
+		return nil, httputil.NewError(http.StatusBadRequest, err)
+	} else if err != nil {
 		return nil, httputil.NewError(http.StatusInternalServerError, err)
 	}
 
@@ -448,6 +546,9 @@ func (a *Authenticate) getOAuthCallback(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return nil, httputil.NewError(http.StatusBadRequest, err)
 	}
+	if !a.options.Load().IsAllowedRedirect(redirectURL) {
+		return nil, httputil.NewError(http.StatusBadRequest, fmt.Errorf("authenticate: redirect not allowed: %s", redirectURL))
+	}
 
 	// ...  and the user state to local storage.
 	if err := state.sessionStore.SaveSession(w, r, &newState); err != nil {
@@ -551,26 +652,53 @@ func (a *Authenticate) Dashboard(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// limitRefreshToken enforces the maximum size of refresh tokens. Tokens over
+// the limit are either rejected, or replaced with a marker which requires the
+// user to sign in again instead of the session being refreshed.
+func limitRefreshToken(options *config.Options, token *oauth2.Token) error {
+	size := len(token.RefreshToken)
+	discard := options.RefreshTokenLimitAction == config.RefreshTokenLimitActionDiscard
+	if err := manager.LimitRefreshToken(token, options.MaxRefreshTokenBytes, discard); err != nil {
+		return err
+	}
+	if discard && token.RefreshToken == manager.DiscardedRefreshToken {
+		log.Info().Int("size", size).Msg("authenticate: discarding oversized refresh token")
+	}
+	return nil
+}
+
 func (a *Authenticate) saveSessionToDataBroker(ctx context.Context, sessionState *sessions.State, accessToken *oauth2.Token) error {
 	state := a.state.Load()
 	options := a.options.Load()
 
-	sessionExpiry, _ := ptypes.TimestampProto(time.Now().Add(options.CookieExpire))
-	sessionState.Expiry = jwt.NewNumericDate(sessionExpiry.AsTime())
+	cookieExpiry := time.Now().Add(options.CookieExpire)
+	idTokenExpiry := cookieExpiry
+	if sessionState.Expiry != nil {
+		// set from the identity provider's claims
+		idTokenExpiry = sessionState.Expiry.Time()
+	}
+	sessionState.Expiry = jwt.NewNumericDate(options.SessionExpirySource.Expiry(cookieExpiry, idTokenExpiry))
+	idTokenExpiresAt, _ := ptypes.TimestampProto(idTokenExpiry)
 	idTokenIssuedAt, _ := ptypes.TimestampProto(sessionState.IssuedAt.Time())
 
 	s := &session.Session{
-		Id:        sessionState.ID,
-		UserId:    sessionState.UserID(a.provider.Load().Name()),
-		ExpiresAt: sessionExpiry,
+		Id:     sessionState.ID,
+		UserId: sessionState.UserID(a.provider.Load().Name()),
 		IdToken: &session.IDToken{
 			Issuer:    sessionState.Issuer,
 			Subject:   sessionState.Subject,
-			ExpiresAt: sessionExpiry,
+			ExpiresAt: idTokenExpiresAt,
 			IssuedAt:  idTokenIssuedAt,
 		},
 		OauthToken: manager.ToOAuthToken(accessToken),
 	}
+	// the id token's expiry is kept up to date as the session is refreshed,
+	// and when just the id token's expiry is honored, so is the session's
+	if options.SessionExpirySource == config.SessionExpirySourceIDToken {
+		s.ExpiresAt = idTokenExpiresAt
+	} else {
+		s.ExpiresAt, _ = ptypes.TimestampProto(cookieExpiry)
+	}
 
 	// if no user exists yet, create a new one
 	currentUser, _ := user.Get(ctx, state.dataBrokerClient, s.GetUserId())
@@ -584,6 +712,10 @@ func (a *Authenticate) saveSessionToDataBroker(ctx context.Context, sessionState
 		if err != nil {
 			return fmt.Errorf("authenticate: error retrieving user info: %w", err)
 		}
+		mu.User.Claims, err = options.GetSessionClaimsLimit().ApplyAny(mu.User.Claims)
+		if err != nil {
+			return fmt.Errorf("authenticate: identity provider returned too many user claims: %w", err)
+		}
 		_, err = user.Set(ctx, state.dataBrokerClient, mu.User)
 		if err != nil {
 			return fmt.Errorf("authenticate: error saving user: %w", err)
@@ -598,3 +730,27 @@ func (a *Authenticate) saveSessionToDataBroker(ctx context.Context, sessionState
 
 	return nil
 }
+
+// idpClaims hydrates a session's state from the identity provider's claims,
+// while also keeping a copy of every raw claim for deriving custom claims.
+type idpClaims struct {
+	*sessions.State
+	claims map[string]interface{}
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Identity providers
+// may unmarshal claims several times (e.g. from the id token and then from
+// the user info endpoint), so claims are merged rather than replaced.
+func (c *idpClaims) UnmarshalJSON(data []byte) error {
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	if c.claims == nil {
+		c.claims = make(map[string]interface{}, len(claims))
+	}
+	for k, v := range claims {
+		c.claims[k] = v
+	}
+	return c.State.UnmarshalJSON(data)
+}
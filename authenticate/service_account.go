@@ -0,0 +1,179 @@
+package authenticate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/uuid"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/sessions/header"
+	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+// serviceAccountSessionPath is where service accounts exchange a credential
+// for a session.
+const serviceAccountSessionPath = "/api/v1/service_account/session"
+
+// serviceAccountCredentialLeeway is the clock skew tolerated when validating
+// the time claims of service account credentials.
+const serviceAccountCredentialLeeway = time.Minute
+
+// serviceAccountCredentialType is the typ claim credentials must carry. The
+// sessions minted from them never do, so a session can't be exchanged for a
+// new one.
+const serviceAccountCredentialType = "pomerium_service_account"
+
+var errInvalidServiceAccountCredential = errors.New("invalid service account credential")
+
+// serviceAccountCredentialClaims are the claims of a service account
+// credential.
+type serviceAccountCredentialClaims struct {
+	jwt.Claims
+	Type string `json:"typ"`
+}
+
+// serviceAccount is a configured service account along with the key its
+// credentials are verified with.
+type serviceAccount struct {
+	config.ServiceAccount
+	// key is either the shared secret or the service account's ECDSA public key.
+	key interface{}
+}
+
+func newServiceAccounts(options *config.Options) (map[string]*serviceAccount, error) {
+	accounts := make(map[string]*serviceAccount, len(options.ServiceAccounts))
+	for _, sa := range options.ServiceAccounts {
+		account := &serviceAccount{ServiceAccount: sa, key: []byte(options.SharedKey)}
+		publicKey, err := sa.GetPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("authenticate: bad public key for service account %s: %w", sa.ID, err)
+		}
+		if publicKey != nil {
+			account.key = publicKey
+		}
+		accounts[sa.ID] = account
+	}
+	return accounts, nil
+}
+
+// verifyServiceAccountCredential checks that the credential is a current JWT
+// of the service account credential type, addressed to the authenticate
+// service and signed with the key of the service account named by its
+// subject. Tokens issued by the authenticate service itself, such as
+// sessions, are rejected. The credential's verified claims are returned along
+// with the service account.
+func (a *Authenticate) verifyServiceAccountCredential(credential string) (*serviceAccount, *serviceAccountCredentialClaims, error) {
+	state := a.state.Load()
+
+	tok, err := jwt.ParseSigned(credential)
+	if err != nil {
+		return nil, nil, errInvalidServiceAccountCredential
+	}
+	var unverified jwt.Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return nil, nil, errInvalidServiceAccountCredential
+	}
+	sa, ok := state.serviceAccounts[unverified.Subject]
+	if !ok {
+		return nil, nil, errInvalidServiceAccountCredential
+	}
+
+	var claims serviceAccountCredentialClaims
+	if err := tok.Claims(sa.key, &claims); err != nil {
+		return nil, nil, errInvalidServiceAccountCredential
+	}
+	if claims.Type != serviceAccountCredentialType {
+		return nil, nil, fmt.Errorf("%w: typ must be %s", errInvalidServiceAccountCredential, serviceAccountCredentialType)
+	}
+	if claims.Issuer == state.redirectURL.Hostname() {
+		return nil, nil, fmt.Errorf("%w: issued by authenticate", errInvalidServiceAccountCredential)
+	}
+	if claims.Expiry == nil {
+		return nil, nil, fmt.Errorf("%w: missing expiry", errInvalidServiceAccountCredential)
+	}
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Subject:  sa.ID,
+		Audience: jwt.Audience{state.redirectURL.Hostname()},
+		Time:     time.Now(),
+	}, serviceAccountCredentialLeeway)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errInvalidServiceAccountCredential, err)
+	}
+	return sa, &claims, nil
+}
+
+// ServiceAccountSession exchanges a service account credential, passed as a
+// bearer token, for a programmatic pomerium session. The session only carries
+// the service account's identity and expires after the service account's
+// session TTL. Its audience is the credential's, so the hosts the credential
+// names besides the authenticate service's accept the session when session
+// audience binding is enabled.
+func (a *Authenticate) ServiceAccountSession(w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(r.Context(), "authenticate.ServiceAccountSession")
+	defer span.End()
+
+	state := a.state.Load()
+
+	credential := header.TokenFromHeader(r, "Authorization", "Bearer")
+	if credential == "" {
+		return httputil.NewError(http.StatusUnauthorized, errInvalidServiceAccountCredential)
+	}
+	sa, claims, err := a.verifyServiceAccountCredential(credential)
+	if err != nil {
+		log.FromRequest(r).Info().Err(err).Msg("authenticate: service account credential rejected")
+		return httputil.NewError(http.StatusUnauthorized, errInvalidServiceAccountCredential)
+	}
+
+	now := time.Now()
+	s := sessions.State{
+		Issuer:       state.redirectURL.Hostname(),
+		Subject:      sa.ID,
+		Audience:     claims.Audience,
+		Expiry:       jwt.NewNumericDate(now.Add(sa.GetSessionTTL())),
+		NotBefore:    jwt.NewNumericDate(now),
+		IssuedAt:     jwt.NewNumericDate(now),
+		ID:           uuid.New().String(),
+		Programmatic: true,
+	}
+	if err := a.saveServiceAccountToDataBroker(ctx, &s, sa); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	signedJWT, err := state.sharedEncoder.Marshal(&s)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(signedJWT)
+	return nil
+}
+
+func (a *Authenticate) saveServiceAccountToDataBroker(ctx context.Context, s *sessions.State, sa *serviceAccount) error {
+	state := a.state.Load()
+
+	expiresAt, _ := ptypes.TimestampProto(s.Expiry.Time())
+	issuedAt, _ := ptypes.TimestampProto(s.IssuedAt.Time())
+	res, err := user.SetServiceAccount(ctx, state.dataBrokerClient, &user.ServiceAccount{
+		Id:        s.ID,
+		UserId:    sa.GetUserID(),
+		ExpiresAt: expiresAt,
+		IssuedAt:  issuedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("authenticate: error saving service account session: %w", err)
+	}
+	s.Version = sessions.Version(res.GetVersion())
+	return nil
+}
@@ -0,0 +1,156 @@
+package authenticate
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+func TestAuthenticate_ServiceAccountSession(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	signingKey, err := cryptutil.NewSigningKey()
+	require.NoError(t, err)
+	publicKey, err := cryptutil.EncodePublicKey(&signingKey.PublicKey)
+	require.NoError(t, err)
+	otherKey, err := cryptutil.NewSigningKey()
+	require.NoError(t, err)
+
+	options := &config.Options{
+		SharedKey: sharedKey,
+		ServiceAccounts: []config.ServiceAccount{
+			{ID: "ci", SessionTTL: 10 * time.Minute},
+			{ID: "deploy", UserID: "deploy-bot", PublicKey: base64.StdEncoding.EncodeToString(publicKey)},
+		},
+	}
+	serviceAccounts, err := newServiceAccounts(options)
+	require.NoError(t, err)
+	sharedEncoder, err := jws.NewHS256Signer([]byte(sharedKey), "auth.example.com")
+	require.NoError(t, err)
+
+	var saved []*user.ServiceAccount
+	auth := testAuthenticate()
+	auth.options.Store(options)
+	state := auth.state.Load()
+	state.sharedEncoder = sharedEncoder
+	state.serviceAccounts = serviceAccounts
+	state.dataBrokerClient = mockDataBrokerServiceClient{
+		set: func(ctx context.Context, in *databroker.SetRequest, opts ...grpc.CallOption) (*databroker.SetResponse, error) {
+			var sa user.ServiceAccount
+			if err := in.GetData().UnmarshalTo(&sa); err != nil {
+				return nil, err
+			}
+			saved = append(saved, &sa)
+			return &databroker.SetResponse{Record: &databroker.Record{Version: "1"}}, nil
+		},
+	}
+
+	soon := time.Now().Add(time.Minute)
+	sign := func(alg jose.SignatureAlgorithm, key interface{}, claims interface{}) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, nil)
+		require.NoError(t, err)
+		raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return raw
+	}
+	claims := func(subject string, audience string, expiry time.Time) serviceAccountCredentialClaims {
+		return serviceAccountCredentialClaims{
+			Claims: jwt.Claims{
+				Subject:  subject,
+				Audience: jwt.Audience{audience},
+				Expiry:   jwt.NewNumericDate(expiry),
+			},
+			Type: serviceAccountCredentialType,
+		}
+	}
+	untyped := claims("ci", "auth.example.com", soon)
+	untyped.Type = ""
+	issuedByAuthenticate := claims("ci", "auth.example.com", soon)
+	issuedByAuthenticate.Issuer = "auth.example.com"
+	withTargets := claims("ci", "auth.example.com", soon)
+	withTargets.Audience = append(withTargets.Audience, "app.example.com")
+
+	tests := []struct {
+		name       string
+		credential string
+		wantStatus int
+		wantUserID string
+		wantTTL    time.Duration
+	}{
+		{"shared key", sign(jose.HS256, []byte(sharedKey), claims("ci", "auth.example.com", soon)), http.StatusOK, "ci", 10 * time.Minute},
+		{"registered key", sign(jose.ES256, signingKey, claims("deploy", "auth.example.com", soon)), http.StatusOK, "deploy-bot", config.DefaultServiceAccountSessionTTL},
+		{"target hosts", sign(jose.HS256, []byte(sharedKey), withTargets), http.StatusOK, "ci", 10 * time.Minute},
+		{"missing credential", "", http.StatusUnauthorized, "", 0},
+		{"malformed credential", "not-a-jwt", http.StatusUnauthorized, "", 0},
+		{"wrong shared key", sign(jose.HS256, cryptutil.NewKey(), claims("ci", "auth.example.com", soon)), http.StatusUnauthorized, "", 0},
+		{"wrong registered key", sign(jose.ES256, otherKey, claims("deploy", "auth.example.com", soon)), http.StatusUnauthorized, "", 0},
+		{"shared key for registered key account", sign(jose.HS256, []byte(sharedKey), claims("deploy", "auth.example.com", soon)), http.StatusUnauthorized, "", 0},
+		{"unknown service account", sign(jose.HS256, []byte(sharedKey), claims("unknown", "auth.example.com", soon)), http.StatusUnauthorized, "", 0},
+		{"wrong audience", sign(jose.HS256, []byte(sharedKey), claims("ci", "proxy.example.com", soon)), http.StatusUnauthorized, "", 0},
+		{"expired", sign(jose.HS256, []byte(sharedKey), claims("ci", "auth.example.com", time.Now().Add(-time.Hour))), http.StatusUnauthorized, "", 0},
+		{"no expiry", sign(jose.HS256, []byte(sharedKey), serviceAccountCredentialClaims{Claims: jwt.Claims{Subject: "ci", Audience: jwt.Audience{"auth.example.com"}}, Type: serviceAccountCredentialType}), http.StatusUnauthorized, "", 0},
+		{"missing typ", sign(jose.HS256, []byte(sharedKey), untyped), http.StatusUnauthorized, "", 0},
+		{"issued by authenticate", sign(jose.HS256, []byte(sharedKey), issuedByAuthenticate), http.StatusUnauthorized, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved = nil
+			r := httptest.NewRequest(http.MethodPost, serviceAccountSessionPath, nil)
+			if tt.credential != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.credential)
+			}
+			w := httptest.NewRecorder()
+			auth.Handler().ServeHTTP(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				assert.Empty(t, saved)
+				return
+			}
+
+			var s sessions.State
+			require.NoError(t, sharedEncoder.Unmarshal(w.Body.Bytes(), &s))
+
+			assert.NotEmpty(t, s.ID)
+			assert.True(t, s.Programmatic)
+			assert.Empty(t, s.CustomClaims)
+			assert.False(t, s.Impersonating())
+			assert.WithinDuration(t, time.Now().Add(tt.wantTTL), s.Expiry.Time(), 5*time.Second)
+
+			// the session is bound to the hosts the credential is addressed to
+			tok, err := jwt.ParseSigned(tt.credential)
+			require.NoError(t, err)
+			var credentialClaims jwt.Claims
+			require.NoError(t, tok.UnsafeClaimsWithoutVerification(&credentialClaims))
+			assert.Equal(t, credentialClaims.Audience, s.Audience)
+
+			require.Len(t, saved, 1)
+			assert.Equal(t, s.ID, saved[0].GetId())
+			assert.Equal(t, tt.wantUserID, saved[0].GetUserId())
+
+			// the minted session can't be exchanged for another one
+			saved = nil
+			r = httptest.NewRequest(http.MethodPost, serviceAccountSessionPath, nil)
+			r.Header.Set("Authorization", "Bearer "+w.Body.String())
+			reexchange := httptest.NewRecorder()
+			auth.Handler().ServeHTTP(reexchange, r)
+			assert.Equal(t, http.StatusUnauthorized, reexchange.Code)
+			assert.Empty(t, saved)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package authenticate
 import (
 	"crypto/cipher"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"sync/atomic"
@@ -42,17 +44,30 @@ type authenticateState struct {
 	// sessionLoaders are a collection of session loaders to attempt to pull
 	// a user's session state from
 	sessionLoaders []sessions.SessionLoader
+	// claimTemplates derive custom claims to add to newly created sessions
+	claimTemplates sessions.ClaimTemplates
+	// serviceAccounts are the service accounts, by id, that may exchange a
+	// credential for a session
+	serviceAccounts map[string]*serviceAccount
 
 	jwk *jose.JSONWebKeySet
+	// jwksJSON and jwksETag are the encoded jwk, and its etag, served by the
+	// jwks endpoint. The keys only change when the state is replaced, so the
+	// document is encoded once rather than on every request.
+	jwksJSON []byte
+	jwksETag string
 
 	dataBrokerClient databroker.DataBrokerServiceClient
 }
 
 func newAuthenticateState() *authenticateState {
-	return &authenticateState{
-		administrators: map[string]struct{}{},
-		jwk:            new(jose.JSONWebKeySet),
+	state := &authenticateState{
+		administrators:  map[string]struct{}{},
+		serviceAccounts: map[string]*serviceAccount{},
+		jwk:             new(jose.JSONWebKeySet),
 	}
+	state.jwksJSON, state.jwksETag, _ = encodeJWKS(state.jwk)
+	return state
 }
 
 func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, error) {
@@ -92,6 +107,8 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 			Secure:   cfg.Options.CookieSecure,
 			HTTPOnly: cfg.Options.CookieHTTPOnly,
 			Expire:   cfg.Options.CookieExpire,
+
+			SizeWarningThreshold: cfg.Options.SessionSizeWarningThreshold,
 		}
 	}, state.sharedEncoder)
 	if err != nil {
@@ -101,6 +118,16 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 	state.sessionStore = cookieStore
 	state.sessionLoaders = []sessions.SessionLoader{qpStore, headerStore, cookieStore}
 
+	state.claimTemplates, err = sessions.NewClaimTemplates(cfg.Options.SessionClaims)
+	if err != nil {
+		return nil, fmt.Errorf("authenticate: invalid session claims: %w", err)
+	}
+
+	state.serviceAccounts, err = newServiceAccounts(cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
 	state.jwk = new(jose.JSONWebKeySet)
 	if cfg.Options.SigningKey != "" {
 		decodedCert, err := base64.StdEncoding.DecodeString(cfg.Options.SigningKey)
@@ -113,6 +140,10 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 		}
 		state.jwk.Keys = append(state.jwk.Keys, *jwk)
 	}
+	state.jwksJSON, state.jwksETag, err = encodeJWKS(state.jwk)
+	if err != nil {
+		return nil, fmt.Errorf("authenticate: failed to encode jwks: %w", err)
+	}
 
 	dataBrokerConn, err := grpc.GetGRPCClientConn("databroker", &grpc.Options{
 		Addr:                    cfg.Options.DataBrokerURL,
@@ -121,6 +152,7 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 		CAFile:                  cfg.Options.CAFile,
 		RequestTimeout:          cfg.Options.GRPCClientTimeout,
 		ClientDNSRoundRobin:     cfg.Options.GRPCClientDNSRoundRobin,
+		ClientDNSStaleTTL:       cfg.Options.GRPCClientDNSStaleTTL,
 		WithInsecure:            cfg.Options.GRPCInsecure,
 		ServiceName:             cfg.Options.Services,
 	})
@@ -133,6 +165,16 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 	return state, nil
 }
 
+// encodeJWKS encodes a JSON web key set, and returns it along with an etag
+// which changes whenever the keys do.
+func encodeJWKS(jwk *jose.JSONWebKeySet) (body []byte, etag string, err error) {
+	body, err = json.Marshal(jwk)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, `"` + hex.EncodeToString(cryptutil.Hash("jwks", body)) + `"`, nil
+}
+
 type atomicAuthenticateState struct {
 	atomic.Value
 }
@@ -0,0 +1,59 @@
+package authorize
+
+import (
+	"context"
+	"time"
+)
+
+// An AuditEvent records an access decision made by the authorize service.
+type AuditEvent struct {
+	Time     time.Time
+	Subject  string
+	Route    string
+	Allowed  bool
+	Status   int32
+	ClientIP string
+}
+
+// An AuditSink receives an AuditEvent for every access decision the
+// authorize service makes, whether it's requested by envoy or by the proxy's
+// forward auth endpoints. Audit is called synchronously, so sinks which write
+// to slow destinations should buffer.
+type AuditSink interface {
+	Audit(ctx context.Context, evt AuditEvent)
+}
+
+// The AuditSinkFunc type is an adapter to allow the use of ordinary
+// functions as audit sinks.
+type AuditSinkFunc func(ctx context.Context, evt AuditEvent)
+
+// Audit calls f(ctx, evt).
+func (f AuditSinkFunc) Audit(ctx context.Context, evt AuditEvent) {
+	f(ctx, evt)
+}
+
+type noopAuditSink struct{}
+
+func (noopAuditSink) Audit(context.Context, AuditEvent) {}
+
+// auditSinkHolder lets AuditSinks of different types be stored in the same
+// atomic.Value.
+type auditSinkHolder struct {
+	sink AuditSink
+}
+
+// SetAuditSink sets the sink which receives an AuditEvent for every access
+// decision. It's independent of the configuration, so it's kept across
+// configuration changes. A nil sink disables auditing.
+func (a *Authorize) SetAuditSink(sink AuditSink) {
+	if sink == nil {
+		sink = noopAuditSink{}
+	}
+	a.auditSink.Store(auditSinkHolder{sink: sink})
+}
+
+func (a *Authorize) audit(ctx context.Context, evt AuditEvent) {
+	if h, ok := a.auditSink.Load().(auditSinkHolder); ok {
+		h.sink.Audit(ctx, evt)
+	}
+}
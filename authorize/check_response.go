@@ -2,9 +2,12 @@ package authorize
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
@@ -14,19 +17,35 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 	"github.com/pomerium/pomerium/internal/urlutil"
+	authorizegrpc "github.com/pomerium/pomerium/pkg/grpc/authorize"
 )
 
 func (a *Authorize) okResponse(reply *evaluator.Result) *envoy_service_auth_v2.CheckResponse {
-	requestHeaders, err := a.getEnvoyRequestHeaders(reply.SignedJWT)
+	requestHeaders, err := a.getEnvoyRequestHeaders(reply.MatchingPolicy, reply.SignedJWT)
 	if err != nil {
 		log.Warn().Err(err).Msg("authorize: error generating new request headers")
 	}
 
+	forwardedJWT := reply.SignedJWT
+	if opts := a.currentOptions.Load(); isDownstreamTokenEnabled(opts) {
+		forwardedJWT, err = getDownstreamToken(a.state.Load().evaluator.SignedJWT, opts, reply.JWTPayload)
+		if err != nil {
+			log.Error().Err(err).Msg("authorize: error minting downstream token")
+			return a.plainTextDeniedResponse(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+		}
+	}
+
 	requestHeaders = append(requestHeaders,
-		mkHeader(httputil.HeaderPomeriumJWTAssertion, reply.SignedJWT, false))
+		mkHeader(httputil.HeaderPomeriumJWTAssertion, forwardedJWT, false))
+
+	if reply.MatchingPolicy != nil && reply.MatchingPolicy.AuthorizationHeader == config.AuthorizationHeaderReplace {
+		requestHeaders = append(requestHeaders, mkHeader("Authorization", "Bearer "+forwardedJWT, false))
+	}
 
 	requestHeaders = append(requestHeaders, getKubernetesHeaders(reply)...)
 
@@ -51,8 +70,12 @@ func (a *Authorize) deniedResponse(
 	code int32, reason string, headers map[string]string,
 ) *envoy_service_auth_v2.CheckResponse {
 
-	returnHTMLError := true
 	inHeaders := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	if code >= 400 && a.currentOptions.Load().JSONErrors {
+		return a.jsonDeniedResponse(code, reason, inHeaders[requestid.HeaderName], headers)
+	}
+
+	returnHTMLError := true
 	if inHeaders != nil {
 		returnHTMLError = strings.Contains(inHeaders["accept"], "text/html")
 	}
@@ -133,6 +156,42 @@ func (a *Authorize) plainTextDeniedResponse(code int32, reason string, headers m
 	}
 }
 
+// jsonDeniedResponse returns a denied response with a JSON body, for
+// programmatic clients. The request id envoy assigned to the request is
+// included in the body, and returned as a header, so errors can be
+// correlated with logs.
+func (a *Authorize) jsonDeniedResponse(code int32, reason, requestID string, headers map[string]string) *envoy_service_auth_v2.CheckResponse {
+	jsonErr := httputil.NewJSONError(int(code), reason, requestID)
+	body, err := json.Marshal(jsonErr)
+	if err != nil {
+		body = []byte(jsonErr.Message)
+		log.Error().Err(err).Msg("error encoding json error")
+	}
+
+	envoyHeaders := []*envoy_api_v2_core.HeaderValueOption{
+		mkHeader("Content-Type", "application/json", false),
+	}
+	if requestID != "" {
+		envoyHeaders = append(envoyHeaders, mkHeader(requestid.HeaderName, requestID, false))
+	}
+	for k, v := range headers {
+		envoyHeaders = append(envoyHeaders, mkHeader(k, v, false))
+	}
+
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Access Denied"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status: &envoy_type.HttpStatus{
+					Code: envoy_type.StatusCode(code),
+				},
+				Headers: envoyHeaders,
+				Body:    string(body),
+			},
+		},
+	}
+}
+
 func (a *Authorize) redirectResponse(in *envoy_service_auth_v2.CheckRequest) *envoy_service_auth_v2.CheckResponse {
 	opts := a.currentOptions.Load()
 
@@ -152,6 +211,23 @@ func (a *Authorize) redirectResponse(in *envoy_service_auth_v2.CheckRequest) *en
 	})
 }
 
+// addServerTiming adds the time taken to authorize a request, and to sync its
+// session from the databroker, to a check response as a Server-Timing header. Denied responses
+// are returned to the client as is, while for allowed requests the timings are
+// passed to envoy which adds the upstream's latency and sets the header on the
+// upstream's response.
+func addServerTiming(res *envoy_service_auth_v2.CheckResponse, authorize, sync time.Duration) {
+	timing := fmt.Sprintf("authorize;dur=%.2f, sync;dur=%.2f",
+		float64(authorize)/float64(time.Millisecond), float64(sync)/float64(time.Millisecond))
+
+	switch r := res.GetHttpResponse().(type) {
+	case *envoy_service_auth_v2.CheckResponse_OkResponse:
+		r.OkResponse.Headers = append(r.OkResponse.Headers, mkHeader(httputil.HeaderPomeriumServerTiming, timing, false))
+	case *envoy_service_auth_v2.CheckResponse_DeniedResponse:
+		r.DeniedResponse.Headers = append(r.DeniedResponse.Headers, mkHeader("Server-Timing", timing, false))
+	}
+}
+
 func getKubernetesHeaders(reply *evaluator.Result) []*envoy_api_v2_core.HeaderValueOption {
 	var requestHeaders []*envoy_api_v2_core.HeaderValueOption
 	if reply.MatchingPolicy != nil && reply.MatchingPolicy.KubernetesServiceAccountToken != "" {
@@ -179,3 +255,27 @@ func mkHeader(k, v string, shouldAppend bool) *envoy_api_v2_core.HeaderValueOpti
 		},
 	}
 }
+
+// getDenialDetailHeaders returns the headers surfacing the configured details
+// of a denial to the user, or nil if none are configured.
+func getDenialDetailHeaders(options *config.Options, details authorizegrpc.DenialDetails) map[string]string {
+	var hdrs map[string]string
+	details = details.Sanitize()
+	for _, detail := range options.ProxyDenialDetails {
+		var k, v string
+		switch detail {
+		case config.DenialDetailReason:
+			k, v = httputil.HeaderPomeriumDenialReason, details.Reason
+		case config.DenialDetailRoute:
+			k, v = httputil.HeaderPomeriumDenialRoute, details.Route
+		}
+		if v == "" {
+			continue
+		}
+		if hdrs == nil {
+			hdrs = make(map[string]string)
+		}
+		hdrs[k] = v
+	}
+	return hdrs
+}
@@ -1,6 +1,7 @@
 package authorize
 
 import (
+	"encoding/json"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
@@ -20,6 +21,8 @@ import (
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/frontend"
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
+	authorizegrpc "github.com/pomerium/pomerium/pkg/grpc/authorize"
 	"github.com/pomerium/pomerium/pkg/grpc/session"
 	"github.com/pomerium/pomerium/pkg/grpc/user"
 )
@@ -100,6 +103,45 @@ func TestAuthorize_okResponse(t *testing.T) {
 				},
 			},
 		},
+		{
+			"ok reply with forwarded authorization header",
+			&evaluator.Result{
+				Status:         0,
+				Message:        "ok",
+				SignedJWT:      "valid-signed-jwt",
+				MatchingPolicy: &config.Policy{AuthorizationHeader: config.AuthorizationHeaderForward},
+			},
+			&envoy_service_auth_v2.CheckResponse{
+				Status: &status.Status{Code: 0, Message: "ok"},
+				HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{
+					OkResponse: &envoy_service_auth_v2.OkHttpResponse{
+						Headers: []*envoy_api_v2_core.HeaderValueOption{
+							mkHeader("x-pomerium-jwt-assertion", "valid-signed-jwt", false),
+						},
+					},
+				},
+			},
+		},
+		{
+			"ok reply with replaced authorization header",
+			&evaluator.Result{
+				Status:         0,
+				Message:        "ok",
+				SignedJWT:      "valid-signed-jwt",
+				MatchingPolicy: &config.Policy{AuthorizationHeader: config.AuthorizationHeaderReplace},
+			},
+			&envoy_service_auth_v2.CheckResponse{
+				Status: &status.Status{Code: 0, Message: "ok"},
+				HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{
+					OkResponse: &envoy_service_auth_v2.OkHttpResponse{
+						Headers: []*envoy_api_v2_core.HeaderValueOption{
+							mkHeader("x-pomerium-jwt-assertion", "valid-signed-jwt", false),
+							mkHeader("Authorization", "Bearer valid-signed-jwt", false),
+						},
+					},
+				},
+			},
+		},
 		{
 			"ok reply with k8s svc",
 			&evaluator.Result{
@@ -287,3 +329,62 @@ func TestAuthorize_deniedResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorize_deniedResponse_json(t *testing.T) {
+	a := &Authorize{currentOptions: config.NewAtomicOptions(), state: newAtomicAuthorizeState(new(authorizeState))}
+	a.currentOptions.Store(&config.Options{JSONErrors: true})
+	a.templates = template.Must(frontend.NewTemplates())
+
+	in := &envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{
+			Request: &envoy_service_auth_v2.AttributeContext_Request{
+				Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+					Headers: map[string]string{
+						"accept":       "application/json",
+						"x-request-id": "REQUEST_ID",
+					},
+				},
+			},
+		},
+	}
+	got := a.deniedResponse(in, http.StatusForbidden, "", nil)
+	require.NotNil(t, got.GetDeniedResponse())
+	assert.Equal(t, envoy_type.StatusCode_Forbidden, got.GetDeniedResponse().GetStatus().GetCode())
+
+	hdrs := map[string]string{}
+	for _, h := range got.GetDeniedResponse().GetHeaders() {
+		hdrs[h.GetHeader().GetKey()] = h.GetHeader().GetValue()
+	}
+	assert.Equal(t, "application/json", hdrs["Content-Type"])
+
+	var body struct {
+		Status    int    `json:"status"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+		Timestamp string `json:"timestamp"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(got.GetDeniedResponse().GetBody()), &body))
+	assert.Equal(t, http.StatusForbidden, body.Status)
+	assert.Equal(t, "Forbidden", body.Message)
+	assert.Equal(t, "REQUEST_ID", body.RequestID)
+	assert.Equal(t, hdrs[requestid.HeaderName], body.RequestID)
+	_, err := time.Parse(time.RFC3339, body.Timestamp)
+	assert.NoError(t, err)
+
+	t.Run("redirects are unchanged", func(t *testing.T) {
+		got := a.deniedResponse(in, http.StatusFound, "Login", map[string]string{"Location": "https://authenticate.example.com"})
+		assert.Equal(t, "Login", got.GetDeniedResponse().GetBody())
+	})
+}
+
+func TestGetDenialDetailHeaders(t *testing.T) {
+	details := authorizegrpc.DenialDetails{Reason: "not in\r\ngroup", Route: "https://from.example.com"}
+
+	assert.Nil(t, getDenialDetailHeaders(&config.Options{}, details), "no details are surfaced by default")
+	assert.Equal(t, map[string]string{
+		"x-pomerium-denial-reason": "not ingroup",
+		"x-pomerium-denial-route":  "https://from.example.com",
+	}, getDenialDetailHeaders(&config.Options{
+		ProxyDenialDetails: []config.DenialDetail{config.DenialDetailReason, config.DenialDetailRoute},
+	}, details))
+}
@@ -0,0 +1,64 @@
+package authorize
+
+import (
+	"time"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+var downstreamTokenNow = time.Now
+
+// downstreamTokenRegisteredClaims are the claims identifying the token's
+// issuer, audience and subject, which are always kept in downstream tokens.
+var downstreamTokenRegisteredClaims = []string{"iss", "aud", "sub"}
+
+// downstreamTokenOmittedClaims are never forwarded in downstream tokens. The
+// jti is the session id, which upstreams have no use for.
+var downstreamTokenOmittedClaims = []string{"jti"}
+
+// isDownstreamTokenEnabled returns true if a separate, minimized token should
+// be forwarded to upstreams instead of the full JWT.
+func isDownstreamTokenEnabled(options *config.Options) bool {
+	return len(options.DownstreamTokenClaims) > 0 || options.DownstreamTokenExpiry > 0
+}
+
+// getDownstreamToken mints the token forwarded to upstreams from the JWT
+// payload. Only the registered claims and the configured claims are kept, or
+// every claim but the omitted ones if none are configured. If an expiry is
+// configured the token expires after it, but never later than the payload
+// itself. The token is signed by sign, which should use the same key as the
+// full JWT so that upstreams can verify it against the published JWKS.
+func getDownstreamToken(sign func(map[string]interface{}) (string, error), options *config.Options, payload map[string]interface{}) (string, error) {
+	claims := make(map[string]interface{}, len(payload))
+	if len(options.DownstreamTokenClaims) == 0 {
+		for k, v := range payload {
+			claims[k] = v
+		}
+	} else {
+		for _, names := range [][]string{downstreamTokenRegisteredClaims, options.DownstreamTokenClaims} {
+			for _, k := range names {
+				if v, ok := payload[k]; ok {
+					claims[k] = v
+				}
+			}
+		}
+		if v, ok := payload["exp"]; ok {
+			claims["exp"] = v
+		}
+	}
+	for _, k := range downstreamTokenOmittedClaims {
+		delete(claims, k)
+	}
+
+	if options.DownstreamTokenExpiry > 0 {
+		now := downstreamTokenNow()
+		exp := now.Add(options.DownstreamTokenExpiry).Unix()
+		if payloadExp, ok := payload["exp"].(int64); ok && payloadExp < exp {
+			exp = payloadExp
+		}
+		claims["iat"] = now.Unix()
+		claims["exp"] = exp
+	}
+
+	return sign(claims)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/directory"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/grpc/session"
@@ -48,6 +49,7 @@ type Evaluator struct {
 	authenticateHost string
 	jwk              interface{}
 	kid              string
+	claimsLimit      sessions.ClaimsLimit
 }
 
 // New creates a new Evaluator.
@@ -56,7 +58,10 @@ func New(options *config.Options, store *Store) (*Evaluator, error) {
 		custom:           NewCustomEvaluator(store.opaStore),
 		authenticateHost: options.AuthenticateURL.Host,
 		policies:         options.Policies,
+		claimsLimit:      options.GetSessionClaimsLimit(),
 	}
+	// the session was already accepted, so its JWT is only ever truncated
+	e.claimsLimit.Reject = false
 	if options.ClientCA != "" {
 		e.clientCA = options.ClientCA
 	} else if options.ClientCAFile != "" {
@@ -101,6 +106,8 @@ func New(options *config.Options, store *Store) (*Evaluator, error) {
 	}
 
 	store.UpdateAdmins(options.Administrators)
+	store.UpdateAdminGroups(options.AdministratorGroups)
+	store.UpdateAdminsOnly(options.AdminsOnly)
 	store.UpdateRoutePolicies(options.Policies)
 
 	e.rego = rego.New(
@@ -144,6 +151,7 @@ func (e *Evaluator) Evaluate(ctx context.Context, req *Request) (*Result, error)
 	evalResult := &Result{
 		MatchingPolicy: getMatchingPolicy(res[0].Bindings.WithoutWildcards(), e.policies),
 		SignedJWT:      signedJWT,
+		JWTPayload:     payload,
 	}
 	if e, ok := payload["email"].(string); ok {
 		evalResult.UserEmail = e
@@ -239,7 +247,37 @@ func (e *Evaluator) JWTPayload(req *Request) map[string]interface{} {
 	if len(req.Session.ImpersonateGroups) > 0 {
 		payload["groups"] = req.Session.ImpersonateGroups
 	}
+	// custom claims never override the claims set by pomerium
+	for k, v := range req.Session.CustomClaims {
+		if _, ok := payload[k]; !ok {
+			payload[k] = v
+		}
+	}
+
+	return e.limitJWTPayload(payload)
+}
+
+// registeredClaims are the JWT claims which identify the session, and so are
+// never dropped by the claims limit.
+var registeredClaims = map[string]bool{
+	"iss": true, "aud": true, "jti": true, "exp": true, "iat": true, "sub": true, "user": true,
+}
 
+// limitJWTPayload truncates the claims derived from the identity provider
+// and directory, such as email and groups, to the session claims limit.
+func (e *Evaluator) limitJWTPayload(payload map[string]interface{}) map[string]interface{} {
+	claims := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if !registeredClaims[k] {
+			claims[k] = v
+		}
+	}
+	claims, _ = e.claimsLimit.ApplyJSON(claims)
+	for k := range payload {
+		if _, ok := claims[k]; !ok && !registeredClaims[k] {
+			delete(payload, k)
+		}
+	}
 	return payload
 }
 
@@ -327,13 +365,15 @@ type (
 		URL               string            `json:"url"`
 		Headers           map[string]string `json:"headers"`
 		ClientCertificate string            `json:"client_certificate"`
+		ClientIP          string            `json:"client_ip"`
 	}
 
 	// RequestSession is the session field in the request.
 	RequestSession struct {
-		ID                string   `json:"id"`
-		ImpersonateEmail  string   `json:"impersonate_email"`
-		ImpersonateGroups []string `json:"impersonate_groups"`
+		ID                string            `json:"id"`
+		ImpersonateEmail  string            `json:"impersonate_email"`
+		ImpersonateGroups []string          `json:"impersonate_groups"`
+		CustomClaims      map[string]string `json:"custom_claims,omitempty"`
 	}
 )
 
@@ -342,6 +382,7 @@ type Result struct {
 	Status         int
 	Message        string
 	SignedJWT      string
+	JWTPayload     map[string]interface{}
 	MatchingPolicy *config.Policy
 
 	UserEmail  string
@@ -56,6 +56,7 @@ func TestJSONMarshal(t *testing.T) {
 				"Accept": "application/json",
 			},
 			ClientCertificate: "CLIENT_CERTIFICATE",
+			ClientIP:          "198.51.100.1",
 		},
 		Session: RequestSession{
 			ID:                "SESSION_ID",
@@ -72,6 +73,7 @@ func TestJSONMarshal(t *testing.T) {
 		},
 		"http": {
 			"client_certificate": "CLIENT_CERTIFICATE",
+			"client_ip": "198.51.100.1",
 			"headers": {
 				"Accept": "application/json"
 			},
@@ -263,6 +265,22 @@ func TestEvaluator_JWTPayload(t *testing.T) {
 				"groups": []string{"admin", "test"},
 			},
 		},
+		{
+			"with custom claims",
+			&Request{
+				HTTP: RequestHTTP{URL: "https://example.com"},
+				Session: RequestSession{
+					ImpersonateEmail: "user@example.com",
+					CustomClaims:     map[string]string{"tenant": "acme", "email": "other@example.com"},
+				},
+			},
+			map[string]interface{}{
+				"iss":    "authn.example.com",
+				"aud":    "example.com",
+				"email":  "user@example.com",
+				"tenant": "acme",
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -276,6 +294,27 @@ func TestEvaluator_JWTPayload(t *testing.T) {
 			assert.Equal(t, tc.want, e.JWTPayload(tc.req))
 		})
 	}
+
+	t.Run("claims limit", func(t *testing.T) {
+		t.Parallel()
+		e, err := New(&config.Options{
+			AuthenticateURL:          mustParseURL("https://authn.example.com"),
+			MaxSessionClaims:         1,
+			SessionClaimsLimitAction: config.ClaimsLimitActionReject,
+		}, NewStore())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"iss":   "authn.example.com",
+			"aud":   "example.com",
+			"email": "user@example.com",
+		}, e.JWTPayload(&Request{
+			HTTP: RequestHTTP{URL: "https://example.com"},
+			Session: RequestSession{
+				ImpersonateEmail:  "user@example.com",
+				ImpersonateGroups: []string{"admin", "test"},
+			},
+		}))
+	})
 }
 
 func TestEvaluator_Evaluate(t *testing.T) {
@@ -355,6 +394,68 @@ func TestEvaluator_Evaluate(t *testing.T) {
 	}
 }
 
+func TestEvaluator_EvaluateAdminsOnly(t *testing.T) {
+	dbd := DataBrokerData{
+		"type.googleapis.com/session.Session": map[string]interface{}{
+			"SESSION_ID": &session.Session{Id: "SESSION_ID", UserId: "USER_ID"},
+		},
+		"type.googleapis.com/user.User": map[string]interface{}{
+			"USER_ID": &user.User{Id: "USER_ID", Email: "foo@example.com"},
+		},
+		"type.googleapis.com/directory.User": map[string]interface{}{
+			"USER_ID": &directory.User{Id: "USER_ID", GroupIds: []string{"GROUP_ID"}},
+		},
+		"type.googleapis.com/directory.Group": map[string]interface{}{
+			"GROUP_ID": &directory.Group{Id: "GROUP_ID", Name: "ops"},
+		},
+	}
+	policies := []config.Policy{
+		{From: "https://foo.com", AllowedUsers: []string{"foo@example.com"}},
+		{From: "https://public.com", AllowPublicUnauthenticatedAccess: true},
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name           string
+		admins         []string
+		adminGroups    []string
+		reqURL         string
+		sessionID      string
+		expectedStatus int
+	}{
+		{"non-admin allowed route", []string{"bar@example.com"}, nil, "https://foo.com/path", "SESSION_ID", http.StatusForbidden},
+		{"non-admin public route", []string{"bar@example.com"}, nil, "https://public.com/path", "SESSION_ID", http.StatusForbidden},
+		{"non-admin pomerium url", []string{"bar@example.com"}, nil, "https://foo.com/.pomerium/", "SESSION_ID", http.StatusOK},
+		{"non-admin pomerium url in query", []string{"bar@example.com"}, nil, "https://foo.com/path?redirect=/.pomerium/", "SESSION_ID", http.StatusForbidden},
+		{"non-admin pomerium url in path", []string{"bar@example.com"}, nil, "https://foo.com/path/.pomerium/", "SESSION_ID", http.StatusForbidden},
+		{"no session", []string{"foo@example.com"}, nil, "https://public.com/path", "", http.StatusUnauthorized},
+		{"admin allowed route", []string{"foo@example.com"}, nil, "https://foo.com/path", "SESSION_ID", http.StatusOK},
+		{"admin public route", []string{"foo@example.com"}, nil, "https://public.com/path", "SESSION_ID", http.StatusOK},
+		{"admin group", nil, []string{"ops"}, "https://foo.com/path", "SESSION_ID", http.StatusOK},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			e, err := New(&config.Options{
+				AuthenticateURL:     mustParseURL("https://authn.example.com"),
+				Policies:            policies,
+				Administrators:      tc.admins,
+				AdministratorGroups: tc.adminGroups,
+				AdminsOnly:          true,
+			}, NewStore())
+			require.NoError(t, err)
+			res, err := e.Evaluate(ctx, &Request{
+				DataBrokerData: dbd,
+				HTTP:           RequestHTTP{Method: "GET", URL: tc.reqURL},
+				Session:        RequestSession{ID: tc.sessionID},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, res.Status)
+		})
+	}
+}
+
 func mustParseURL(str string) *url.URL {
 	u, err := url.Parse(str)
 	if err != nil {
@@ -84,6 +84,23 @@ deny[reason] {
 	contains(input.http.url,".pomerium/admin")
 }
 
+# in admins only mode, require a session for every route
+deny[reason] {
+	reason = [401, "login required"]
+	data.admins_only == true
+	input.session.id == ""
+	not is_pomerium_path
+}
+
+# in admins only mode, deny non-admin users from every route
+deny[reason] {
+	reason = [403, "access is restricted to administrators"]
+	data.admins_only == true
+	input.session.id != ""
+	not is_admin
+	not is_pomerium_path
+}
+
 deny[reason] {
 	reason = [495, "invalid client certificate"]
 	is_boolean(input.is_valid_client_certificate)
@@ -150,6 +167,23 @@ normalize_url_path(str) = str {
 	str != ""
 }
 
+# pomerium's own endpoints, such as the sign in callback, must be reachable
+# without admin rights. Only the path is matched, so a query string or another
+# host can't be used to claim an exemption.
+is_pomerium_path {
+	startswith(parse_url(input.http.url).path, "/.pomerium/")
+}
+
+is_admin {
+	element_in_list(data.admins, user.email)
+}
+
+is_admin {
+	some group
+	groups[_] = group
+	data.admin_groups[_] = group
+}
+
 email_in_domain(email, domain) {
 	x := split(email, "@")
 	count(x) == 2
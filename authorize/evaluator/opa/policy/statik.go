@@ -9,6 +9,6 @@ import (
 const Rego = "rego" // static asset namespace
 
 func init() {
-	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\xcc\xb1\xfcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\n\x00	\x00authz.regoUT\x05\x00\x01@\xa3 _\xacWO\x97\x9b6\x10?\xc3\xa7\x98\x90\x8biY\x9c\xf4\xcf\xa1\xce\xa3\xdb\xbc\x9czh7/iO<Bd\x90\x8d\x12\x90\xa8$\x12o6\xf9\xee}#	\x1bc\xc3:\x9b\xecam\x8f~\xf3\xe773\x8c\x86\x96\x14\xef\xc9\x96B+\x1a*Y\xd7\xc4\xa4\xd3\xd5'\xdf/\xe9\x86t\xb5\x06R\xd7\xe2#$\xb0!\xb5\xa2\xbe\xefK\xd1i\x9a\xb7\xa2f\xc5m\xce\xca\x1d\xac\x12\xd80\xa9tn\x90\xb4\xcc\xc7\x88\x05\xe3m\xa7\xe3J\xeb6\xeed\x1d\x1e\x99@\xf5\x92h\x12\x0f\x84\x8c\xaatl$\xf3\x15U\x8a	\x8e\n\xd6 \xaa\xad\xa5xOen,8\x80\xdf)*\xa7Qx\xeao\xa5\xe8Z5\x0d\xb2\xe7\xbeO\xeazO\xab\x14\x0da\xdc(m\xa9\x1e\x8b\x17\xc3\x80\xc3#\xc5\x83\xb3\xa1\x9e\x95\xce\xa8a\xa0'ZF8R\xf2\x1f\xbb\"\xb5\xdd\xbaf\x85o\x7f\xdc\xf9\xde\x10\x16?G\xe9K\x83\xf8\x97c\x8d)\xd7\xac \x9a\x96\xcf\x8b\x82*\x05I\x02Zv\xd4\xffr0X\x08\xa9\xa0\x95tS\xb3m\xa5'\x0c\xbf\xb8y\xf5\xda\x1a\xef\x81{S\xde\xa0\xf2\x0d\xd5\x95(\xf1(\xb8y\xf9\xcf\x9f7\x7f\xbf\x0e|\xaf\x10\x1d\xd7\x0b\xb1~G\x0b\x1do\xa9\x1e\xb6JEII\xa5\x8a \xb0\x01^\xbd\x10\\KQ_\xbd\xa2\xffuT\xe9\xab\xbf\x8c\xc5 \x824\x0bC\xf8\x1d\x9e\\j\xefF\xb2-\xe3C\xc5\x01\xe7\xf5-\xd0\x86\xb0\xfa\xc0\x16s\x1e\x1b\x19F\x7fR\xa24\xcfz\xa2\xae\x03c\xd6\xb4T*\xc1\x89\xa6\xf9^1\x08\x86nL\xf9\x0f>\x94h\xa8\x93y\xb63\xd2<\x83\xa4\x17\x9d\xb6\xd3\xd1\xf1\xb4w\xd7{I\x02\xbc\xab\xeb\x11\xcf\x01p\xcc\xf9\x1cK\xe8\x9f\x96I\x9a3\xf6g\xf8\xde\x17\xfdWd\xe2\xd8\xbf}4GN\x9d\xd03\x01\xe7\x8c\xbb\x07xq\xa8r\x04g\x1e\xfb\xd4~f\xe1\x03j=J\xc5W\x85u\x8f\xb3{b\x1d\x04\xd1\xcfw\xe8d\xad\x0e\xce\x0b\xc1\xb5\x99_\xc7C:\x82`\x19\xf7*\xcb \xf4=.4\\\x04&e\xc3xp\xe4\xdbLd\xa6\xc0\x1c\x1d|\xd3\x9a6\x94kd[3\xa5\x17f\xf4\x1a\x8c\x8a\\\xab\x1d\xaa\x12\xce\xc5:\xe1\xbd\xa4\xfc\x16\xb8\xe0WF\nv\xa2n\xa4h\x80\xe0Ha|kC\x023\xd0\x94\x8f\xf8TR\xa2\x04\xcf\xcc\x9c3_!\x81\xf4\x97'?G\x10\xf4<0\x17\xd6Qf\x133\xcb\xe4\"\x0e\xe7)\xcc\x04\xf4\xdb\xaf\x11\x04\x8c\x7f 5+\xa1\xa8\x19\xe5\x1a\n*5\xdb\x98\xa9\x8e\x911\x95\xaf\x85\xa8)\xe9\xdb\x88\xa9\xdc\xe0s\x8b\xcf\x07xW\xe1{q6\xb1\x92\xeaNr\x05\xba\xa2\xf6\xf2\x87\x86\xe8\xa2\xc2\x84\x9aT\xfa\x97l\x049.\x03\xd0o\x0f\x83\x8d\xe2\xce\xf7Nd\xab\x04R\xfc\xfc\x0c\xe6qa\xe5.\x02{\xfc\xcc}\xc2\xf9E\x02w\x87gp\x14\xc9I\xff\xb8\x9b4K\x9fd\xc8\xef\x0c8\x1f\xe2\xee\xdc\x0c@a.\xd6\xef0\xb8\x96HEQ0\xe0f\xa6\xd5 \x01Jt\xb2\x18\x18D\xdd\xbd\xd11\x18\xaf]\xb6\xbb\x14Ltu!T\xd2-\x9d4;&?\x1f2\x16jp\xd7Zi\x04\x81U\n\"\x08\x82p?\x0d\xbf\xb7\xddG\xc6\xaege\xe7+\xe1\x16\x14\x0b	GE\x8b+\xa1\xcc\xa6rl\xc1\x88O\xf30[\x8d\xa9x\xad\xd2l\x1e\xbe\xddn\x9f\x07M\xa4V\x1f\xd9\xb8\x0fbl\x8d\xdebl5\xcf\xd4y\xa6\x81&\xa3 \xba\x9a\xe7\xf6M6\x1d\xaf>p\xa2+ts\xca\xed\x94\xcb\\\x87O96:\xb3l\xbe\xd5\xaa\xe3#inFe\xdf\x9c\x06\x12\x9d\xe1e\x8at\xe8e\xa5%\xce\xca;\x08TQ\xd1\x86\x06+\xb0_\"\x08\xb0e\x83\x15\xe0G\x9f\xc3\x15\x98\x8c}\xc1\xc8\xd2<\xdac-F\x92\x8fx\x8c{\x93\xf1\x1fo\x18/qZ\xe7JK\xc6\xb7\xb9\xea\xd6&\xca\x9c/|\xcf{\xbb\xb8^-pZ\xa6*\xbb\x0eW\xcbex\xbdH\xdf,\xb3\x1f\xc3E\xfa\xe6\xfaq\xf6C\xf86\xf2=Oi\x19\xc1\xd3\x10\x87\xa8g\xeb\x05\\\xc8\x86\xd4\xec\x93}\xbcLC8\xdf\x86\xde\x99c\xc73X\x06f5\xd2r_\x8ei0\xa2\x1c\xf8Q\xbf{\x8d\xf7(\xb7-\xd9_\xa6`\xe6NQm\xcdt\x7f\x18\xfc\x11\x84\xfd;\xc4\xce\xf4\xc1O\xbe\xb7K\x9ff\xf8\xd5-hhzt\xd9\xe3\xbf\xc8\xac\x00h\x17\x00\x7f\xdb\xa5\x14e\xa8q\xfa\x06\xd77N\x02\x1f\x8c\x0e\x80\xea\xd6\xa3W\xbf\x04R\xd5\xc6\xc7\xb2\xcf\xa0Z3\xe4\xdch\xeb\xd6\x87\x9b.\xcf2c\xe9\x03\x02\xee\x00\xef\xca\x1d$@\xa4$\xb7q!xA\xf4\xc2\x00\xf0\xcf\x198\xb2\x1e\xedO\xd3\x0e>C7\xed\xe8Xo\xef9D\xda_\xc6\x8c\xfb7\xe4S\xce\x0f\x89\xd4Y{@\xac\xfdv<\x1f\xad{/\xff>\xc1Zc\x0f\x88u\xffj3\n\xf5\xff\x00\x00\x00\xff\xffPK\x07\x08\x7fq\x93\xd4\xcb\x04\x00\x00\xae\x11\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xcc\xb1\xfcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x00	\x00authz_test.regoUT\x05\x00\x01@\xa3 _\xecXMo\xe36\x10=K\xbf\x82\xe0i\xb7pl8\xed\xc9@\xd0,\x16E\xd1C\x9b\xc5\xee\xf6\x14\x18\x02-M%v%QKRX;\x86\xff\xfb\x82\x1f\xfa\xb2%\x9b\x11b'\x07\xe7\x12\x9b\x9ay3\xf3\xde\x0cE\xba \xe17\x12\x03*X\x06\x9c\x96\xd9\x94\x942y\xf2}	B\x06\x90\x11\x9a\x06$M\xd9\x0f\x88\xd0\xd6\xf7\xf4G\xf4\x83\xca\xc4\xf7\xbc\x88H2\xe5\xac\x94\x10\x14,\xa5!\x05\x81\x88@\x8f[\xdf\xf3<,X\xc9C\xc0\x0b\x84aM\xb2\"\x85i\xc82<\xd1\xcf,bP\n\xe0\x02/\xd0#^\xdf\xb7\xad\x96\xbe\xe7\xed\x96U\x1c\x9a\x17\xa5\x9c\xaah+\xce\xbe\x01\x0f\xd4G\x15\xc9\x06\x02!(\xcb\xf1\xc2|\xf7\xb0B\x0dh\xa4B\xab\x8fs\xac\x96w&\xb2Zh,u}\xca\xae\x1b^\xdb\xab\x14\xba\x19$R\x16:,\xc2%\xd7nje1\x9b\xb5}\xd1\x9e\x93\xcd\xce\xfa\x99\xac\xec\xda\x1cO\x10\xa6Y\x01\\\xb0\x9cH\x08\xeat0\xda\xf9;\xab\xc1\x81A\x903\xd9\xd6$g\x12]u\xb9\x88.\x9b\xfb.\xe4\x11\x91.14\x9b\xab8.\xe2\xc4\x9c\x95\xc5\x19\x05\xd1\xf8F\x91\xf9k\xab0i9\x1c\xe6uai\xea\x04\xf22M\x07\xa6\xc5\xd8\\`O{K*\xb5\xec#\xca!\x94\x8co\x82\xb6'B\x08\x1d\xea\xf7*\xf3\xd5\xca\xe2\x16/\x8f\xabx\x91\x19\xbb\xbd\xaa\xf7\x02\xeaE,#4?\xa3b&\x80	}}M\x9d>\xdbYA\xce\xbf\x11^\x859q~\x98\x1f;\xdd\xbd\xc6\xdc\xcc\xaf\xfa\x1c\xd5\xc7\xae?k^T9\xb6\xe0F\x95\x9e\xd4'\xc6\xa6 2Q\x163R\xad\xb8\x8c\x93%xL\x9c\xd5~\x9c\xe6\xd8\x9f3\x96\xc3}}a\xef\x06[>_\x9e\xd9\xea@ \x15\xac\xa3\x8e\x96\xa5\x96\xe2\x7f\x06C\x87ms\xfb\x89 \xa7g\xdb\xc0\x1a&Vlu\xf2\n\xe4\xc2\xc0\x0b\xd6_\x94\xab\x94\x86g\xd8\x1f>(\x98O\x1a\xfd\xdf\x9c\x942\x81\\\xd2\x90H\x88>\x84!\x08E\x88\xe4%\x8cg\xc0\xdfu*\x18!ao\xaf\xf759\x87\xff\xe8\xda\xb4\xf9\xe6F\xef>\x83\xcd\xde'\xf1\xd0X\xf5\x84rgMonC\xcc\x99mrx\x80l\x15\x8a\xfc\xa6\x13\xec\x80\x9e\xa1\x17\xdch\x1a\xd3\x07\xb3i\x95\xf6\xac\xdd\x12U)#\x9a\xe2\xec\xd5\x9c\xd0\xa6)\x88D\x19\xcdm\xcc\x84	\xb9\x9fMG\xbd\x90q\x11\xa8FMi\x9c\xc8\xd7\xd1\xd0\x18~|\xf8\xfc\xc5\xb4q\x95\x8d\xc3\xa8k\xcf\x0cd\xc2\xf4\xf6\xf5\xf0\xe9\xeb_\x0f\xff|\xb1\xa1\x87\xc9\xaa\xd8\x01\x12\x99\xac\xec\x8c=p\x1a\xd3\\g)X\x06\xcc|]VC\xa6G\xe9\xe6#\xcb%g\xe9\xcdg\xf8^\x82\x907\x7fW\xe1\x1f\xf1\x9f\x7f|m\xdd\x81\xaa\xb6\xda\xe3\xf8\xcd6\xd7\x1b\xe6\xd1\xce'\xe1\x02\x82\x92\xa7*\x8e\xfa\xb7\xb8C\xf5\xda\xbb\xbe\x04U\xf4\x99:l\xfc\xfe]\xe0\xf7\xdai*\xc2\x042@ww\xa6$lV\xd5\xa4\xe8\xb5\xee\xa8\xa8G\xca_?j\xe0p\x9dSE\xbcQ\xcdHT\x8fO\xb5\xde\x97\x1b\x9e\xa0\xed\x80\xa4\xbb\xf7\xcf\xf7\xef1\x18\x0b#\xc6\xe0\xcc^\n\xe84\xce\xcc\x0d\xc8!#\x83T\x8f\xe1 \x1a\xe3\xf1q\xb9\xfa\xbb\xc1\x1c\x00\xdc\xbb\xa1u`p,Q\xef\xf5\xba-uW\xee\x81\x987\x81[\x89=9\xd4\xee\x03\xd5\xa9\xb1p\xaf\xad:\xf1;V\xd6\xe3\xe4TD/%\xf5\xadf\x0c!\x07\xce\xfdtp\x88\xe1\x19Zks\x85;\xfd\xc5\x91\x91\x9e\xc2j\x10\xfb\xd0b\xb9\x17W\x03<\xae7O\xcbvq\xa2\\\x99w\xd0F\xd5\xb4V[m\x0cM\xcd\xfa=\xf3n\xeb#\xfb7\xf8r\xaa\x0d\x0e\xdfP\xa5\xbem\x94\xb7\xea\xc5\xd0\xe0Tq)h\xab\xfa\x89\xfa\xdb\x1e\x81\xf9\x15/\xd1n\xe2`~\xab\xcd\x7f\xd3?\x13V\x96K\xfdIq\xb7V;\xfd\xb6\xc9\xcd@\x1b\x8f\x9d\xef\xfb\xdef\x9f\n{3\x1eEF\xfbV\x1d\xe9\x90\xd18:z\x80\x8e\x13\xd2q\xd0eFC\x94l\x0c%u~\x06\xdcxhJ\x9e\xf6)1?\xc7\x8eb\xa4\xf5Kn\xac\x03\xc6\xe3\x089\xc49\xceG\xdb^\x97\x18\x0f\xd1\xf1d\xe8\xa8\xb33\xd8\xc6C\x9dU~\x06\x00\x00\xff\xffPK\x07\x08tC\x13e,\x04\x00\x00k \x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xcc\xb1\xfcP\x7fq\x93\xd4\xcb\x04\x00\x00\xae\x11\x00\x00\n\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00authz.regoUT\x05\x00\x01@\xa3 _PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xcc\xb1\xfcPtC\x13e,\x04\x00\x00k \x00\x00\x0f\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x0c\x05\x00\x00authz_test.regoUT\x05\x00\x01@\xa3 _PK\x05\x06\x00\x00\x00\x00\x02\x00\x02\x00\x87\x00\x00\x00~	\x00\x00\x00\x00"
+	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\xa6FO]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\n\x00	\x00authz.regoUT\x05\x00\x01y\x94\xd0j\xacXMw\xdb\xb6\x12]\x8b\xbfbB/\"\xbeG\xd3\xc9{\xed\xa2\xceQ\xdd\x9c\xac\xbah\x9d\x93\xb4+\x1d\x85\x81\xc8\x91\x88\x84\x04\x18\x00\xf4Gl\xff\xf7\x9e\x01@\x8a\xa2%Jv\x9a\x0d\x15`\xe6\xce\xbd3\xf8\x18\xb8f\xd9W\xb6F\xa8e\x85\x8a7U\xc2\x1aS|\x0f\x82\x1cW\xac)\x0d\xb0\xb2\x94\xd70\x83\x15+5\x06A\xa0dc0\xade\xc9\xb3\xdb\x94\xe77p>\x83\x15W\xda\xa4\xd6\x12\xf3th1\xe5\xa2nLR\x18S'\x8d*\xa3-\x08r\xcf\x99aIo\x90\xa3\x9e\xf7m\x08d\x11h\xd4\x9aKA\x0e\x0e\x90\xdc\x96J~E\x95\xd2\xcf\xc4\x1b\x04\x8dF\xb5\xdf\x8af\x83\xb5\x92M\xad\xf7\x1b\xb9\xf9 `e\xd9\xc9\xcae\xc5\xb8\xb0Nk4\xc3\xe1i\x9fp\xb4\xe5\xb8	\xd6\xf7s\xa3#nD\xf4\x91\x97\x1d\x1c8\x05'\xbeHu\xb3,yF\xb1\xe55\xdc\x05\x93\xbeY\xf2\x96\xd8\xbc\xb7\x16\x7f\x0b\xaa1\n\xc33f0\x7f\x9be\xa85\xccf`T\x83\xc1\xc3\x060\x93JC\xadpU\xf2ua\xf6\x00\xbf\xbb\xfc\xf0\xd1\x81\xb7\x86\x1d\xd4\xa4W\xf9\nM!s\x9a\n/\xdf\xff\xf5\xfb\xe5\x9f\x1f\xc3`\x92\xc9F\x98\xa9\\~\xc1\xcc$k4\xfd\xa5R \xcbQ\xe9\x18BG\xf0\xf4\x9d\x14F\xc9\xf2\xf4\x03~kP\x9b\xd3?,b\x18\xc3|\x11E\xf0+\xbc:\x16\xefR\xf15\x17}\xc7\x9e\xe6\xe5-`\xc5x\xb9QK9O\xec\x18\xb1\xefW\x96f\xf4<]\xb4B\xfd\nLxU\xa3\xd2R0\x83i\xe7\x18\x86\xfd0\xb6\xfc\x9b\x18ZV\xe8\xc7&\xf6C\xb00k\x87\x1e/\xa7\xad\xe9\xfd\xd1\xfd\xda\x9b\xcd@4e9\xd0\xd93\x1cj\xde\xa5\x12fp@\xe6\x08\xfe\x88\xdeC\xec\x9f\x90\x89\xed\xf8nk\x0e\x82\xfa\xc1\x89\x15\x9cr\xe17\xf0tS\xe5\x18vl\xfb\xb9\xfb.\xa2g\xd4z\x90\x8a'\xd1:\x10\xec\x00\xd7^>\xda\xf3\x1d\x1aU\xeaMN2)\x0c\xe9\xeb\xef\xbcF\x951\x84gI\xebr\x16F\xc1DH\x03G\x19\xb3\xbc\xe2\"\xdc\x8aM\xb9\x05\xae\xc1Nmbc\x89\x15\n\x93r\x91\x96\\\x9b\xa9=z\xad\x8d\x8e\xfdR\xdbT%\x1a\xe3\xba'z\x8e\xe2\x16\x84\x14\xa7\x16\xd4\xd2\xd0\xb0R\xb2\x02FG\n\x17kG	\xecI\xa9\x03\xb2\x9f+dZ\x8a\x05\x11t?a\x06\xf3\x9f^\xfd?\x86\xb0\xd5A\xb9\xb0\x8e\xe1\xc2%fT\xc9Q\x1a\xf6I\xe0\xc2E\xd2 Ey\x0b\x95\xcc1\x06\x85\xdf\x1a\xae\x10\x18\xf8U\x08+\xa9\x00\xafP\xdd:)\xa3J^\xc7\x10\x96r\xcdE\x8b\x93\x93\x8c^\xf2S\x1bjp\x88w\xeb=\xf7\x87\x99\x15\xceu\xda\xf2Nkf\x8a\x11\xd2\xfb\x8bq,q*\x81-\x9c\xa6\xc5\xa4P\x1b\xc53\x839\x18\xe9\x92\xc4\xb5Q\xccH\xa5\x9f(\xe8\xc5\x96 \xeb\xb3_\xdeHj\x7f\xf99\x86\x90\x8b+V\xf2\x1c\xb2\x92\xa30\x90\xa12|eoZb\xc5u\xba\x94\xb2D&\xfc&\xe2:\xb5\xf6\xa9\xb3O{\xf6~\xd7\x1d\xb4sIWh\x1a%4\x98\x02]C\x06\x153YA\x8b\xdc\xa5\xf6\x98.-\xa5\x06\x0d\xda\x8e\xae\xd7\xe5\xdd\x05\x93Gc\xe73\x98S\x07x\x0f\xf68\xe7\xf9M\x0cn\xfa\x8d\xff\xc2\xee\xe6\x8e\xfa\xb97\xd0\x1e\xe3\x96\xdd\xa3#\xc5\x01D\x8b\xf9\xab\x05\xe9\xdbaL\\\xdb\x80\xd1\x9d?\x97i0\x95\xcb/D\xaefJ#\x0dL\xbb\xa9\xc8\xde\xa5\x1b\xa4T\xcbFe8\xdd\xf2\xed@\x87\xc6\xd4\n\xf1^\xa6\xc6\x8d\x99)\x8e4U\xb8\xc6\xbd\xb0C\xf1\xe3\x94\xa9P\xbd\xfe\xc7q\x8b!tNa\x0ca\x18u\xdd\xc8\x13Rq\x14\xae\xdfGnlw%\x9cc\xe2L\xda\xcb\xb45M\n\xa9m\xf7\xb8\x8d`\x87\x1f\xe7a\xb4\x1a\xfb\xf8:\xa7\xd1<\xfc8n\x9b\x07\xc3\x94\xd1\xd7|\xb8\x0e\x12Z\x1a-b\xe2\xc2\xed\xa8\xf3\xc8\x02\xda\xcb\x82\x99b\\\xdb\x0faz]-qf\n\n\xb3E1io\x81\xe3W\xf8\xbe\xc0vW\x8c\xaa\xf9QT\xafGaj\x8fJ\x1f:\xb1\xb01l\xe1Z]\xb6H\x9bSE\x1bEg\xe5\x1d\x84:+\xb0\xc2\xf0\x1c\xdc\x8f\x18BZ\xb2\xe19\xd0\xa7\xcd\xe19\xd0\x07\x1eH\xef<\x8d;[g\xa3\xd85MS/k\xe3'+.r\xeaBS\xba\xe6\xc4:\xd5\xcd\xd2\xb2L\xc54\x98L>O/\xce\xa7\xd4\x80\xcd\xf5\xe2\":?;\x8b.\xa6\xf3Og\x8b\xffF\xd3\xf9\xa7\x8b\x93\xc5\x7f\xa2\xcfq0\x99h\xa3bx\x1d\xd1!:!x\x98\x81\x90\xaab%\xff\xee6(\x0dN}l+o\xc7\xb4\xd7\x19\x9e\x85D]\x1b\xd5\x1d \xfb\x8d\xc9\xca\x1b\xbf\xf0\xc6\xc1I\xd7\x84\xbe\xd4 \xaf\x05\xa0\xc8k\xc9\x85\xd11\xe8&+\x80\xb9\xdbK\xf3\xb5\xa0\xc6'ce\xb9d\xd9\xd7\x18\xaaF\x1bX\"(dY\xc1\x96%\x06'@\xfbJ6\xbe\x11\x03E/T\x9d\xc0%\xb5.t\x05\x92 j\x13l\xce0\x8fAK`\xf0\xad\xa1\x1e\xc9e\x14\xa4\x02&\xa4)P\x05'\xb6\n\x901\xf1\xd2\x06j\xb4k+\xb2\x92\xf1\n\x98\x00\xbc\xc1\xaa6\xd4\xf0\x07\xc3\x9e\x07\xee\xb6\xb6\xfa\xe0\xd6\xe9\xba\xe4\xc8o\xfbAc\xfd\x10\x10\xa0\xed9\x08\xe8\xe8Nr\xe0w\xe0\x01\xb9\xe9\xeev\xbc\x1f\x1f\x82`\xf8\xec\xf0\x8f\x0b\xf7?\xbb\x97\xecu\xaf\xeb\x92\x9bv2\xfc\x8d\xde\x05\xee	\x7fc/\x95\xff\x05\x93\x9b\xf9\xeb\x05\xfd\xf4\x8f\x1c\x82\x1e(\xa2V?\xb6\x1d3\xe1\x02\xd0\xff\x1dW\x1a#\x8f\xc7\x7f\xf0hO\x8a\x19\\Y\x1f\x00\xdd,\xbbV\xc6\xdaP\x97\xae\xebd{\xec\x1etM\xbc\xfd\xc6&\xa7\xae	I\x17\x0b\x8btE\x06wp\x03\xf7p\x033`J\xb1\xdb$\x93\"cfj\x0d\xe8\x9f\x07\xd8B\x8f\xbb\xd9y\x03\xf7\xd0\xec\x0f\xb4\xed\xd7E\x8eH\xf6\xc3P\xb1\x7f\xc4\xed\xd0\xfc\x1c\xa6\x1e\xed\x19\\\xbd\xe7\x01\xb6\xfe\xcfX\xff\x0eY\x07\xf6\x0c\xae\xdd\x9a\x1eP\xfdg\x00PK\x07\x08\xbf\x95$\xab\xe8\x05\x00\x00\xdd\x14\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\x94q)Q\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x00	\x00authz_test.regoUT\x05\x00\x01\xd8\xe2X_\xecXOo\xdb6\x14?K\x9f\x82\xe0\xa9-\x1c\x0bNv2\x10\xacE1\x0c;l)\xda\xeed\x18\x82,q2WITI\n\xb1c\xe8\xbb\x0f\x8f\xa4h\xca\x96\x1cY\x88\x93\x0cH\x0e\x89B\xbd\xbf\xbf\xdf{\x8f\xa4\xca(\xfe\x11\xa5\x04\x95,'\x9cV\xf94\xaa\xe4\xfa\xc1\xf7%\x112$yD\xb30\xca2vO\x12\xb4\xf3=\xf5\x88\xee\xa9\\\xfb\x9e\x97D2\x9arVI\x12\x96,\xa31%\x02E\x02-v\xbe\xe7yX\xb0\x8a\xc7\x04\xcf\x11&\x9b(/32\x8dY\x8e'\xea\x9d\xb1\x18V\x82p\x81\xe7h\x817\x1f]\xa9\xa5\xefy\xf5\xb2\xf1C\x8b\xb2\x92S\xf0\xb6\xe2\xec\x07\xe1!<\x82'\xe3\x88\x08AY\x81\xe7\xfa\x7f\x0f\x83\xd5\x90&\xe0\x1a\x1eg\x18\xc4j\xed\x19\x16\xf6\x92*?\x90k\xbb\x07\xc9\x1aBhG\xb0\x96\xb2Tn\x11\xae\xb8R\x83\x95y\x10\xb8\xba\xe8@\xc9Dg\xf4tTfm\x86'\x08\xd3\xbc$\\\xb0\"\x92$\xb4\xe1`T\xfb\xb5\xe1\xe0H ,\x98t9)\x98Do\xbc<\x0b/\xdbV\x99\x9c$\xc9!\xe8b\xe4l\xdf\x9a\xc6i\x9a^rR\xce\xaa\xf2\x82\x84(\xfbz\x8c\xcd^ztM\x1c\x85\xe3\xb8\x9e\x99\x1a\x1b@QeYO\xb7h\x99g\x98i\xc7h\xbc\xf0\x06\xa3\xa8\xc2	\xe5$\x96\x8coCwkB\x08\xa1c\xfe^\xa4\xbf\x9c(\xae\xf1\xf24\x8b\x0e\x83\x97c\xef\xfaU\x1c\x0f\xfe\xef\xec%,\x8fhqA\xc6\xb4\x03M\x99+\xf5\x1a\xc8{\x816\xb2\xe1\xf4\x1d\x1b\x0c!\x97\x1f\x84o\xc4t\x13c\xcf\x0f3c\xf3\x14M\xcf\xda7\xb3\xb7\xf3\x9d{\xbe;\xe2\xc7\xac\x9f\xd5/\x90\x8e\x19\xf3\xfb\xa6\xe9\x08]U\xb0\x87\xcbH\xaeA\"\x88\x9a\x95!s\xcel\x13c\xfc\xac\x0e\xfd\xec\xef\xca\x05c\x05\xf9h/\xecMmhg\xcb\xf3\xe9	VG\x04\x81\xb3\x16;jc\xb1\x9d\xfb/#\xad\xdapzE\xc9\x84	)\xe8\xc5\x06\xd8\x1e\x89\x15[\xb5\xe2\xe8j\x91Q\x05:>\xff\xb2Ze4\xbe\xc0|\xf8\x04u\xf0EY\xff\xbb\x80\x8f4\xa4\x904\x8e$I>\xc51\x1100$\xaf\xc8x\x04\xfc\xba\x95\xc1\x08\n;{\xea(\x13\x0f\x97\x9c\xfcC7\x10H\xb0\xda^\x01\xd6\xfd\xc5\xdeEq_[u\xb8\x1a\x8e\x9a\x1an}\xb5\x03\xef\xfb\xc1\xb3Y\x00\xf8\xb6\x13\x9a\x06\xbd\xe0^\xf1\xf4\x9d\x10L\x9b\xb0\x03\xb7$\xcc\xda\x98\xa2\xb8x_?\xc2\xcd>\xa1(\xc9ia|\xae\x99\x90\x87%\xd3b/f\\\x84P\xa8\x19M\xd7\xad#\xd9\xa0Vx\x92\xacu\xa8\x9f\xef\xbe~\xd3e\xdcD3\xa0\xd5\x95fN\xe4\x9a\xa9\xf1u\xf7\xe5\xfb\x1fw\x7f}\xc3\x93G\xc0j\xd0!Q\xa2\x1b\xd0l]w\x9c\xa6\x14N{\x0b,XN\x98\xfewi\xbaV\x0f\xa0\xab\xcf\xac\x90\x9ceW_\xc9\xcf\x8a\x08y\xf5g\xe3~\x81\x7f\xff\xed\xbbs\x83\xf5\xebN\x8c_mq\xbdb\x1cM\x7fF\\\x90\xb0\xe2\x19\xf8\x81?\xf3[d\xd7\xdeu\x11\x0d,\x06p\xa8\xf9\xf5\xa7\xc0\xef\x95\xd2T\xc4k\x92\x13t{\xabg\x1c\xd6\xab\xd0)j\xcdQ7\xaf@_\xbd\xda\x9b\xc36\xa6fF\xe9\xe6\xd0\x14\xd9\x11\xd8\xacw\xc5\x86'h\xd7Ci\xfd\xfe|\xfd\x0e\x81\xb1f\xc4\x18;\xc1S\x19z\xdcN\xf0d\x11iKv\xc6\xf7\x86\xc5xz\x10\x96c\x04ltW\x03\xccU\xba\x19^\x0d\xce\x81a`\x8aj\xd6\xab\xb2TUy`D\xbd\x1d\x98bG\x0cV\xbd';h\x8b\xe1\xb95'\xfes\xc8k+\x0dJ\xa2\x13\x92\xc6\xcc(@\x8e\x94\xbb\xe1\xe0$%gp\xad\xc4\xc1\xee\xf4\xc3x\xae\xad\x11\xf3r\xfaa8Pm\x03\x8b\xcd\xf6a\xe9r-\xaa\x95\xbe\xb8l!\xa7\x0d\x8c\xda\x94\xd8\x03\x82>\x92\xbd\xdb\xf9\xc8\xfc\xf4L\xb2\xc9^\xa0\xa5\xa9\xb6\xd8J\xdd\xb6\xaak\xd8`\xad\x98\xf5K\x89\x92\xb2o\xe0gw\xc2\xcc\x0d|\xb5\x9d\x0c\x10\xbfV^\x7f\x01q+\xbdTO\x80\xdd\x06&\xfdn\x1f\x1b\xc8\xde\x18\x8d\xda\xf7}o{\x08\x85\xb9\x19\x8f\x02\xc3\xbdU'*\x8fd\x1c\x1c\x1d\x86N\x03\xd2RP\x90$}\x90l5$6>\xf8}c4\x14$\x0f\x87\x90\xe8\x8f\xc2\xa3\x10q\xbe\xe4\xa6\xcaa:\x0e\x90c;\xa7\xf1p\xe5\x15\x1ci\x1f\x1c\x0f\x1a\x0e\x1b\x1d\xfc\xbe1\x1a\xb5_\xfb\xff\x0d\x00PK\x07\x08tC\x13eW\x04\x00\x00k \x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xa6FO]\xbf\x95$\xab\xe8\x05\x00\x00\xdd\x14\x00\x00\n\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\x00\x00\x00\x00authz.regoUT\x05\x00\x01y\x94\xd0jPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x94q)QtC\x13eW\x04\x00\x00k \x00\x00\x0f\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81)\x06\x00\x00authz_test.regoUT\x05\x00\x01\xd8\xe2X_PK\x05\x06\x00\x00\x00\x00\x02\x00\x02\x00\x87\x00\x00\x00\xc6\n\x00\x00\x00\x00"
 	fs.RegisterWithNamespace("rego", data)
 }
@@ -35,6 +35,16 @@ func (s *Store) UpdateAdmins(admins []string) {
 	s.write("/admins", admins)
 }
 
+// UpdateAdminGroups updates the admin groups in the store.
+func (s *Store) UpdateAdminGroups(groups []string) {
+	s.write("/admin_groups", groups)
+}
+
+// UpdateAdminsOnly updates whether only admins may access routes.
+func (s *Store) UpdateAdminsOnly(adminsOnly bool) {
+	s.write("/admins_only", adminsOnly)
+}
+
 // UpdateRoutePolicies updates the route policies in the store.
 func (s *Store) UpdateRoutePolicies(routePolicies []config.Policy) {
 	s.write("/route_policies", routePolicies)
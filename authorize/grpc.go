@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/rs/zerolog"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
@@ -18,6 +20,7 @@ import (
 	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/internal/urlutil"
+	authorizegrpc "github.com/pomerium/pomerium/pkg/grpc/authorize"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/grpc/session"
 	"github.com/pomerium/pomerium/pkg/grpc/user"
@@ -37,40 +40,144 @@ func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRe
 	ctx, span := trace.StartSpan(ctx, "authorize.grpc.Check")
 	defer span.End()
 
+	start := time.Now()
 	state := a.state.Load()
 
+	// keep the original request to sign the decision for it
+	var signedReq *envoy_service_auth_v2.AttributeContext_Request
+	if a.currentOptions.Load().AuthorizeResponseSigning {
+		signedReq = proto.Clone(in.GetAttributes().GetRequest()).(*envoy_service_auth_v2.AttributeContext_Request)
+	}
+
 	// maybe rewrite http request for forward auth
 	isForwardAuth := a.handleForwardAuth(in)
+
+	// every decision is audited, including errors. The event is filled in as
+	// the request is checked, and sent once Check returns. This defer runs
+	// after the data broker data lock is released, so a slow sink doesn't
+	// hold up updates to the data.
+	evt := AuditEvent{
+		Time:     start,
+		Status:   http.StatusOK,
+		ClientIP: getClientIP(in, a.currentOptions.Load()),
+	}
+	defer func() { a.audit(ctx, evt) }()
+
+	if !isForwardAuth {
+		err := checkRequestFraming(in.GetAttributes().GetRequest().GetHttp().GetHeaders(), a.currentOptions.Load().RequestSmugglingProtection)
+		if err != nil {
+			log.Info().Err(err).Msg("authorize: rejecting request with ambiguous framing")
+			evt.Status = http.StatusBadRequest
+			res := a.deniedResponse(in, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil)
+			if signedReq != nil {
+				authorizegrpc.SignCheckResponse(a.currentOptions.Load().SharedKey, signedReq, res)
+			}
+			return res, nil
+		}
+	}
 	hreq := getHTTPRequestFromCheckRequest(in)
-	rawJWT, _ := loadRawSession(hreq, a.currentOptions.Load(), state.encoder)
-	sessionState, _ := loadSession(state.encoder, rawJWT)
+	var sessionState *sessions.State
+	// public routes never need a session, so don't bother loading one
+	if !a.isPublicRoute(getCheckRequestURL(in)) {
+		rawJWT, _ := loadRawSession(hreq, a.currentOptions.Load(), state.encoder)
+		sessionState, _ = loadSession(state.encoder, rawJWT)
+	}
+	if sessionState != nil && a.currentOptions.Load().SessionAudienceBinding && !isSessionIssuedForHost(sessionState, hreq.URL.Host) {
+		log.Info().Str("host", hreq.URL.Host).Strs("audience", sessionState.Audience).Msg("authorize: ignoring session issued for another host")
+		sessionState = nil
+	}
+	if sessionState != nil {
+		var err error
+		sessionState.CustomClaims, err = a.currentOptions.Load().GetSessionClaimsLimit().Apply(sessionState.CustomClaims)
+		if err != nil {
+			log.Info().Err(err).Str("session_id", sessionState.ID).Msg("authorize: ignoring session with too many claims")
+			sessionState = nil
+		}
+	}
 
+	syncStart := time.Now()
 	if err := a.forceSync(ctx, sessionState); err != nil {
 		log.Warn().Err(err).Msg("clearing session due to force sync failed")
 		sessionState = nil
 	}
+	syncDuration := time.Since(syncStart)
 
+	// The lock is held until the response is built, so that a session which
+	// is refreshed concurrently is seen either before or after the refresh,
+	// never partly. All identity headers sent upstream are derived from the
+	// one signed JWT, and the session cookie only references the session by
+	// id, so it's unaffected by refreshes.
 	a.dataBrokerDataLock.RLock()
 	defer a.dataBrokerDataLock.RUnlock()
 
+	if sessionState != nil && a.isSessionExpired(sessionState.ID, time.Now()) {
+		log.Info().Str("session_id", sessionState.ID).Msg("authorize: ignoring expired session")
+		sessionState = nil
+	}
+
 	req := a.getEvaluatorRequestFromCheckRequest(in, sessionState)
 	reply, err := state.evaluator.Evaluate(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("error during OPA evaluation")
+		// envoy fails the request with its status on error
+		evt.Status = http.StatusInternalServerError
 		return nil, err
 	}
-	logAuthorizeCheck(ctx, in, reply)
+	logAuthorizeCheck(ctx, in, req.HTTP.ClientIP, reply)
 
+	var res *envoy_service_auth_v2.CheckResponse
 	switch {
 	case reply.Status == http.StatusOK:
-		return a.okResponse(reply), nil
+		res = a.okResponse(reply)
+	case reply.Status == http.StatusUnauthorized && isForwardAuth:
+		res = a.deniedResponse(in, http.StatusUnauthorized, "Unauthenticated", nil)
 	case reply.Status == http.StatusUnauthorized:
-		if isForwardAuth {
-			return a.deniedResponse(in, http.StatusUnauthorized, "Unauthenticated", nil), nil
+		res = a.redirectResponse(in)
+	default:
+		details := authorizegrpc.DenialDetails{Reason: reply.Message}
+		if reply.MatchingPolicy != nil && reply.MatchingPolicy.Source != nil {
+			details.Route = reply.MatchingPolicy.Source.String()
 		}
-		return a.redirectResponse(in), nil
+		res = a.deniedResponse(in, int32(reply.Status), reply.Message,
+			getDenialDetailHeaders(a.currentOptions.Load(), details))
+		authorizegrpc.AddDenialDetails(res, details)
+	}
+	evt.Allowed = reply.Status == http.StatusOK
+	if sessionState != nil {
+		evt.Subject = sessionState.Subject
+	}
+	if reply.MatchingPolicy != nil {
+		evt.Route = reply.MatchingPolicy.String()
+	}
+	if denied := res.GetDeniedResponse(); denied != nil {
+		evt.Status = int32(denied.GetStatus().GetCode())
+	}
+
+	if a.currentOptions.Load().ServerTimingHeaders {
+		addServerTiming(res, time.Since(start), syncDuration)
+	}
+	if signedReq != nil {
+		authorizegrpc.SignCheckResponse(a.currentOptions.Load().SharedKey, signedReq, res)
+	}
+	return res, nil
+}
+
+// isSessionExpired returns true if the session has expired, according to the
+// configured session expiry source. The data broker data lock must be held.
+func (a *Authorize) isSessionExpired(sessionID string, now time.Time) bool {
+	s, ok := a.dataBrokerData.Get(sessionTypeURL, sessionID).(*session.Session)
+	if !ok {
+		return false
 	}
-	return a.deniedResponse(in, int32(reply.Status), reply.Message, nil), nil
+	var cookieExpiry, idTokenExpiry time.Time
+	if s.GetExpiresAt() != nil {
+		cookieExpiry = s.GetExpiresAt().AsTime()
+	}
+	if s.GetIdToken().GetExpiresAt() != nil {
+		idTokenExpiry = s.GetIdToken().GetExpiresAt().AsTime()
+	}
+	expiry := a.currentOptions.Load().SessionExpirySource.Expiry(cookieExpiry, idTokenExpiry)
+	return !expiry.IsZero() && !now.Before(expiry)
 }
 
 func (a *Authorize) forceSync(ctx context.Context, ss *sessions.State) error {
@@ -158,15 +265,29 @@ func (a *Authorize) forceSyncUser(ctx context.Context, userID string) *user.User
 	return u
 }
 
-func (a *Authorize) getEnvoyRequestHeaders(signedJWT string) ([]*envoy_api_v2_core.HeaderValueOption, error) {
+func (a *Authorize) getEnvoyRequestHeaders(policy *config.Policy, signedJWT string) ([]*envoy_api_v2_core.HeaderValueOption, error) {
 	var hvos []*envoy_api_v2_core.HeaderValueOption
 
-	hdrs, err := a.getJWTClaimHeaders(a.currentOptions.Load(), signedJWT)
+	opts := a.currentOptions.Load()
+	hdrs, err := a.getJWTClaimHeaders(opts, signedJWT)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil && policy.MaxInjectedHeaderBytes > 0 {
+		hdrs = limitClaimHeaders(hdrs, opts.JWTClaimsHeaders, policy)
+	}
+	userIDHdrs, err := a.getUserIDHeader(opts, signedJWT)
 	if err != nil {
 		return nil, err
 	}
-	for k, v := range hdrs {
-		hvos = append(hvos, mkHeader(k, v, false))
+	for k, vs := range userIDHdrs {
+		hdrs[k] = vs
+	}
+	for k, vs := range hdrs {
+		for i, v := range vs {
+			// replace any existing header, then append the remaining values
+			hvos = append(hvos, mkHeader(k, v, i > 0))
+		}
 	}
 
 	return hvos, nil
@@ -222,16 +343,21 @@ func (a *Authorize) getEvaluatorRequestFromCheckRequest(in *envoy_service_auth_v
 			URL:               requestURL.String(),
 			Headers:           getCheckRequestHeaders(in),
 			ClientCertificate: getPeerCertificate(in),
+			ClientIP:          getClientIP(in, a.currentOptions.Load()),
 		},
 	}
+	p := a.getMatchingPolicy(requestURL)
+	if p != nil && p.AuthorizeRequestHeaders != nil {
+		req.HTTP.Headers = filterRequestHeaders(req.HTTP.Headers, p.AuthorizeRequestHeaders)
+	}
 	if sessionState != nil {
 		req.Session = evaluator.RequestSession{
 			ID:                sessionState.ID,
 			ImpersonateEmail:  sessionState.ImpersonateEmail,
 			ImpersonateGroups: sessionState.ImpersonateGroups,
+			CustomClaims:      sessionState.CustomClaims,
 		}
 	}
-	p := a.getMatchingPolicy(requestURL)
 	if p != nil {
 		for _, sp := range p.SubPolicies {
 			req.CustomPolicies = append(req.CustomPolicies, sp.Rego...)
@@ -240,6 +366,17 @@ func (a *Authorize) getEvaluatorRequestFromCheckRequest(in *envoy_service_auth_v
 	return req
 }
 
+// isPublicRoute returns true if the request URL matches a route which allows
+// public unauthenticated access. In admins only mode no route is public, so
+// the session is always loaded.
+func (a *Authorize) isPublicRoute(requestURL *url.URL) bool {
+	if a.currentOptions.Load().AdminsOnly {
+		return false
+	}
+	p := a.getMatchingPolicy(requestURL)
+	return p != nil && p.AllowPublicUnauthenticatedAccess
+}
+
 func (a *Authorize) getMatchingPolicy(requestURL *url.URL) *config.Policy {
 	options := a.currentOptions.Load()
 
@@ -277,6 +414,25 @@ func getCheckRequestHeaders(req *envoy_service_auth_v2.CheckRequest) map[string]
 	return hdrs
 }
 
+// builtinPolicyHeaders are the headers used by the built-in authorization
+// policy, which are never filtered out.
+var builtinPolicyHeaders = []string{"Access-Control-Request-Method", "Origin"}
+
+// filterRequestHeaders returns the headers whose names are in allowed, and
+// those needed by the built-in policy.
+func filterRequestHeaders(hdrs map[string]string, allowed []string) map[string]string {
+	filtered := make(map[string]string)
+	for _, names := range [][]string{allowed, builtinPolicyHeaders} {
+		for _, name := range names {
+			k := http.CanonicalHeaderKey(name)
+			if v, ok := hdrs[k]; ok {
+				filtered[k] = v
+			}
+		}
+	}
+	return filtered
+}
+
 func getCheckRequestURL(req *envoy_service_auth_v2.CheckRequest) *url.URL {
 	h := req.GetAttributes().GetRequest().GetHttp()
 	u := &url.URL{
@@ -307,9 +463,30 @@ func getPeerCertificate(in *envoy_service_auth_v2.CheckRequest) string {
 	return cert
 }
 
+// getClientIP returns the IP of the client from the X-Forwarded-For chain
+// envoy forwarded, falling back to the address of the downstream peer. If the
+// address was taken from a PROXY protocol header sent by an untrusted peer,
+// no IP is returned.
+func getClientIP(in *envoy_service_auth_v2.CheckRequest, options *config.Options) string {
+	if isUntrustedProxyProtocolPeer(in) {
+		return ""
+	}
+	xff := in.GetAttributes().GetRequest().GetHttp().GetHeaders()["x-forwarded-for"]
+	peer := in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress()
+	return httputil.ClientIP(xff, peer, options.GetClientIPOptions())
+}
+
+// isUntrustedProxyProtocolPeer returns true if envoy's rbac filter recorded
+// that the peer which sent the PROXY protocol header isn't trusted.
+func isUntrustedProxyProtocolPeer(in *envoy_service_auth_v2.CheckRequest) bool {
+	rbac := in.GetAttributes().GetMetadataContext().GetFilterMetadata()["envoy.filters.http.rbac"]
+	return rbac.GetFields()["shadow_engine_result"].GetStringValue() == "denied"
+}
+
 func logAuthorizeCheck(
 	ctx context.Context,
 	in *envoy_service_auth_v2.CheckRequest,
+	clientIP string,
 	reply *evaluator.Result,
 ) {
 	hdrs := getCheckRequestHeaders(in)
@@ -322,6 +499,7 @@ func logAuthorizeCheck(
 	evt = evt.Str("path", hattrs.GetPath())
 	evt = evt.Str("host", hattrs.GetHost())
 	evt = evt.Str("query", hattrs.GetQuery())
+	evt = evt.Str("ip", clientIP)
 	// reply
 	if reply != nil {
 		evt = evt.Bool("allow", reply.Status == http.StatusOK)
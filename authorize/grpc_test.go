@@ -2,21 +2,29 @@ package authorize
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
 	"github.com/golang/protobuf/ptypes"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"gopkg.in/square/go-jose.v2/jwt"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/sessions"
+	authorizegrpc "github.com/pomerium/pomerium/pkg/grpc/authorize"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/grpc/session"
 	"github.com/pomerium/pomerium/pkg/grpc/user"
@@ -107,6 +115,82 @@ func Test_getEvaluatorRequest(t *testing.T) {
 	assert.Equal(t, expect, actual)
 }
 
+func Test_getEvaluatorRequestHeaderAllowlist(t *testing.T) {
+	a := &Authorize{currentOptions: config.NewAtomicOptions(), state: newAtomicAuthorizeState(new(authorizeState))}
+	a.currentOptions.Store(&config.Options{
+		Policies: []config.Policy{{
+			Source:                  &config.StringURL{URL: &url.URL{Host: "example.com"}},
+			AuthorizeRequestHeaders: []string{"x-tenant-id", "User-Agent"},
+		}},
+	})
+
+	actual := a.getEvaluatorRequestFromCheckRequest(&envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{
+			Request: &envoy_service_auth_v2.AttributeContext_Request{
+				Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+					Method: "POST",
+					Headers: map[string]string{
+						"authorization":   "Bearer secret",
+						"cookie":          "session=secret",
+						"origin":          "https://app.example.com",
+						"user-agent":      "curl/7.72.0",
+						"x-forwarded-for": "198.51.100.1",
+						"x-tenant-id":     "acme",
+					},
+					Path:   "/some/path",
+					Host:   "example.com",
+					Scheme: "https",
+				},
+			},
+		},
+	}, nil)
+	assert.Equal(t, evaluator.RequestHTTP{
+		Method: "POST",
+		URL:    "https://example.com/some/path",
+		Headers: map[string]string{
+			"Origin":      "https://app.example.com",
+			"User-Agent":  "curl/7.72.0",
+			"X-Tenant-Id": "acme",
+		},
+		ClientIP: "198.51.100.1",
+	}, actual.HTTP)
+}
+
+func Test_getClientIP(t *testing.T) {
+	newCheckRequest := func(shadowEngineResult string) *envoy_service_auth_v2.CheckRequest {
+		in := &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Source: &envoy_service_auth_v2.AttributeContext_Peer{
+					Address: &envoy_api_v2_core.Address{Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{Address: "198.51.100.1"},
+					}},
+				},
+				Request: &envoy_service_auth_v2.AttributeContext_Request{
+					Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+						Headers: map[string]string{"x-forwarded-for": "198.51.100.1"},
+					},
+				},
+			},
+		}
+		if shadowEngineResult != "" {
+			in.Attributes.MetadataContext = &envoy_api_v2_core.Metadata{
+				FilterMetadata: map[string]*structpb.Struct{
+					"envoy.filters.http.rbac": {Fields: map[string]*structpb.Value{
+						"shadow_engine_result": {Kind: &structpb.Value_StringValue{StringValue: shadowEngineResult}},
+					}},
+				},
+			}
+		}
+		return in
+	}
+
+	options := new(config.Options)
+	assert.Equal(t, "198.51.100.1", getClientIP(newCheckRequest(""), options))
+	assert.Equal(t, "198.51.100.1", getClientIP(newCheckRequest("allowed"), options))
+	assert.Equal(t, "", getClientIP(newCheckRequest("denied"), options),
+		"the address from an untrusted peer's PROXY protocol header should be ignored")
+}
+
 func Test_handleForwardAuth(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -486,3 +570,412 @@ type mockDataBrokerServiceClient struct {
 func (m mockDataBrokerServiceClient) Get(ctx context.Context, in *databroker.GetRequest, opts ...grpc.CallOption) (*databroker.GetResponse, error) {
 	return m.get(ctx, in, opts...)
 }
+
+func TestAuthorize_Check_serverTiming(t *testing.T) {
+	checkRequest := func(path string) *envoy_service_auth_v2.CheckRequest {
+		return &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Request: &envoy_service_auth_v2.AttributeContext_Request{
+					Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+						Method: "GET",
+						Path:   path,
+						Host:   "example.com",
+						Scheme: "https",
+					},
+				},
+			},
+		}
+	}
+	getHeader := func(res *envoy_service_auth_v2.CheckResponse, key string) string {
+		hdrs := res.GetOkResponse().GetHeaders()
+		if res.GetDeniedResponse() != nil {
+			hdrs = res.GetDeniedResponse().GetHeaders()
+		}
+		for _, hdr := range hdrs {
+			if hdr.GetHeader().GetKey() == key {
+				return hdr.GetHeader().GetValue()
+			}
+		}
+		return ""
+	}
+
+	for _, enabled := range []bool{true, false} {
+		opts := &config.Options{
+			AuthenticateURL:     mustParseURL("https://authenticate.example.com"),
+			DataBrokerURL:       mustParseURL("https://databroker.example.com"),
+			SharedKey:           "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+			ServerTimingHeaders: enabled,
+			Policies: []config.Policy{
+				{From: "https://example.com", To: "https://to.example.com", Prefix: "/public", AllowPublicUnauthenticatedAccess: true},
+				{From: "https://example.com", To: "https://to.example.com", AllowedUsers: []string{"admin@example.com"}},
+			},
+		}
+		for i := range opts.Policies {
+			require.NoError(t, opts.Policies[i].Validate())
+		}
+		a, err := New(&config.Config{Options: opts})
+		require.NoError(t, err)
+		a.currentOptions.Store(opts)
+
+		allowed, err := a.Check(context.Background(), checkRequest("/public"))
+		require.NoError(t, err)
+		require.NotNil(t, allowed.GetOkResponse())
+		denied, err := a.Check(context.Background(), checkRequest("/private"))
+		require.NoError(t, err)
+		require.NotNil(t, denied.GetDeniedResponse())
+
+		if !enabled {
+			assert.Empty(t, getHeader(allowed, httputil.HeaderPomeriumServerTiming))
+			assert.Empty(t, getHeader(denied, "Server-Timing"))
+			continue
+		}
+		assert.Regexp(t, `^authorize;dur=[0-9.]+, sync;dur=[0-9.]+$`, getHeader(allowed, httputil.HeaderPomeriumServerTiming))
+		assert.Regexp(t, `^authorize;dur=[0-9.]+, sync;dur=[0-9.]+$`, getHeader(denied, "Server-Timing"))
+	}
+}
+
+func TestAuthorize_Check_audit(t *testing.T) {
+	opts := &config.Options{
+		AuthenticateURL: mustParseURL("https://authenticate.example.com"),
+		DataBrokerURL:   mustParseURL("https://databroker.example.com"),
+		SharedKey:       "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+		Policies: []config.Policy{
+			{From: "https://example.com", To: "https://to.example.com", Prefix: "/public", AllowPublicUnauthenticatedAccess: true},
+			{From: "https://example.com", To: "https://to.example.com", AllowedUsers: []string{"admin@example.com"}},
+		},
+	}
+	for i := range opts.Policies {
+		require.NoError(t, opts.Policies[i].Validate())
+	}
+	a, err := New(&config.Config{Options: opts})
+	require.NoError(t, err)
+	a.currentOptions.Store(opts)
+
+	// isUnlocked reports whether the data broker data lock can be taken, so
+	// it fails rather than deadlocks if the lock is held by the caller
+	isUnlocked := func() bool {
+		done := make(chan struct{})
+		go func() {
+			a.dataBrokerDataLock.Lock()
+			a.dataBrokerDataLock.Unlock()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	var events []AuditEvent
+	a.SetAuditSink(AuditSinkFunc(func(_ context.Context, evt AuditEvent) {
+		assert.True(t, isUnlocked(), "the sink should be called after the data broker data lock is released")
+		events = append(events, evt)
+	}))
+	// the sink is kept across configuration changes
+	a.OnConfigChange(&config.Config{Options: opts})
+
+	check := func(path string, headers map[string]string) {
+		_, err := a.Check(context.Background(), &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Source: &envoy_service_auth_v2.AttributeContext_Peer{
+					Address: &envoy_api_v2_core.Address{Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{Address: "198.51.100.1"},
+					}},
+				},
+				Request: &envoy_service_auth_v2.AttributeContext_Request{
+					Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+						Method:  "GET",
+						Path:    path,
+						Host:    "example.com",
+						Scheme:  "https",
+						Headers: headers,
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+	before := time.Now()
+	check("/public", nil)
+	check("/private", nil)
+	check("/public", map[string]string{"content-length": "1, 2"})
+
+	require.Len(t, events, 3)
+	for i := range events {
+		assert.False(t, events[i].Time.Before(before))
+		events[i].Time = time.Time{}
+	}
+	assert.Equal(t, AuditEvent{
+		Route:    opts.Policies[0].String(),
+		Allowed:  true,
+		Status:   http.StatusOK,
+		ClientIP: "198.51.100.1",
+	}, events[0])
+	assert.Equal(t, AuditEvent{
+		Route:    opts.Policies[1].String(),
+		Status:   http.StatusFound,
+		ClientIP: "198.51.100.1",
+	}, events[1], "unauthenticated requests are redirected to sign in")
+	assert.Equal(t, AuditEvent{
+		Status:   http.StatusBadRequest,
+		ClientIP: "198.51.100.1",
+	}, events[2], "requests with ambiguous framing are rejected before they're evaluated")
+
+	a.SetAuditSink(nil)
+	check("/public", nil)
+	assert.Len(t, events, 3)
+}
+
+func TestAuthorize_isPublicRoute(t *testing.T) {
+	opts := &config.Options{
+		Policies: []config.Policy{
+			{From: "https://example.com", To: "https://to.example.com", Prefix: "/assets/", AllowPublicUnauthenticatedAccess: true},
+			{From: "https://example.com", To: "https://to.example.com", AllowedUsers: []string{"admin@example.com"}},
+		},
+	}
+	for i := range opts.Policies {
+		require.NoError(t, opts.Policies[i].Validate())
+	}
+	a := &Authorize{currentOptions: config.NewAtomicOptions()}
+	a.currentOptions.Store(opts)
+
+	assert.True(t, a.isPublicRoute(mustParseURL("https://example.com/assets/logo.png")))
+	assert.False(t, a.isPublicRoute(mustParseURL("https://example.com/admin")))
+	assert.False(t, a.isPublicRoute(mustParseURL("https://unknown.example.com/assets/logo.png")))
+
+	adminsOnly := *opts
+	adminsOnly.AdminsOnly = true
+	a.currentOptions.Store(&adminsOnly)
+	assert.False(t, a.isPublicRoute(mustParseURL("https://example.com/assets/logo.png")),
+		"no route is public in admins only mode")
+}
+
+func TestAuthorize_Check_signature(t *testing.T) {
+	opts := &config.Options{
+		AuthenticateURL:          mustParseURL("https://authenticate.example.com"),
+		DataBrokerURL:            mustParseURL("https://databroker.example.com"),
+		SharedKey:                "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+		AuthorizeResponseSigning: true,
+		Policies: []config.Policy{
+			{From: "https://example.com", To: "https://to.example.com", AllowPublicUnauthenticatedAccess: true},
+		},
+	}
+	require.NoError(t, opts.Policies[0].Validate())
+	a, err := New(&config.Config{Options: opts})
+	require.NoError(t, err)
+	a.currentOptions.Store(opts)
+
+	req := &envoy_service_auth_v2.AttributeContext_Request{
+		Time: ptypes.TimestampNow(),
+		Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+			Method: "GET",
+			Path:   "/",
+			Host:   "example.com",
+			Scheme: "https",
+		},
+	}
+	res, err := a.Check(context.Background(), &envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{Request: req},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, res.GetOkResponse())
+	assert.NoError(t, authorizegrpc.VerifyCheckResponse(opts.SharedKey, req, res))
+}
+
+func TestAuthorize_Check_refreshedSession(t *testing.T) {
+	opts := &config.Options{
+		AuthenticateURL:  mustParseURL("https://authenticate.example.com"),
+		DataBrokerURL:    mustParseURL("https://databroker.example.com"),
+		SharedKey:        "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+		JWTClaimsHeaders: []string{"email"},
+		Policies: []config.Policy{{
+			From:                "https://example.com",
+			To:                  "https://to.example.com",
+			AllowedDomains:      []string{"example.com"},
+			AuthorizationHeader: config.AuthorizationHeaderReplace,
+		}},
+	}
+	require.NoError(t, opts.Policies[0].Validate())
+	a, err := New(&config.Config{Options: opts})
+	require.NoError(t, err)
+	a.currentOptions.Store(opts)
+
+	rawSession, err := a.state.Load().encoder.Marshal(&sessions.State{ID: "SESSION_ID"})
+	require.NoError(t, err)
+	check := func() map[string]string {
+		res, err := a.Check(context.Background(), &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Request: &envoy_service_auth_v2.AttributeContext_Request{
+					Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+						Method: "GET",
+						Path:   "/",
+						Host:   "example.com",
+						Scheme: "https",
+						Headers: map[string]string{
+							"authorization": httputil.AuthorizationTypePomerium + " " + string(rawSession),
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, res.GetOkResponse())
+		hdrs := map[string]string{}
+		for _, h := range res.GetOkResponse().GetHeaders() {
+			hdrs[h.GetHeader().GetKey()] = h.GetHeader().GetValue()
+		}
+		return hdrs
+	}
+	jwtEmail := func(signedJWT string) string {
+		payload, err := a.state.Load().evaluator.ParseSignedJWT(signedJWT)
+		require.NoError(t, err)
+		var claims struct {
+			Email string `json:"email"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &claims))
+		return claims.Email
+	}
+	setUser := func(email string) {
+		a.dataBrokerDataLock.Lock()
+		a.dataBrokerData = evaluator.DataBrokerData{
+			sessionTypeURL: map[string]interface{}{
+				"SESSION_ID": &session.Session{Id: "SESSION_ID", UserId: "user1"},
+			},
+			userTypeURL: map[string]interface{}{
+				"user1": &user.User{Id: "user1", Email: email},
+			},
+		}
+		a.dataBrokerDataLock.Unlock()
+	}
+
+	for _, email := range []string{"before@example.com", "after@example.com"} {
+		setUser(email)
+		hdrs := check()
+		signedJWT := hdrs[httputil.HeaderPomeriumJWTAssertion]
+		assert.Equal(t, email, jwtEmail(signedJWT))
+		assert.Equal(t, email, hdrs["x-pomerium-claim-email"],
+			"claim headers should come from the forwarded jwt")
+		assert.Equal(t, "Bearer "+signedJWT, hdrs["Authorization"],
+			"the authorization header should carry the forwarded jwt")
+		assert.NotContains(t, hdrs, "Set-Cookie",
+			"the session cookie only references the session, so isn't replaced on refresh")
+	}
+}
+
+func TestAuthorize_Check_sessionAudienceBinding(t *testing.T) {
+	opts := &config.Options{
+		AuthenticateURL: mustParseURL("https://authenticate.example.com"),
+		DataBrokerURL:   mustParseURL("https://databroker.example.com"),
+		SharedKey:       "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+		Policies: []config.Policy{{
+			From:           "https://example.com",
+			To:             "https://to.example.com",
+			AllowedDomains: []string{"example.com"},
+		}},
+	}
+	require.NoError(t, opts.Policies[0].Validate())
+	a, err := New(&config.Config{Options: opts})
+	require.NoError(t, err)
+
+	a.dataBrokerDataLock.Lock()
+	a.dataBrokerData = evaluator.DataBrokerData{
+		sessionTypeURL: map[string]interface{}{
+			"SESSION_ID": &session.Session{Id: "SESSION_ID", UserId: "user1"},
+		},
+		userTypeURL: map[string]interface{}{
+			"user1": &user.User{Id: "user1", Email: "user1@example.com"},
+		},
+	}
+	a.dataBrokerDataLock.Unlock()
+
+	check := func(audience string) *envoy_service_auth_v2.CheckResponse {
+		rawSession, err := a.state.Load().encoder.Marshal(&sessions.State{
+			ID:       "SESSION_ID",
+			Audience: jwt.Audience{audience},
+		})
+		require.NoError(t, err)
+		res, err := a.Check(context.Background(), &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Request: &envoy_service_auth_v2.AttributeContext_Request{
+					Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+						Method: "GET",
+						Path:   "/",
+						Host:   "example.com",
+						Scheme: "https",
+						Headers: map[string]string{
+							"authorization": httputil.AuthorizationTypePomerium + " " + string(rawSession),
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		opts.SessionAudienceBinding = false
+		a.currentOptions.Store(opts)
+		assert.NotNil(t, check("example.com").GetOkResponse())
+		assert.NotNil(t, check("other.example.com").GetOkResponse())
+	})
+	t.Run("enabled", func(t *testing.T) {
+		opts.SessionAudienceBinding = true
+		a.currentOptions.Store(opts)
+		assert.NotNil(t, check("example.com").GetOkResponse())
+		assert.NotNil(t, check("example.com:443").GetOkResponse(), "ports should be ignored")
+		assert.Nil(t, check("other.example.com").GetOkResponse(),
+			"a session issued for another host should be ignored")
+	})
+}
+
+func TestAuthorize_isSessionExpired(t *testing.T) {
+	now := time.Now()
+	ts := func(d time.Duration) *timestamp.Timestamp {
+		pbts, _ := ptypes.TimestampProto(now.Add(d))
+		return pbts
+	}
+
+	tests := []struct {
+		name          string
+		src           config.SessionExpirySource
+		cookieExpiry  *timestamp.Timestamp
+		idTokenExpiry *timestamp.Timestamp
+		at            time.Duration
+		want          bool
+	}{
+		{"default before both", "", ts(14 * time.Hour), ts(time.Hour), 30 * time.Minute, false},
+		{"default cookie outlives id token", "", ts(14 * time.Hour), ts(time.Hour), 2 * time.Hour, true},
+		{"default id token outlives cookie", "", ts(time.Hour), ts(14 * time.Hour), 2 * time.Hour, true},
+		{"id token expired", config.SessionExpirySourceIDToken, ts(14 * time.Hour), ts(time.Hour), 2 * time.Hour, true},
+		{"id token outlives cookie", config.SessionExpirySourceIDToken, ts(time.Hour), ts(14 * time.Hour), 2 * time.Hour, false},
+		{"no expiry", "", nil, nil, 2 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &config.Options{
+				AuthenticateURL:     mustParseURL("https://authenticate.example.com"),
+				DataBrokerURL:       mustParseURL("https://databroker.example.com"),
+				SharedKey:           "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+				SessionExpirySource: tt.src,
+			}
+			a, err := New(&config.Config{Options: opts})
+			require.NoError(t, err)
+			a.currentOptions.Store(opts)
+			a.dataBrokerData = evaluator.DataBrokerData{
+				sessionTypeURL: {
+					"s1": &session.Session{
+						Id:        "s1",
+						ExpiresAt: tt.cookieExpiry,
+						IdToken:   &session.IDToken{ExpiresAt: tt.idTokenExpiry},
+					},
+				},
+			}
+
+			assert.Equal(t, tt.want, a.isSessionExpired("s1", now.Add(tt.at)))
+			assert.False(t, a.isSessionExpired("unknown", now.Add(tt.at)))
+		})
+	}
+}
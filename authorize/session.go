@@ -1,21 +1,24 @@
 package authorize
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"strings"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/internal/sessions/cookie"
 	"github.com/pomerium/pomerium/internal/sessions/header"
 	"github.com/pomerium/pomerium/internal/sessions/queryparam"
+	"github.com/pomerium/pomerium/internal/sessions/subprotocol"
 	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
 )
 
 func loadRawSession(req *http.Request, options *config.Options, encoder encoding.MarshalUnmarshaler) ([]byte, error) {
@@ -27,8 +30,19 @@ func loadRawSession(req *http.Request, options *config.Options, encoder encoding
 	loaders = append(loaders,
 		cookieStore,
 		header.NewStore(encoder, httputil.AuthorizationTypePomerium),
-		queryparam.NewStore(encoder, urlutil.QuerySession),
+		queryparam.NewStore(encoder, urlutil.QuerySession).SafeMethodsOnly(!options.QueryParamSessionAllMethods),
 	)
+	if options.WebSocketSubprotocolSession {
+		loaders = append(loaders, subprotocol.NewStore(httputil.WebSocketProtocolPomerium))
+	}
+
+	if options.ConcurrentSessionLoaders {
+		sess, err := sessions.LoadConcurrently(req, loaders...)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(sess), nil
+	}
 
 	for _, loader := range loaders {
 		sess, err := loader.LoadSession(req)
@@ -51,6 +65,18 @@ func loadSession(encoder encoding.MarshalUnmarshaler, rawJWT []byte) (*sessions.
 	return &s, nil
 }
 
+// isSessionIssuedForHost reports whether host is one of the audiences the
+// session was issued for. Ports are ignored.
+func isSessionIssuedForHost(s *sessions.State, host string) bool {
+	host = urlutil.StripPort(host)
+	for _, aud := range s.Audience {
+		if urlutil.StripPort(aud) == host {
+			return true
+		}
+	}
+	return false
+}
+
 func getCookieStore(options *config.Options, encoder encoding.MarshalUnmarshaler) (sessions.SessionStore, error) {
 	cookieStore, err := cookie.NewStore(func() cookie.Options {
 		return cookie.Options{
@@ -59,6 +85,8 @@ func getCookieStore(options *config.Options, encoder encoding.MarshalUnmarshaler
 			Secure:   options.CookieSecure,
 			HTTPOnly: options.CookieHTTPOnly,
 			Expire:   options.CookieExpire,
+
+			SizeWarningThreshold: options.SessionSizeWarningThreshold,
 		}
 	}, encoder)
 	if err != nil {
@@ -86,9 +114,9 @@ func getJWTSetCookieHeaders(cookieStore sessions.SessionStore, rawjwt []byte) (m
 	return hdrs, nil
 }
 
-func (a *Authorize) getJWTClaimHeaders(options *config.Options, signedJWT string) (map[string]string, error) {
+func (a *Authorize) getJWTClaimHeaders(options *config.Options, signedJWT string) (map[string][]string, error) {
 	if len(signedJWT) == 0 {
-		return make(map[string]string), nil
+		return make(map[string][]string), nil
 	}
 
 	state := a.state.Load()
@@ -102,24 +130,96 @@ func (a *Authorize) getJWTClaimHeaders(options *config.Options, signedJWT string
 		return nil, err
 	}
 
-	hdrs := make(map[string]string)
+	hdrs := make(map[string][]string)
 	for _, name := range options.JWTClaimsHeaders {
 		if claim, ok := claims[name]; ok {
 			switch value := claim.(type) {
 			case string:
-				hdrs["x-pomerium-claim-"+name] = value
+				hdrs["x-pomerium-claim-"+name] = []string{value}
 			case []interface{}:
-				hdrs["x-pomerium-claim-"+name] = strings.Join(toSliceStrings(value), ",")
+				hdrs["x-pomerium-claim-"+name] = options.JWTClaimsHeadersFormat.FormatValues(value)
 			}
 		}
 	}
 	return hdrs, nil
 }
 
-func toSliceStrings(sliceIfaces []interface{}) []string {
-	sliceStrings := make([]string, 0, len(sliceIfaces))
-	for _, e := range sliceIfaces {
-		sliceStrings = append(sliceStrings, fmt.Sprint(e))
+// getUserIDHeader returns the configured user id header, whose value is a
+// keyed hash of the JWT's subject, or nil if it's disabled. Without a subject
+// the header is still returned, but empty, so that any value sent by the
+// client is overwritten.
+func (a *Authorize) getUserIDHeader(options *config.Options, signedJWT string) (map[string][]string, error) {
+	if options.UserIDHeader == "" {
+		return nil, nil
+	}
+	if len(signedJWT) == 0 {
+		return map[string][]string{options.UserIDHeader: {""}}, nil
+	}
+
+	state := a.state.Load()
+
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	payload, err := state.evaluator.ParseSignedJWT(signedJWT)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return map[string][]string{options.UserIDHeader: {""}}, nil
+	}
+	return map[string][]string{
+		options.UserIDHeader: {hashUserID(options.UserIDHeaderSalt, claims.Subject)},
+	}, nil
+}
+
+// hashUserID derives a stable identifier for the subject that doesn't reveal
+// it without knowing the salt.
+func hashUserID(salt, subject string) string {
+	return hex.EncodeToString(cryptutil.GenerateHMAC([]byte(subject), salt))
+}
+
+// limitClaimHeaders keeps the total size of the claim headers within the
+// policy's MaxInjectedHeaderBytes. The policy's priority claims are kept first,
+// then the remaining claims in the order they were configured. Claims that
+// don't fit are dropped.
+func limitClaimHeaders(hdrs map[string][]string, claimNames []string, policy *config.Policy) map[string][]string {
+	names := make([]string, 0, len(policy.PriorityClaimHeaders)+len(claimNames))
+	names = append(names, policy.PriorityClaimHeaders...)
+	names = append(names, claimNames...)
+
+	limited := make(map[string][]string, len(hdrs))
+	var size int
+	var dropped []string
+	for _, name := range names {
+		k := "x-pomerium-claim-" + name
+		vs, ok := hdrs[k]
+		if !ok {
+			continue
+		}
+		if _, ok := limited[k]; ok {
+			continue
+		}
+		var sz int
+		for _, v := range vs {
+			sz += len(k) + len(v)
+		}
+		if size+sz > policy.MaxInjectedHeaderBytes {
+			dropped = append(dropped, name)
+			continue
+		}
+		size += sz
+		limited[k] = vs
+	}
+	if len(dropped) > 0 {
+		log.Warn().
+			Str("route", policy.String()).
+			Int("max_injected_header_bytes", policy.MaxInjectedHeaderBytes).
+			Strs("dropped", dropped).
+			Msg("authorize: dropped claim headers over the route's size budget")
 	}
-	return sliceStrings
+	return limited
 }
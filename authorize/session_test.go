@@ -3,8 +3,10 @@ package authorize
 import (
 	"net/url"
 	"regexp"
+	"sort"
 	"testing"
 
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -104,6 +106,48 @@ func TestLoadSession(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotNil(t, sess)
 	})
+	t.Run("query param on post", func(t *testing.T) {
+		hattrs := &envoy_service_auth_v2.AttributeContext_HttpRequest{
+			Id:     "req-1",
+			Method: "POST",
+			Path: "/hello/world?" + url.Values{
+				"pomerium_session": []string{string(rawjwt)},
+			}.Encode(),
+			Host:   "example.com",
+			Scheme: "https",
+		}
+		sess, err := load(t, hattrs)
+		assert.Error(t, err)
+		assert.Nil(t, sess)
+
+		opts.QueryParamSessionAllMethods = true
+		defer func() { opts.QueryParamSessionAllMethods = false }()
+		sess, err = load(t, hattrs)
+		assert.NoError(t, err)
+		assert.NotNil(t, sess)
+	})
+	t.Run("websocket subprotocol", func(t *testing.T) {
+		hattrs := &envoy_service_auth_v2.AttributeContext_HttpRequest{
+			Id:     "req-1",
+			Method: "GET",
+			Headers: map[string]string{
+				"Connection":             "Upgrade",
+				"Upgrade":                "websocket",
+				"Sec-WebSocket-Protocol": "graphql-ws, pomerium.session." + string(rawjwt),
+			},
+			Path:   "/graphql",
+			Host:   "example.com",
+			Scheme: "https",
+		}
+		_, err := load(t, hattrs)
+		assert.Equal(t, sessions.ErrNoSessionFound, err, "should be ignored unless enabled")
+
+		opts.WebSocketSubprotocolSession = true
+		defer func() { opts.WebSocketSubprotocolSession = false }()
+		sess, err := load(t, hattrs)
+		assert.NoError(t, err)
+		assert.Equal(t, "xyz", sess.ID)
+	})
 }
 
 func TestAuthorize_getJWTClaimHeaders(t *testing.T) {
@@ -157,7 +201,8 @@ func TestAuthorize_getJWTClaimHeaders(t *testing.T) {
 		},
 		HTTP: evaluator.RequestHTTP{URL: "https://example.com"},
 		Session: evaluator.RequestSession{
-			ID: "SESSION_ID",
+			ID:           "SESSION_ID",
+			CustomClaims: map[string]string{"tenant": "acme"},
 		},
 	}))
 
@@ -165,19 +210,131 @@ func TestAuthorize_getJWTClaimHeaders(t *testing.T) {
 		name            string
 		signedJWT       string
 		jwtHeaders      []string
-		expectedHeaders map[string]string
+		format          config.ClaimHeaderFormat
+		expectedHeaders map[string][]string
 	}{
-		{"good with email", signedJWT, []string{"email"}, map[string]string{"x-pomerium-claim-email": "foo@example.com"}},
-		{"good with groups", signedJWT, []string{"groups"}, map[string]string{"x-pomerium-claim-groups": "admin_id,test_id,admin,test"}},
-		{"empty signed JWT", "", nil, make(map[string]string)},
+		{"good with email", signedJWT, []string{"email"}, "", map[string][]string{"x-pomerium-claim-email": {"foo@example.com"}}},
+		{"good with groups", signedJWT, []string{"groups"}, "", map[string][]string{"x-pomerium-claim-groups": {"admin_id,test_id,admin,test"}}},
+		{"good with custom claim", signedJWT, []string{"tenant"}, "", map[string][]string{"x-pomerium-claim-tenant": {"acme"}}},
+		{"groups as csv", signedJWT, []string{"groups"}, config.ClaimHeaderFormatCSV, map[string][]string{"x-pomerium-claim-groups": {"admin_id,test_id,admin,test"}}},
+		{"groups as json", signedJWT, []string{"groups"}, config.ClaimHeaderFormatJSON, map[string][]string{"x-pomerium-claim-groups": {`["admin_id","test_id","admin","test"]`}}},
+		{"groups as repeated headers", signedJWT, []string{"groups"}, config.ClaimHeaderFormatRepeated, map[string][]string{"x-pomerium-claim-groups": {"admin_id", "test_id", "admin", "test"}}},
+		{"single value as repeated headers", signedJWT, []string{"email"}, config.ClaimHeaderFormatRepeated, map[string][]string{"x-pomerium-claim-email": {"foo@example.com"}}},
+		{"empty signed JWT", "", nil, "", make(map[string][]string)},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			opt.JWTClaimsHeaders = tc.jwtHeaders
+			opt.JWTClaimsHeadersFormat = tc.format
 			gotHeaders, err := a.getJWTClaimHeaders(opt, tc.signedJWT)
 			require.NoError(t, err)
 			assert.Equal(t, tc.expectedHeaders, gotHeaders)
 		})
 	}
+
+	t.Run("repeated envoy headers", func(t *testing.T) {
+		opt.JWTClaimsHeaders = []string{"groups"}
+		opt.JWTClaimsHeadersFormat = config.ClaimHeaderFormatRepeated
+		hvos, err := a.getEnvoyRequestHeaders(nil, signedJWT)
+		require.NoError(t, err)
+		assert.Equal(t, []*envoy_api_v2_core.HeaderValueOption{
+			mkHeader("x-pomerium-claim-groups", "admin_id", false),
+			mkHeader("x-pomerium-claim-groups", "test_id", true),
+			mkHeader("x-pomerium-claim-groups", "admin", true),
+			mkHeader("x-pomerium-claim-groups", "test", true),
+		}, hvos)
+	})
+
+	t.Run("route header budget", func(t *testing.T) {
+		opt.JWTClaimsHeaders = []string{"email", "groups", "tenant"}
+		opt.JWTClaimsHeadersFormat = ""
+		headerNames := func(policy *config.Policy) []string {
+			hvos, err := a.getEnvoyRequestHeaders(policy, signedJWT)
+			require.NoError(t, err)
+			var names []string
+			for _, hvo := range hvos {
+				names = append(names, hvo.GetHeader().GetKey())
+			}
+			sort.Strings(names)
+			return names
+		}
+
+		assert.Equal(t, []string{"x-pomerium-claim-email", "x-pomerium-claim-groups", "x-pomerium-claim-tenant"},
+			headerNames(&config.Policy{}), "unbudgeted routes should keep every claim")
+		assert.Equal(t, []string{"x-pomerium-claim-email"},
+			headerNames(&config.Policy{MaxInjectedHeaderBytes: 40}), "claims should be kept in configured order")
+		assert.Equal(t, []string{"x-pomerium-claim-tenant"},
+			headerNames(&config.Policy{MaxInjectedHeaderBytes: 40, PriorityClaimHeaders: []string{"tenant"}}),
+			"priority claims should be kept first")
+		assert.Equal(t, []string{"x-pomerium-claim-email", "x-pomerium-claim-tenant"},
+			headerNames(&config.Policy{MaxInjectedHeaderBytes: 70, PriorityClaimHeaders: []string{"tenant"}}))
+	})
+}
+
+func TestAuthorize_getUserIDHeader(t *testing.T) {
+	opt := &config.Options{
+		AuthenticateURL: mustParseURL("https://authenticate.example.com"),
+		Policies: []config.Policy{{
+			Source: &config.StringURL{URL: &url.URL{Host: "example.com"}},
+			SubPolicies: []config.SubPolicy{{
+				Rego: []string{"allow = true"},
+			}},
+		}},
+		UserIDHeader:     "X-User-Id",
+		UserIDHeaderSalt: "salt",
+	}
+	a := &Authorize{currentOptions: config.NewAtomicOptions(), state: newAtomicAuthorizeState(new(authorizeState))}
+	a.currentOptions.Store(opt)
+	a.store = evaluator.NewStore()
+	pe, err := newPolicyEvaluator(opt, a.store)
+	require.NoError(t, err)
+	a.state.Load().evaluator = pe
+
+	userIDHeader := func(opt *config.Options, subject string) string {
+		signedJWT, err := pe.SignedJWT(map[string]interface{}{"sub": subject, "email": subject + "@example.com"})
+		require.NoError(t, err)
+		hdrs, err := a.getUserIDHeader(opt, signedJWT)
+		require.NoError(t, err)
+		return hdrs["X-User-Id"][0]
+	}
+
+	alice := userIDHeader(opt, "alice")
+	assert.Regexp(t, "^[0-9a-f]{64}$", alice)
+	assert.NotContains(t, alice, "alice")
+	assert.Equal(t, alice, userIDHeader(opt, "alice"), "same subject should have the same id")
+	assert.NotEqual(t, alice, userIDHeader(opt, "bob"), "different subjects should have different ids")
+
+	resalted := *opt
+	resalted.UserIDHeaderSalt = "pepper"
+	assert.NotEqual(t, alice, userIDHeader(&resalted, "alice"), "changing the salt should change the id")
+
+	hdrs, err := a.getUserIDHeader(opt, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"X-User-Id": {""}}, hdrs, "a spoofed header should be overwritten without a subject")
+
+	disabled := *opt
+	disabled.UserIDHeader = ""
+	hdrs, err = a.getUserIDHeader(&disabled, "")
+	assert.NoError(t, err)
+	assert.Empty(t, hdrs)
+}
+
+func TestIsSessionIssuedForHost(t *testing.T) {
+	s := &sessions.State{Audience: []string{"authenticate.example.com", "app-a.example.com:8443"}}
+
+	tests := []struct {
+		name string
+		host string
+		want bool
+	}{
+		{"issuing host", "app-a.example.com", true},
+		{"issuing host with port", "app-a.example.com:443", true},
+		{"other host", "app-b.example.com", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isSessionIssuedForHost(s, tc.host))
+		})
+	}
 }
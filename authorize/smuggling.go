@@ -0,0 +1,54 @@
+package authorize
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+var (
+	errConflictingFraming       = errors.New("request has both content-length and transfer-encoding headers")
+	errDuplicateContentLength   = errors.New("request has more than one content-length")
+	errConflictingContentLength = errors.New("request has conflicting content-length values")
+)
+
+// checkRequestFraming rejects requests whose framing headers are ambiguous
+// and could be interpreted differently by the upstream, which is the basis
+// of request smuggling. Envoy passes repeated headers joined by commas.
+func checkRequestFraming(headers map[string]string, protection config.RequestSmugglingProtection) error {
+	if protection == config.RequestSmugglingProtectionOff {
+		return nil
+	}
+
+	var contentLength string
+	var hasContentLength, hasTransferEncoding bool
+	for k, v := range headers {
+		switch strings.ToLower(k) {
+		case "content-length":
+			contentLength, hasContentLength = v, true
+		case "transfer-encoding":
+			hasTransferEncoding = true
+		}
+	}
+	if !hasContentLength {
+		return nil
+	}
+
+	values := strings.Split(contentLength, ",")
+	for _, v := range values[1:] {
+		if strings.TrimSpace(v) != strings.TrimSpace(values[0]) {
+			return errConflictingContentLength
+		}
+	}
+	if protection == config.RequestSmugglingProtectionLenient {
+		return nil
+	}
+	if len(values) > 1 {
+		return errDuplicateContentLength
+	}
+	if hasTransferEncoding {
+		return errConflictingFraming
+	}
+	return nil
+}
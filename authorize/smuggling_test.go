@@ -0,0 +1,84 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func Test_checkRequestFraming(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		strictErr  error
+		lenientErr error
+	}{
+		{"no body", map[string]string{}, nil, nil},
+		{"content length", map[string]string{"content-length": "10"}, nil, nil},
+		{"chunked", map[string]string{"transfer-encoding": "chunked"}, nil, nil},
+		{"content length and chunked", map[string]string{"content-length": "10", "transfer-encoding": "chunked"}, errConflictingFraming, nil},
+		{"duplicate content length", map[string]string{"content-length": "10,10"}, errDuplicateContentLength, nil},
+		{"conflicting content length", map[string]string{"content-length": "10, 5"}, errConflictingContentLength, errConflictingContentLength},
+		{"mixed case", map[string]string{"Content-Length": "10", "Transfer-Encoding": "chunked"}, errConflictingFraming, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.strictErr, checkRequestFraming(tt.headers, ""))
+			assert.Equal(t, tt.strictErr, checkRequestFraming(tt.headers, config.RequestSmugglingProtectionStrict))
+			assert.Equal(t, tt.lenientErr, checkRequestFraming(tt.headers, config.RequestSmugglingProtectionLenient))
+			assert.NoError(t, checkRequestFraming(tt.headers, config.RequestSmugglingProtectionOff))
+		})
+	}
+}
+
+func TestAuthorize_Check_requestFraming(t *testing.T) {
+	opts := &config.Options{
+		AuthenticateURL: mustParseURL("https://authenticate.example.com"),
+		DataBrokerURL:   mustParseURL("https://databroker.example.com"),
+		SharedKey:       "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w=",
+		Policies: []config.Policy{
+			{From: "https://example.com", To: "https://to.example.com", AllowPublicUnauthenticatedAccess: true},
+		},
+	}
+	for i := range opts.Policies {
+		require.NoError(t, opts.Policies[i].Validate())
+	}
+	a, err := New(&config.Config{Options: opts})
+	require.NoError(t, err)
+	a.currentOptions.Store(opts)
+
+	check := func(headers map[string]string) *envoy_service_auth_v2.CheckResponse {
+		res, err := a.Check(context.Background(), &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Request: &envoy_service_auth_v2.AttributeContext_Request{
+					Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+						Method:  "POST",
+						Path:    "/",
+						Host:    "example.com",
+						Scheme:  "https",
+						Headers: headers,
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	normal := check(map[string]string{"content-length": "10"})
+	assert.NotNil(t, normal.GetOkResponse())
+
+	smuggled := check(map[string]string{"content-length": "10", "transfer-encoding": "chunked"})
+	require.NotNil(t, smuggled.GetDeniedResponse())
+	assert.Equal(t, http.StatusBadRequest, int(smuggled.GetDeniedResponse().GetStatus().GetCode()))
+}
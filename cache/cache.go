@@ -141,6 +141,11 @@ func (c *Cache) update(cfg *config.Config) error {
 		manager.WithDataBrokerClient(dataBrokerClient),
 		manager.WithGroupRefreshInterval(cfg.Options.RefreshDirectoryInterval),
 		manager.WithGroupRefreshTimeout(cfg.Options.RefreshDirectoryTimeout),
+		manager.WithClaimsLimit(cfg.Options.GetSessionClaimsLimit()),
+		manager.WithSessionExpiryFromIDToken(cfg.Options.SessionExpirySource == config.SessionExpirySourceIDToken),
+		manager.WithRefreshTokenLimit(cfg.Options.MaxRefreshTokenBytes,
+			cfg.Options.RefreshTokenLimitAction == config.RefreshTokenLimitActionDiscard),
+		manager.WithSessionNonce(cfg.Options.SessionNonce),
 	}
 
 	if c.manager == nil {
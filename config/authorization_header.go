@@ -0,0 +1,29 @@
+package config
+
+import "fmt"
+
+// An AuthorizationHeaderMode determines what happens to the Authorization
+// header of requests before they are sent to a route's upstream.
+type AuthorizationHeaderMode string
+
+// AuthorizationHeaderMode values.
+const (
+	// AuthorizationHeaderForward forwards the client's Authorization header
+	// unchanged.
+	AuthorizationHeaderForward AuthorizationHeaderMode = "forward"
+	// AuthorizationHeaderStrip removes the Authorization header.
+	AuthorizationHeaderStrip AuthorizationHeaderMode = "strip"
+	// AuthorizationHeaderReplace replaces the Authorization header with a
+	// bearer token containing pomerium's signed JWT assertion.
+	AuthorizationHeaderReplace AuthorizationHeaderMode = "replace"
+)
+
+// Validate checks that the mode is known. The empty mode is treated as
+// AuthorizationHeaderForward.
+func (m AuthorizationHeaderMode) Validate() error {
+	switch m {
+	case "", AuthorizationHeaderForward, AuthorizationHeaderStrip, AuthorizationHeaderReplace:
+		return nil
+	}
+	return fmt.Errorf("unknown authorization header mode %q", m)
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A ClaimHeaderFormat determines how claims with multiple values, such as
+// groups, are formatted as X-Pomerium-Claim-* headers.
+type ClaimHeaderFormat string
+
+// ClaimHeaderFormat values.
+const (
+	// ClaimHeaderFormatCSV joins the values with commas, e.g. `a,b`.
+	ClaimHeaderFormatCSV ClaimHeaderFormat = "csv"
+	// ClaimHeaderFormatJSON formats the values as a JSON array, e.g. `["a","b"]`.
+	ClaimHeaderFormatJSON ClaimHeaderFormat = "json"
+	// ClaimHeaderFormatRepeated adds a separate header for each value.
+	ClaimHeaderFormatRepeated ClaimHeaderFormat = "repeated"
+)
+
+// Validate checks that the format is known. The empty format is treated as
+// ClaimHeaderFormatCSV.
+func (f ClaimHeaderFormat) Validate() error {
+	switch f {
+	case "", ClaimHeaderFormatCSV, ClaimHeaderFormatJSON, ClaimHeaderFormatRepeated:
+		return nil
+	}
+	return fmt.Errorf("unknown claim header format %q", f)
+}
+
+// FormatValues returns the header values for a claim with multiple values.
+func (f ClaimHeaderFormat) FormatValues(values []interface{}) []string {
+	switch f {
+	case ClaimHeaderFormatJSON:
+		bs, err := json.Marshal(values)
+		if err == nil {
+			return []string{string(bs)}
+		}
+	case ClaimHeaderFormatRepeated:
+		return claimValueStrings(values)
+	}
+	return []string{strings.Join(claimValueStrings(values), ",")}
+}
+
+// A ClaimsLimitAction determines what happens to sessions whose claims exceed
+// max_session_claims or max_session_claims_bytes.
+type ClaimsLimitAction string
+
+// ClaimsLimitAction values.
+const (
+	// ClaimsLimitActionTruncate drops the lowest priority claims.
+	ClaimsLimitActionTruncate ClaimsLimitAction = "truncate"
+	// ClaimsLimitActionReject rejects the session.
+	ClaimsLimitActionReject ClaimsLimitAction = "reject"
+)
+
+// Validate checks that the action is known. The empty action is treated as
+// ClaimsLimitActionTruncate.
+func (a ClaimsLimitAction) Validate() error {
+	switch a {
+	case "", ClaimsLimitActionTruncate, ClaimsLimitActionReject:
+		return nil
+	}
+	return fmt.Errorf("unknown claims limit action %q", a)
+}
+
+func claimValueStrings(values []interface{}) []string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, fmt.Sprint(v))
+	}
+	return strs
+}
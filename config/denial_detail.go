@@ -0,0 +1,24 @@
+package config
+
+import "fmt"
+
+// A DenialDetail is a detail of an authorize denial which may be surfaced to
+// users in the responses to denied requests.
+type DenialDetail string
+
+// DenialDetail values.
+const (
+	// DenialDetailReason is the reason the request was denied.
+	DenialDetailReason DenialDetail = "reason"
+	// DenialDetailRoute is the route whose policy denied the request.
+	DenialDetailRoute DenialDetail = "route"
+)
+
+// Validate checks that the detail is known.
+func (d DenialDetail) Validate() error {
+	switch d {
+	case DenialDetailReason, DenialDetailRoute:
+		return nil
+	}
+	return fmt.Errorf("unknown denial detail %q", d)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// A LoadBalancingPolicy determines how requests to a route are distributed
+// across its upstreams.
+type LoadBalancingPolicy string
+
+// LoadBalancingPolicy values.
+const (
+	// LoadBalancingRoundRobin sends requests to each upstream in turn,
+	// in proportion to their weights.
+	LoadBalancingRoundRobin LoadBalancingPolicy = "round_robin"
+	// LoadBalancingRandom sends requests to randomly chosen upstreams, in
+	// proportion to their weights.
+	LoadBalancingRandom LoadBalancingPolicy = "random"
+	// LoadBalancingLeastRequest sends requests to the upstream with the
+	// fewest active requests, relative to their weights.
+	LoadBalancingLeastRequest LoadBalancingPolicy = "least_request"
+)
+
+// Validate checks that the policy is known. The empty policy is treated as
+// LoadBalancingRoundRobin.
+func (p LoadBalancingPolicy) Validate() error {
+	switch p {
+	case "", LoadBalancingRoundRobin, LoadBalancingRandom, LoadBalancingLeastRequest:
+		return nil
+	}
+	return fmt.Errorf("unknown load balancing policy %q", p)
+}
+
+// A WeightedUpstream is one of the upstreams requests to a route are load
+// balanced across.
+type WeightedUpstream struct {
+	URL string `mapstructure:"url" yaml:"url" json:"url"`
+	// Weight is the upstream's share of requests relative to the other
+	// upstreams. Zero is treated as one.
+	Weight uint32 `mapstructure:"weight" yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	Destination *url.URL `yaml:",omitempty" json:"destination,omitempty" hash:"ignore"`
+}
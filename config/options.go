@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -26,8 +27,10 @@ import (
 	"github.com/pomerium/pomerium/internal/directory/google"
 	"github.com/pomerium/pomerium/internal/directory/okta"
 	"github.com/pomerium/pomerium/internal/directory/onelogin"
+	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/identity/oauth"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/internal/telemetry"
 	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/internal/urlutil"
@@ -115,6 +118,16 @@ type Options struct {
 	// SignOutRedirectURL represents the url that  user will be redirected to after signing out.
 	SignOutRedirectURLString string   `mapstructure:"signout_redirect_url" yaml:"signout_redirect_url,omitempty"`
 	SignOutRedirectURL       *url.URL `yaml:"-,omitempty"`
+	// SignOutRedirectAllowedHosts are added to the RedirectAllowlist.
+	//
+	// Deprecated: use RedirectAllowlist instead.
+	SignOutRedirectAllowedHosts []string `mapstructure:"signout_redirect_allowed_hosts" yaml:"signout_redirect_allowed_hosts,omitempty"`
+	// RedirectAllowlist restricts the targets users may be redirected to after
+	// signing in or out. Entries are hosts, or scheme://host to also restrict
+	// the scheme. Entries without a port match any port. The authenticate
+	// service, forward auth, routes and the signout redirect url are always
+	// allowed. If empty, any http or https url is allowed.
+	RedirectAllowlist []string `mapstructure:"redirect_allowlist" yaml:"redirect_allowlist,omitempty"`
 
 	// AuthenticateCallbackPath is the path to the HTTP endpoint that will
 	// receive the response from your identity provider. The value must exactly
@@ -131,6 +144,46 @@ type Options struct {
 	CookieHTTPOnly bool          `mapstructure:"cookie_http_only" yaml:"cookie_http_only,omitempty"`
 	CookieExpire   time.Duration `mapstructure:"cookie_expire" yaml:"cookie_expire,omitempty"`
 
+	// SessionExpirySource determines whether sessions expire at the earlier
+	// of the cookie expiry and the identity provider token's expiry (the
+	// default), or only at the latter.
+	SessionExpirySource SessionExpirySource `mapstructure:"session_expiry_source" yaml:"session_expiry_source,omitempty"`
+
+	// SessionNonce makes the cache service's identity manager delete a user's
+	// earlier sessions from the databroker when they sign in again, so only
+	// the most recent sign-in stays valid.
+	SessionNonce bool `mapstructure:"session_nonce" yaml:"session_nonce,omitempty"`
+
+	// SessionAudienceBinding only accepts a session on the hosts it was issued
+	// for, even if the session cookie is shared with other hosts.
+	SessionAudienceBinding bool `mapstructure:"session_audience_binding" yaml:"session_audience_binding,omitempty"`
+
+	// ConcurrentSessionLoaders calls the session loaders (cookie, header and
+	// query param) concurrently rather than one after the other. Loaders keep
+	// their precedence when more than one finds a session.
+	ConcurrentSessionLoaders bool `mapstructure:"concurrent_session_loaders" yaml:"concurrent_session_loaders,omitempty"`
+
+	// QueryParamSessionAllMethods accepts a session passed in the
+	// pomerium_session query param on all requests. By default it is only
+	// accepted on GET and HEAD requests.
+	QueryParamSessionAllMethods bool `mapstructure:"query_param_session_all_methods" yaml:"query_param_session_all_methods,omitempty"`
+
+	// ProxyCookieReadOnly stops the proxy service from setting or clearing
+	// session cookies, leaving that to the authenticate service. This avoids
+	// conflicting Set-Cookie headers from several proxy instances. It requires
+	// a CookieDomain shared with the authenticate service.
+	ProxyCookieReadOnly bool `mapstructure:"proxy_cookie_read_only" yaml:"proxy_cookie_read_only,omitempty"`
+
+	// SessionSizeWarningThreshold is the fraction of the largest session the
+	// session cookie can hold above which a warning is logged when a session
+	// is saved. Zero disables the warning.
+	SessionSizeWarningThreshold float64 `mapstructure:"session_size_warning_threshold" yaml:"session_size_warning_threshold,omitempty"`
+
+	// WebSocketSubprotocolSession loads the session from a websocket
+	// handshake's Sec-WebSocket-Protocol header, for browser clients which
+	// can't set an Authorization header.
+	WebSocketSubprotocolSession bool `mapstructure:"websocket_subprotocol_session" yaml:"websocket_subprotocol_session,omitempty"`
+
 	// Identity provider configuration variables as specified by RFC6749
 	// https://openid.net/specs/openid-connect-basic-1_0.html#RFC6749
 	ClientID       string   `mapstructure:"idp_client_id" yaml:"idp_client_id,omitempty"`
@@ -144,6 +197,11 @@ type Options struct {
 	RefreshDirectoryInterval time.Duration `mapstructure:"idp_refresh_directory_interval" yaml:"idp_refresh_directory_interval,omitempty"`
 	QPS                      float64       `mapstructure:"idp_qps" yaml:"idp_qps"`
 
+	// IDPAllowedAudiences are the client IDs, besides ClientID, that ID tokens
+	// from the identity provider may be issued for (aud) or to (azp). Tokens
+	// with any other audience or authorized party are rejected.
+	IDPAllowedAudiences []string `mapstructure:"idp_allowed_audiences" yaml:"idp_allowed_audiences,omitempty"`
+
 	// RequestParams are custom request params added to the signin request as
 	// part of an Oauth2 code flow.
 	//
@@ -155,12 +213,26 @@ type Options struct {
 	// (sudo) access including the ability to impersonate other users' access
 	Administrators []string `mapstructure:"administrators" yaml:"administrators,omitempty"`
 
+	// AdministratorGroups contains a set of groups whose members are
+	// administrators, in addition to the users listed in Administrators.
+	AdministratorGroups []string `mapstructure:"administrator_groups" yaml:"administrator_groups,omitempty"`
+
+	// AdminsOnly restricts every route to administrators, regardless of the
+	// route's policy. It's meant to lock down access during incidents.
+	AdminsOnly bool `mapstructure:"admins_only" yaml:"admins_only,omitempty"`
+
 	// AuthorizeURL is the routable destination of the authorize service's
 	// gRPC endpoint. NOTE: As many load balancers do not support
 	// externally routed gRPC so this may be an internal location.
 	AuthorizeURLString string   `mapstructure:"authorize_service_url" yaml:"authorize_service_url,omitempty"`
 	AuthorizeURL       *url.URL `yaml:",omitempty"`
 
+	// AuthorizeResponseSigning makes the authorize service sign its decisions
+	// with the shared secret, and the proxy reject forward auth decisions that
+	// are unsigned or whose signature is invalid. Envoy doesn't verify the
+	// signature, so decisions for the routes it proxies aren't protected.
+	AuthorizeResponseSigning bool `mapstructure:"authorize_response_signing" yaml:"authorize_response_signing,omitempty"`
+
 	// Settings to enable custom behind-the-ingress service communication
 	OverrideCertificateName string `mapstructure:"override_certificate_name" yaml:"override_certificate_name,omitempty"`
 	CA                      string `mapstructure:"certificate_authority" yaml:"certificate_authority,omitempty"`
@@ -176,12 +248,122 @@ type Options struct {
 
 	// List of JWT claims to insert as x-pomerium-claim-* headers on proxied requests
 	JWTClaimsHeaders []string `mapstructure:"jwt_claims_headers" yaml:"jwt_claims_headers,omitempty"`
+	// JWTClaimsHeadersFormat sets how claims with multiple values are
+	// formatted as x-pomerium-claim-* headers. Defaults to comma separated.
+	JWTClaimsHeadersFormat ClaimHeaderFormat `mapstructure:"jwt_claims_headers_format" yaml:"jwt_claims_headers_format,omitempty"`
+
+	// DownstreamTokenClaims and DownstreamTokenExpiry, if set, replace the
+	// JWT forwarded to upstreams with a separate token carrying only the
+	// listed claims, which expires after DownstreamTokenExpiry. The session
+	// cookie is unaffected.
+	DownstreamTokenClaims []string      `mapstructure:"downstream_token_claims" yaml:"downstream_token_claims,omitempty"`
+	DownstreamTokenExpiry time.Duration `mapstructure:"downstream_token_expiry" yaml:"downstream_token_expiry,omitempty"`
+
+	// UserIDHeader, if set, is a header added to proxied requests containing
+	// a stable pseudonymous identifier for the user: a keyed hash of the
+	// user's subject, salted with UserIDHeaderSalt.
+	UserIDHeader     string `mapstructure:"user_id_header" yaml:"user_id_header,omitempty"`
+	UserIDHeaderSalt string `mapstructure:"user_id_header_salt" yaml:"user_id_header_salt,omitempty"`
+
+	// ServerTimingHeaders adds a Server-Timing header to proxied responses
+	// reporting how long authorization, session refresh and the upstream took.
+	ServerTimingHeaders bool `mapstructure:"server_timing_headers" yaml:"server_timing_headers,omitempty"`
+
+	// SessionClaims are additional claims added to a user's session when it is
+	// created. Each value is a template evaluated against the identity
+	// provider's claims.
+	SessionClaims map[string]string `mapstructure:"session_claims" yaml:"session_claims,omitempty"`
+
+	// MaxSessionClaims and MaxSessionClaimsBytes bound the number and total
+	// size of a session's custom claims, of the identity provider claims
+	// stored with sessions and users, and of the claims in the JWT. Claims
+	// over either limit are truncated or rejected depending on
+	// SessionClaimsLimitAction.
+	MaxSessionClaims         int               `mapstructure:"max_session_claims" yaml:"max_session_claims,omitempty"`
+	MaxSessionClaimsBytes    int               `mapstructure:"max_session_claims_bytes" yaml:"max_session_claims_bytes,omitempty"`
+	SessionClaimsLimitAction ClaimsLimitAction `mapstructure:"session_claims_limit_action" yaml:"session_claims_limit_action,omitempty"`
+
+	// MaxRefreshTokenBytes bounds the size of the refresh tokens stored with
+	// sessions. Larger tokens are rejected or discarded depending on
+	// RefreshTokenLimitAction. Zero means no limit.
+	MaxRefreshTokenBytes    int                     `mapstructure:"max_refresh_token_bytes" yaml:"max_refresh_token_bytes,omitempty"`
+	RefreshTokenLimitAction RefreshTokenLimitAction `mapstructure:"refresh_token_limit_action" yaml:"refresh_token_limit_action,omitempty"`
+
+	// ServiceAccounts are non-interactive identities that may exchange a
+	// signed credential for a session without going through the identity
+	// provider.
+	ServiceAccounts []ServiceAccount `mapstructure:"service_accounts" yaml:"service_accounts,omitempty"`
 
 	// RefreshCooldown limits the rate a user can refresh her session
 	RefreshCooldown time.Duration `mapstructure:"refresh_cooldown" yaml:"refresh_cooldown,omitempty"`
 
 	DefaultUpstreamTimeout time.Duration `mapstructure:"default_upstream_timeout" yaml:"default_upstream_timeout,omitempty"`
 
+	// UpstreamIdleTimeout and UpstreamMaxConnections configure the pool of
+	// connections the proxy keeps to each route's upstream. Idle connections
+	// are closed after UpstreamIdleTimeout, and no more than
+	// UpstreamMaxConnections are opened at once. Zero uses envoy's defaults.
+	UpstreamIdleTimeout    time.Duration `mapstructure:"upstream_idle_timeout" yaml:"upstream_idle_timeout,omitempty"`
+	UpstreamMaxConnections int           `mapstructure:"upstream_max_connections" yaml:"upstream_max_connections,omitempty"`
+
+	// UseProxyProtocol enables parsing of PROXY protocol headers sent by an L4
+	// load balancer on the main listener. The client address in headers sent
+	// by peers outside of ProxyProtocolTrustedCIDRs is ignored.
+	UseProxyProtocol          bool     `mapstructure:"use_proxy_protocol" yaml:"use_proxy_protocol,omitempty"`
+	ProxyProtocolTrustedCIDRs []string `mapstructure:"proxy_protocol_trusted_cidrs" yaml:"proxy_protocol_trusted_cidrs,omitempty"`
+
+	// ProxyMaxRouteInflightRequests and ProxyMaxHeapBytes enable load shedding
+	// in envoy. The in-flight limit applies to each route separately: once a
+	// route has ProxyMaxRouteInflightRequests requests open to its upstream,
+	// new requests to that route are rejected with a 503. Once envoy's heap
+	// reaches ProxyMaxHeapBytes, every new request is rejected with a 503 until
+	// memory use drops. Zero disables the check.
+	ProxyMaxRouteInflightRequests int    `mapstructure:"proxy_max_route_inflight_requests" yaml:"proxy_max_route_inflight_requests,omitempty"`
+	ProxyMaxHeapBytes             uint64 `mapstructure:"proxy_max_heap_bytes" yaml:"proxy_max_heap_bytes,omitempty"`
+
+	// ProxyRouteMetrics names envoy's upstream request stats for each route
+	// after the route's id, rather than its cluster.
+	ProxyRouteMetrics bool `mapstructure:"proxy_route_metrics" yaml:"proxy_route_metrics,omitempty"`
+
+	// ProxyDenialDetails are the details of authorize denials surfaced in
+	// the responses to denied requests, whether they are denied by envoy or
+	// by the proxy service's forward auth endpoints. None are surfaced by
+	// default, since they may reveal how routes are protected.
+	ProxyDenialDetails []DenialDetail `mapstructure:"proxy_denial_details" yaml:"proxy_denial_details,omitempty"`
+
+	// ProxyDeduplicateAuthorizeChecks makes the proxy service's forward auth
+	// endpoints share a single authorize check between identical concurrent
+	// requests, that is requests with the same session, method and url.
+	// Envoy's checks for the routes it proxies are never shared.
+	ProxyDeduplicateAuthorizeChecks bool `mapstructure:"proxy_deduplicate_authorize_checks" yaml:"proxy_deduplicate_authorize_checks,omitempty"`
+
+	// RequestSmugglingProtection determines how strictly requests with
+	// ambiguous Content-Length and Transfer-Encoding headers are rejected.
+	// Defaults to strict.
+	RequestSmugglingProtection RequestSmugglingProtection `mapstructure:"request_smuggling_protection" yaml:"request_smuggling_protection,omitempty"`
+
+	// SNIHostConsistency determines whether requests for protected routes
+	// must be sent over a TLS connection whose server name matches their Host
+	// header. Defaults to lenient.
+	SNIHostConsistency SNIHostConsistency `mapstructure:"sni_host_consistency" yaml:"sni_host_consistency,omitempty"`
+
+	// JWKSCacheMaxAge is how long clients may cache the authenticate service's
+	// JSON web key set. Zero requires clients to revalidate it every time.
+	JWKSCacheMaxAge time.Duration `mapstructure:"jwks_cache_max_age" yaml:"jwks_cache_max_age,omitempty"`
+
+	// JSONErrors returns errors generated by pomerium for proxied requests as
+	// JSON, including the request id, rather than as HTML or plain text.
+	JSONErrors bool `mapstructure:"json_errors" yaml:"json_errors,omitempty"`
+
+	// XFFMaxLength caps the number of X-Forwarded-For entries considered when
+	// determining the client IP. Only the right-most entries, those appended
+	// by the proxies closest to pomerium, are kept. Zero means no limit.
+	XFFMaxLength int `mapstructure:"xff_max_length" yaml:"xff_max_length,omitempty"`
+	// XFFFilterPrivateRanges skips private and reserved addresses, treated as
+	// the hops of trusted proxies, in the X-Forwarded-For chain when
+	// determining the client IP.
+	XFFFilterPrivateRanges bool `mapstructure:"xff_filter_private_ranges" yaml:"xff_filter_private_ranges,omitempty"`
+
 	// Address/Port to bind to for prometheus metrics
 	MetricsAddr string `mapstructure:"metrics_address" yaml:"metrics_address,omitempty"`
 
@@ -216,6 +398,9 @@ type Options struct {
 
 	GRPCClientTimeout       time.Duration `mapstructure:"grpc_client_timeout" yaml:"grpc_client_timeout,omitempty"`
 	GRPCClientDNSRoundRobin bool          `mapstructure:"grpc_client_dns_roundrobin" yaml:"grpc_client_dns_roundrobin,omitempty"`
+	// GRPCClientDNSStaleTTL specifies how long previously resolved addresses
+	// continue to be used for gRPC clients while DNS resolution is failing.
+	GRPCClientDNSStaleTTL time.Duration `mapstructure:"grpc_client_dns_stale_ttl" yaml:"grpc_client_dns_stale_ttl,omitempty"`
 
 	//GRPCServerMaxConnectionAge sets MaxConnectionAge in the grpc ServerParameters used to create GRPC Services
 	GRPCServerMaxConnectionAge time.Duration `mapstructure:"grpc_server_max_connection_age" yaml:"grpc_server_max_connection_age,omitempty"`
@@ -294,9 +479,11 @@ var defaultOptions = Options{
 	WriteTimeout:                    0, // support streaming by default
 	IdleTimeout:                     5 * time.Minute,
 	RefreshCooldown:                 5 * time.Minute,
+	SessionSizeWarningThreshold:     0.8,
 	GRPCAddr:                        ":443",
 	GRPCClientTimeout:               10 * time.Second, // Try to withstand transient service failures for a single request
 	GRPCClientDNSRoundRobin:         true,
+	GRPCClientDNSStaleTTL:           5 * time.Minute,
 	GRPCServerMaxConnectionAge:      5 * time.Minute,
 	GRPCServerMaxConnectionAgeGrace: 5 * time.Minute,
 	AuthenticateCallbackPath:        "/oauth2/callback",
@@ -550,6 +737,15 @@ func (o *Options) Validate() error {
 		o.SignOutRedirectURL = u
 	}
 
+	if len(o.SignOutRedirectAllowedHosts) > 0 {
+		log.Warn().Msg("config: signout_redirect_allowed_hosts is deprecated, use redirect_allowlist instead")
+	}
+	for _, entry := range o.getRedirectAllowlist() {
+		if _, _, err := parseRedirectAllowlistEntry(entry); err != nil {
+			return fmt.Errorf("config: invalid redirect_allowlist entry %q: %w", entry, err)
+		}
+	}
+
 	if o.AuthorizeURLString != "" {
 		u, err := urlutil.ParseAndValidateURL(o.AuthorizeURLString)
 		if err != nil {
@@ -574,6 +770,114 @@ func (o *Options) Validate() error {
 		o.ForwardAuthURL = u
 	}
 
+	if err := o.JWTClaimsHeadersFormat.Validate(); err != nil {
+		return fmt.Errorf("config: bad jwt_claims_headers_format: %w", err)
+	}
+
+	if o.DownstreamTokenExpiry < 0 {
+		return errors.New("config: downstream_token_expiry cannot be negative")
+	}
+
+	if o.UserIDHeader != "" && o.UserIDHeaderSalt == "" {
+		return errors.New("config: user_id_header_salt is required when user_id_header is set")
+	}
+
+	if err := o.RequestSmugglingProtection.Validate(); err != nil {
+		return fmt.Errorf("config: bad request_smuggling_protection: %w", err)
+	}
+
+	if err := o.SNIHostConsistency.Validate(); err != nil {
+		return fmt.Errorf("config: bad sni_host_consistency: %w", err)
+	}
+
+	for _, detail := range o.ProxyDenialDetails {
+		if err := detail.Validate(); err != nil {
+			return fmt.Errorf("config: bad proxy_denial_details: %w", err)
+		}
+	}
+
+	if o.SessionSizeWarningThreshold < 0 || o.SessionSizeWarningThreshold > 1 {
+		return errors.New("config: session_size_warning_threshold must be between 0 and 1")
+	}
+
+	if o.UpstreamIdleTimeout < 0 {
+		return errors.New("config: upstream_idle_timeout cannot be negative")
+	}
+
+	if o.UpstreamMaxConnections < 0 {
+		return errors.New("config: upstream_max_connections cannot be negative")
+	}
+
+	if o.JWKSCacheMaxAge < 0 {
+		return errors.New("config: jwks_cache_max_age cannot be negative")
+	}
+
+	if o.XFFMaxLength < 0 {
+		return errors.New("config: xff_max_length cannot be negative")
+	}
+
+	if err := o.SessionExpirySource.Validate(); err != nil {
+		return fmt.Errorf("config: bad session_expiry_source: %w", err)
+	}
+
+	if _, err := sessions.NewClaimTemplates(o.SessionClaims); err != nil {
+		return fmt.Errorf("config: bad session claims: %w", err)
+	}
+
+	if o.MaxSessionClaims < 0 {
+		return errors.New("config: max_session_claims cannot be negative")
+	}
+
+	if o.MaxSessionClaimsBytes < 0 {
+		return errors.New("config: max_session_claims_bytes cannot be negative")
+	}
+
+	if err := o.SessionClaimsLimitAction.Validate(); err != nil {
+		return fmt.Errorf("config: bad session_claims_limit_action: %w", err)
+	}
+
+	if o.MaxRefreshTokenBytes < 0 {
+		return errors.New("config: max_refresh_token_bytes cannot be negative")
+	}
+
+	if err := o.RefreshTokenLimitAction.Validate(); err != nil {
+		return fmt.Errorf("config: bad refresh_token_limit_action: %w", err)
+	}
+
+	if o.ProxyCookieReadOnly {
+		if o.CookieDomain == "" {
+			return errors.New("config: cookie_domain is required when proxy_cookie_read_only is enabled")
+		}
+		if o.AuthenticateURL != nil && !cookieDomainMatches(o.CookieDomain, o.AuthenticateURL.Hostname()) {
+			return fmt.Errorf("config: cookie_domain %s must include the authenticate service's host when proxy_cookie_read_only is enabled", o.CookieDomain)
+		}
+	}
+
+	serviceAccountIDs := make(map[string]struct{}, len(o.ServiceAccounts))
+	for i := range o.ServiceAccounts {
+		sa := &o.ServiceAccounts[i]
+		if err := sa.Validate(); err != nil {
+			return fmt.Errorf("config: bad service account: %w", err)
+		}
+		if _, ok := serviceAccountIDs[sa.ID]; ok {
+			return fmt.Errorf("config: duplicate service account %s", sa.ID)
+		}
+		serviceAccountIDs[sa.ID] = struct{}{}
+	}
+
+	if o.UseProxyProtocol && len(o.ProxyProtocolTrustedCIDRs) == 0 {
+		return errors.New("config: proxy_protocol_trusted_cidrs is required when use_proxy_protocol is enabled")
+	}
+	for _, cidr := range o.ProxyProtocolTrustedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("config: bad proxy protocol trusted cidr %s: %w", cidr, err)
+		}
+	}
+
+	if o.ProxyMaxRouteInflightRequests < 0 {
+		return errors.New("config: proxy_max_route_inflight_requests cannot be negative")
+	}
+
 	if o.PolicyFile != "" {
 		return errors.New("config: policy file setting is deprecated")
 	}
@@ -723,18 +1027,128 @@ func (o *Options) GetForwardAuthURL() *url.URL {
 	return u
 }
 
+// IsAllowedRedirect returns true if users may be redirected to u after
+// signing in or out. Only http and https urls are allowed. If a redirect
+// allowlist is configured, u must also point to the authenticate service,
+// forward auth, a route, the signout redirect url or an entry of the
+// allowlist.
+func (o *Options) IsAllowedRedirect(u *url.URL) bool {
+	if u == nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return false
+	}
+	redirectAllowlist := o.getRedirectAllowlist()
+	if len(redirectAllowlist) == 0 {
+		return true
+	}
+
+	allowed := []string{
+		o.GetAuthenticateURL().Host,
+		o.GetForwardAuthURL().Host,
+	}
+	if o.SignOutRedirectURL != nil {
+		allowed = append(allowed, o.SignOutRedirectURL.Host)
+	}
+	for _, p := range o.Policies {
+		if p.Source != nil {
+			allowed = append(allowed, p.Source.Host)
+		}
+	}
+	for _, host := range allowed {
+		if strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+
+	for _, entry := range redirectAllowlist {
+		scheme, host, err := parseRedirectAllowlistEntry(entry)
+		if err != nil {
+			continue
+		}
+		if scheme != "" && scheme != u.Scheme {
+			continue
+		}
+		// entries without a port match any port
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			if strings.EqualFold(u.Hostname(), strings.Trim(host, "[]")) {
+				return true
+			}
+		} else if strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// getRedirectAllowlist returns the redirect allowlist, including the hosts
+// of the deprecated signout redirect allowed hosts setting.
+func (o *Options) getRedirectAllowlist() []string {
+	if len(o.SignOutRedirectAllowedHosts) == 0 {
+		return o.RedirectAllowlist
+	}
+	return append(append([]string(nil), o.RedirectAllowlist...), o.SignOutRedirectAllowedHosts...)
+}
+
+// parseRedirectAllowlistEntry parses a host or scheme://host allowlist entry.
+func parseRedirectAllowlistEntry(entry string) (scheme, host string, err error) {
+	if !strings.Contains(entry, "://") {
+		if entry == "" || strings.ContainsAny(entry, "/?#@") {
+			return "", "", fmt.Errorf("expected a host or scheme://host")
+		}
+		return "", entry, nil
+	}
+	u, err := url.Parse(entry)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", fmt.Errorf("expected a host or scheme://host")
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// cookieDomainMatches returns true if a cookie set for domain is sent to host.
+func cookieDomainMatches(domain, host string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
 // GetOauthOptions gets the oauth.Options for the given config options.
 func (o *Options) GetOauthOptions() oauth.Options {
 	redirectURL := o.GetAuthenticateURL()
 	redirectURL.Path = o.AuthenticateCallbackPath
 	return oauth.Options{
-		RedirectURL:    redirectURL,
-		ProviderName:   o.Provider,
-		ProviderURL:    o.ProviderURL,
-		ClientID:       o.ClientID,
-		ClientSecret:   o.ClientSecret,
-		Scopes:         o.Scopes,
-		ServiceAccount: o.ServiceAccount,
+		RedirectURL:      redirectURL,
+		ProviderName:     o.Provider,
+		ProviderURL:      o.ProviderURL,
+		ClientID:         o.ClientID,
+		ClientSecret:     o.ClientSecret,
+		Scopes:           o.Scopes,
+		ServiceAccount:   o.ServiceAccount,
+		AllowedAudiences: o.IDPAllowedAudiences,
+	}
+}
+
+// GetSessionClaimsLimit gets the limit on the claims of sessions.
+// Claims passed as JWT claim headers are kept first when truncating.
+func (o *Options) GetSessionClaimsLimit() sessions.ClaimsLimit {
+	return sessions.ClaimsLimit{
+		MaxCount: o.MaxSessionClaims,
+		MaxBytes: o.MaxSessionClaimsBytes,
+		Reject:   o.SessionClaimsLimitAction == ClaimsLimitActionReject,
+		Priority: o.JWTClaimsHeaders,
+	}
+}
+
+// GetClientIPOptions gets the options used to derive the client IP from the
+// X-Forwarded-For chain.
+func (o *Options) GetClientIPOptions() httputil.ClientIPOptions {
+	return httputil.ClientIPOptions{
+		MaxLength:           o.XFFMaxLength,
+		FilterPrivateRanges: o.XFFFilterPrivateRanges,
 	}
 }
 
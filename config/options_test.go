@@ -46,6 +46,98 @@ func Test_Validate(t *testing.T) {
 	missingStorageDSN.DataBrokerStorageType = "redis"
 	badSignoutRedirectURL := testOptions()
 	badSignoutRedirectURL.SignOutRedirectURLString = "--"
+	proxyProtocol := testOptions()
+	proxyProtocol.UseProxyProtocol = true
+	proxyProtocol.ProxyProtocolTrustedCIDRs = []string{"10.0.0.0/8"}
+	proxyProtocolNoCIDRs := testOptions()
+	proxyProtocolNoCIDRs.UseProxyProtocol = true
+	proxyProtocolBadCIDR := testOptions()
+	proxyProtocolBadCIDR.UseProxyProtocol = true
+	proxyProtocolBadCIDR.ProxyProtocolTrustedCIDRs = []string{"10.0.0.0"}
+	jsonClaimHeaders := testOptions()
+	jsonClaimHeaders.JWTClaimsHeadersFormat = ClaimHeaderFormatJSON
+	badClaimHeadersFormat := testOptions()
+	badClaimHeadersFormat.JWTClaimsHeadersFormat = "xml"
+	idTokenSessionExpiry := testOptions()
+	idTokenSessionExpiry.SessionExpirySource = SessionExpirySourceIDToken
+	badSessionExpirySource := testOptions()
+	badSessionExpirySource.SessionExpirySource = "refresh_token"
+	rejectSessionClaims := testOptions()
+	rejectSessionClaims.MaxSessionClaims = 10
+	rejectSessionClaims.MaxSessionClaimsBytes = 1024
+	rejectSessionClaims.SessionClaimsLimitAction = ClaimsLimitActionReject
+	negativeMaxSessionClaims := testOptions()
+	negativeMaxSessionClaims.MaxSessionClaims = -1
+	negativeMaxSessionClaimsBytes := testOptions()
+	negativeMaxSessionClaimsBytes.MaxSessionClaimsBytes = -1
+	badSessionClaimsLimitAction := testOptions()
+	badSessionClaimsLimitAction.SessionClaimsLimitAction = "ignore"
+	lenientRequestSmuggling := testOptions()
+	lenientRequestSmuggling.RequestSmugglingProtection = RequestSmugglingProtectionLenient
+	badRequestSmuggling := testOptions()
+	badRequestSmuggling.RequestSmugglingProtection = "paranoid"
+	userIDHeader := testOptions()
+	userIDHeader.UserIDHeader = "X-User-Id"
+	userIDHeader.UserIDHeaderSalt = "salt"
+	unsaltedUserIDHeader := testOptions()
+	unsaltedUserIDHeader.UserIDHeader = "X-User-Id"
+	denialDetails := testOptions()
+	denialDetails.ProxyDenialDetails = []DenialDetail{DenialDetailReason, DenialDetailRoute}
+	badDenialDetails := testOptions()
+	badDenialDetails.ProxyDenialDetails = []DenialDetail{"policy"}
+	downstreamToken := testOptions()
+	downstreamToken.DownstreamTokenClaims = []string{"email"}
+	downstreamToken.DownstreamTokenExpiry = time.Minute
+	negativeDownstreamTokenExpiry := testOptions()
+	negativeDownstreamTokenExpiry.DownstreamTokenExpiry = -time.Minute
+	xffLimits := testOptions()
+	xffLimits.XFFMaxLength = 3
+	xffLimits.XFFFilterPrivateRanges = true
+	negativeXFFMaxLength := testOptions()
+	negativeXFFMaxLength.XFFMaxLength = -1
+	discardRefreshTokens := testOptions()
+	discardRefreshTokens.MaxRefreshTokenBytes = 4096
+	discardRefreshTokens.RefreshTokenLimitAction = RefreshTokenLimitActionDiscard
+	negativeMaxRefreshTokenBytes := testOptions()
+	negativeMaxRefreshTokenBytes.MaxRefreshTokenBytes = -1
+	badRefreshTokenLimitAction := testOptions()
+	badRefreshTokenLimitAction.RefreshTokenLimitAction = "truncate"
+	strictSNIHost := testOptions()
+	strictSNIHost.SNIHostConsistency = SNIHostConsistencyStrict
+	badSNIHost := testOptions()
+	badSNIHost.SNIHostConsistency = "loose"
+	badSessionSizeWarningThreshold := testOptions()
+	badSessionSizeWarningThreshold.SessionSizeWarningThreshold = 1.5
+	negativeUpstreamIdleTimeout := testOptions()
+	negativeUpstreamIdleTimeout.UpstreamIdleTimeout = -time.Minute
+	negativeUpstreamMaxConnections := testOptions()
+	negativeUpstreamMaxConnections.UpstreamMaxConnections = -1
+	negativeJWKSCacheMaxAge := testOptions()
+	negativeJWKSCacheMaxAge.JWKSCacheMaxAge = -time.Minute
+	serviceAccounts := testOptions()
+	serviceAccounts.ServiceAccounts = []ServiceAccount{{ID: "ci"}, {ID: "deploy", SessionTTL: time.Minute}}
+	duplicateServiceAccounts := testOptions()
+	duplicateServiceAccounts.ServiceAccounts = []ServiceAccount{{ID: "ci"}, {ID: "ci"}}
+	badServiceAccountKey := testOptions()
+	badServiceAccountKey.ServiceAccounts = []ServiceAccount{{ID: "ci", PublicKey: "bm90IGEga2V5"}}
+	redirectAllowlist := testOptions()
+	redirectAllowlist.RedirectAllowlist = []string{"portal.example.com", "https://app.example.com"}
+	badRedirectAllowlistScheme := testOptions()
+	badRedirectAllowlistScheme.RedirectAllowlist = []string{"javascript://app.example.com"}
+	badRedirectAllowlistPath := testOptions()
+	badRedirectAllowlistPath.RedirectAllowlist = []string{"https://app.example.com/path"}
+	badSignOutRedirectAllowedHost := testOptions()
+	badSignOutRedirectAllowedHost.SignOutRedirectAllowedHosts = []string{"app.example.com/path"}
+	proxyCookieReadOnly := testOptions()
+	proxyCookieReadOnly.ProxyCookieReadOnly = true
+	proxyCookieReadOnly.CookieDomain = ".example.com"
+	proxyCookieReadOnly.AuthenticateURLString = "https://authenticate.example.com"
+	proxyCookieReadOnlyNoDomain := testOptions()
+	proxyCookieReadOnlyNoDomain.ProxyCookieReadOnly = true
+	proxyCookieReadOnlyOtherDomain := testOptions()
+	proxyCookieReadOnlyOtherDomain.ProxyCookieReadOnly = true
+	proxyCookieReadOnlyOtherDomain.CookieDomain = "apps.example.com"
+	proxyCookieReadOnlyOtherDomain.AuthenticateURLString = "https://authenticate.example.com"
 
 	tests := []struct {
 		name     string
@@ -60,6 +152,46 @@ func Test_Validate(t *testing.T) {
 		{"invalid databroker storage type", invalidStorageType, true},
 		{"missing databroker storage dsn", missingStorageDSN, true},
 		{"invalid signout redirect url", badSignoutRedirectURL, true},
+		{"proxy protocol with trusted cidrs", proxyProtocol, false},
+		{"proxy protocol without trusted cidrs", proxyProtocolNoCIDRs, true},
+		{"proxy protocol with bad trusted cidr", proxyProtocolBadCIDR, true},
+		{"json claim headers format", jsonClaimHeaders, false},
+		{"unknown claim headers format", badClaimHeadersFormat, true},
+		{"id token session expiry source", idTokenSessionExpiry, false},
+		{"unknown session expiry source", badSessionExpirySource, true},
+		{"reject sessions over claims limit", rejectSessionClaims, false},
+		{"negative max session claims", negativeMaxSessionClaims, true},
+		{"negative max session claims bytes", negativeMaxSessionClaimsBytes, true},
+		{"unknown session claims limit action", badSessionClaimsLimitAction, true},
+		{"lenient request smuggling protection", lenientRequestSmuggling, false},
+		{"unknown request smuggling protection", badRequestSmuggling, true},
+		{"user id header", userIDHeader, false},
+		{"user id header without salt", unsaltedUserIDHeader, true},
+		{"denial details", denialDetails, false},
+		{"unknown denial detail", badDenialDetails, true},
+		{"downstream token", downstreamToken, false},
+		{"negative downstream token expiry", negativeDownstreamTokenExpiry, true},
+		{"x-forwarded-for limits", xffLimits, false},
+		{"negative x-forwarded-for max length", negativeXFFMaxLength, true},
+		{"session size warning threshold over 1", badSessionSizeWarningThreshold, true},
+		{"negative upstream idle timeout", negativeUpstreamIdleTimeout, true},
+		{"negative upstream max connections", negativeUpstreamMaxConnections, true},
+		{"negative jwks cache max age", negativeJWKSCacheMaxAge, true},
+		{"discard oversized refresh tokens", discardRefreshTokens, false},
+		{"negative max refresh token bytes", negativeMaxRefreshTokenBytes, true},
+		{"unknown refresh token limit action", badRefreshTokenLimitAction, true},
+		{"strict sni host consistency", strictSNIHost, false},
+		{"unknown sni host consistency", badSNIHost, true},
+		{"service accounts", serviceAccounts, false},
+		{"duplicate service accounts", duplicateServiceAccounts, true},
+		{"service account with bad public key", badServiceAccountKey, true},
+		{"redirect allowlist", redirectAllowlist, false},
+		{"redirect allowlist entry with bad scheme", badRedirectAllowlistScheme, true},
+		{"redirect allowlist entry with path", badRedirectAllowlistPath, true},
+		{"signout redirect allowed host with path", badSignOutRedirectAllowedHost, true},
+		{"proxy read-only cookies", proxyCookieReadOnly, false},
+		{"proxy read-only cookies without cookie domain", proxyCookieReadOnlyNoDomain, true},
+		{"proxy read-only cookies with cookie domain not shared with authenticate", proxyCookieReadOnlyOtherDomain, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -216,7 +348,7 @@ func Test_Checksum(t *testing.T) {
 func TestOptionsFromViper(t *testing.T) {
 	t.Parallel()
 	opts := []cmp.Option{
-		cmpopts.IgnoreFields(Options{}, "CookieSecret", "GRPCInsecure", "GRPCAddr", "CacheURLString", "CacheURL", "DataBrokerURLString", "DataBrokerURL", "AuthorizeURL", "AuthorizeURLString", "DefaultUpstreamTimeout", "CookieExpire", "Services", "Addr", "RefreshCooldown", "LogLevel", "KeyFile", "CertFile", "SharedKey", "ReadTimeout", "IdleTimeout", "GRPCClientTimeout", "GRPCClientDNSRoundRobin", "TracingSampleRate"),
+		cmpopts.IgnoreFields(Options{}, "CookieSecret", "GRPCInsecure", "GRPCAddr", "CacheURLString", "CacheURL", "DataBrokerURLString", "DataBrokerURL", "AuthorizeURL", "AuthorizeURLString", "DefaultUpstreamTimeout", "CookieExpire", "Services", "Addr", "RefreshCooldown", "LogLevel", "KeyFile", "CertFile", "SharedKey", "ReadTimeout", "IdleTimeout", "GRPCClientTimeout", "GRPCClientDNSRoundRobin", "GRPCClientDNSStaleTTL", "TracingSampleRate"),
 		cmpopts.IgnoreFields(Policy{}, "Source", "Destination"),
 		cmpOptIgnoreUnexported,
 	}
@@ -243,10 +375,11 @@ func TestOptionsFromViper(t *testing.T) {
 					"X-Frame-Options":           "SAMEORIGIN",
 					"X-XSS-Protection":          "1; mode=block",
 				},
-				RefreshDirectoryTimeout:  1 * time.Minute,
-				RefreshDirectoryInterval: 10 * time.Minute,
-				QPS:                      1.0,
-				DataBrokerStorageType:    "memory",
+				RefreshDirectoryTimeout:     1 * time.Minute,
+				RefreshDirectoryInterval:    10 * time.Minute,
+				QPS:                         1.0,
+				DataBrokerStorageType:       "memory",
+				SessionSizeWarningThreshold: 0.8,
 			},
 			false},
 		{"good disable header",
@@ -265,6 +398,7 @@ func TestOptionsFromViper(t *testing.T) {
 				RefreshDirectoryInterval:        10 * time.Minute,
 				QPS:                             1.0,
 				DataBrokerStorageType:           "memory",
+				SessionSizeWarningThreshold:     0.8,
 			},
 			false},
 		{"bad url", []byte(`{"policy":[{"from": "https://","to":"https://to.example"}]}`), nil, true},
@@ -517,3 +651,76 @@ func TestOptions_GetOauthOptions(t *testing.T) {
 	// Test that oauth redirect url hostname must point to authenticate url hostname.
 	assert.Equal(t, opts.AuthenticateURL.Hostname(), opts.GetOauthOptions().RedirectURL.Hostname())
 }
+
+func TestOptions_IsAllowedRedirect(t *testing.T) {
+	t.Run("no allowlist", func(t *testing.T) {
+		opts := &Options{}
+		assert.True(t, opts.IsAllowedRedirect(mustParseURL("https://anywhere.example.com/")))
+		assert.True(t, opts.IsAllowedRedirect(mustParseURL("http://localhost:8000")))
+		assert.False(t, opts.IsAllowedRedirect(mustParseURL("javascript://anywhere.example.com/")))
+	})
+
+	opts := &Options{
+		AuthenticateURL:   mustParseURL("https://authenticate.example.com"),
+		RedirectAllowlist: []string{"portal.example.com", "https://secure.example.com", "http://localhost:8000", "http://127.0.0.1"},
+		Policies: []Policy{
+			{Source: &StringURL{URL: mustParseURL("https://app.example.com")}},
+		},
+	}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://authenticate.example.com/", true},
+		{"https://app.example.com/path", true},
+		{"https://portal.example.com/", true},
+		{"http://portal.example.com/", true},
+		{"https://secure.example.com/", true},
+		{"http://secure.example.com/", false},
+		{"https://portal.example.com:8443/", true},
+		{"http://localhost:8000/", true},
+		{"http://localhost:9000/", false},
+		{"http://127.0.0.1:49152/", true},
+		{"https://evil.example.com/", false},
+		{"https://portal.example.com.evil.example.com/", false},
+		{"javascript://portal.example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.want, opts.IsAllowedRedirect(mustParseURL(tt.url)))
+		})
+	}
+}
+
+func TestOptions_IsAllowedRedirectSignOutSettings(t *testing.T) {
+	// the deprecated signout redirect allowed hosts are part of the allowlist,
+	// so they restrict redirects even when the allowlist itself is empty
+	opts := &Options{
+		AuthenticateURL:             mustParseURL("https://authenticate.example.com"),
+		SignOutRedirectURL:          mustParseURL("https://signed-out.example.com/bye"),
+		SignOutRedirectAllowedHosts: []string{"landing.example.com", "https://portal.example.com"},
+		Policies: []Policy{
+			{Source: &StringURL{URL: mustParseURL("https://app.example.com")}},
+		},
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://authenticate.example.com/", true},
+		{"https://signed-out.example.com/other", true},
+		{"https://landing.example.com/?from=pomerium", true},
+		{"https://APP.example.com/", true},
+		{"https://portal.example.com/", true},
+		{"http://portal.example.com/", false},
+		{"https://evil.example.com/", false},
+		{"https://app.example.com.evil.example.com/", false},
+		{"javascript://app.example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.want, opts.IsAllowedRedirect(mustParseURL(tt.url)))
+		})
+	}
+}
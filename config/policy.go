@@ -50,6 +50,12 @@ type Policy struct {
 	// Allow any public request to access this route. **Bypasses authentication**
 	AllowPublicUnauthenticatedAccess bool `mapstructure:"allow_public_unauthenticated_access" yaml:"allow_public_unauthenticated_access,omitempty"`
 
+	// AllowResponseCaching stops pomerium from marking responses to
+	// authenticated requests as uncacheable, leaving the upstream's
+	// Cache-Control header untouched. Only enable this for content that is
+	// safe to share between users.
+	AllowResponseCaching bool `mapstructure:"allow_response_caching" yaml:"allow_response_caching,omitempty"`
+
 	// UpstreamTimeout is the route specific timeout. Must be less than the global
 	// timeout. If unset,  route will fallback to the proxy's DefaultUpstreamTimeout.
 	UpstreamTimeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
@@ -58,6 +64,20 @@ type Policy struct {
 	// Caution: Enabling this feature could result in abuse via DOS attacks.
 	AllowWebsockets bool `mapstructure:"allow_websockets"  yaml:"allow_websockets,omitempty"`
 
+	// WebsocketReauthorizeInterval, if set, closes upgraded websocket
+	// connections once they have been open for the interval. Clients have to
+	// reconnect, which re-runs the authorize check, so a connection whose
+	// access was revoked isn't kept open for longer than the interval.
+	// Requires AllowWebsockets.
+	WebsocketReauthorizeInterval time.Duration `mapstructure:"websocket_reauthorize_interval" yaml:"websocket_reauthorize_interval,omitempty"`
+
+	// MaxWebsocketConnections, if set, limits the number of open websocket
+	// connections to the route's upstream. Upgrades are sent to a cluster of
+	// their own, so the limit doesn't apply to the route's other requests.
+	// New upgrades are rejected while the limit is reached. Requires
+	// AllowWebsockets.
+	MaxWebsocketConnections int `mapstructure:"max_websocket_connections" yaml:"max_websocket_connections,omitempty"`
+
 	// AllowSPDY enables proxying of SPDY upgrade requests
 	AllowSPDY bool `mapstructure:"allow_spdy" yaml:"allow_spdy,omitempty"`
 
@@ -106,6 +126,62 @@ type Policy struct {
 	// https://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_set_header
 	PreserveHostHeader bool `mapstructure:"preserve_host_header" yaml:"preserve_host_header,omitempty"`
 
+	// PreserveSessionQueryParam forwards the pomerium_session query param to
+	// the upstream. By default it is stripped so the session token doesn't
+	// leak into the upstream or its logs.
+	PreserveSessionQueryParam bool `mapstructure:"preserve_session_query_param" yaml:"preserve_session_query_param,omitempty"`
+
+	// RewriteResponseLocation rewrites Location headers in upstream responses
+	// that point at the upstream's internal address (To) to point at the
+	// route's external address (From) instead.
+	RewriteResponseLocation bool `mapstructure:"rewrite_response_location" yaml:"rewrite_response_location,omitempty"`
+
+	// Deprecation and Sunset are RFC 3339 timestamps announcing that the route
+	// is deprecated and when it will stop responding. When set, responses
+	// carry the corresponding Deprecation (RFC 9745) and Sunset (RFC 8594)
+	// headers.
+	Deprecation string `mapstructure:"deprecation" yaml:"deprecation,omitempty"`
+	Sunset      string `mapstructure:"sunset" yaml:"sunset,omitempty"`
+
+	// SecurityHeaders sets Content-Security-Policy, X-Content-Type-Options,
+	// X-Frame-Options and Referrer-Policy on responses from the route.
+	// SecurityHeadersMode controls whether they override or are merged with
+	// the headers sent by the upstream.
+	SecurityHeaders     map[string]string   `mapstructure:"security_headers" yaml:"security_headers,omitempty"`
+	SecurityHeadersMode SecurityHeadersMode `mapstructure:"security_headers_mode" yaml:"security_headers_mode,omitempty"`
+
+	// UpstreamSetCookieMode controls whether Set-Cookie headers sent by the
+	// upstream are passed through, filtered or limited to
+	// UpstreamSetCookieLimit cookies.
+	UpstreamSetCookieMode  UpstreamSetCookieMode `mapstructure:"upstream_set_cookie_mode" yaml:"upstream_set_cookie_mode,omitempty"`
+	UpstreamSetCookieLimit int                   `mapstructure:"upstream_set_cookie_limit" yaml:"upstream_set_cookie_limit,omitempty"`
+
+	// Upstreams, if set instead of To, are the upstreams requests to the
+	// route are load balanced across, according to LoadBalancingPolicy.
+	// Upstreams are ejected for UpstreamEjectionTime after
+	// UpstreamEjectionFailures consecutive failures. Zero disables ejection.
+	Upstreams                []WeightedUpstream  `mapstructure:"upstreams" yaml:"upstreams,omitempty" json:"upstreams,omitempty"`
+	LoadBalancingPolicy      LoadBalancingPolicy `mapstructure:"load_balancing_policy" yaml:"load_balancing_policy,omitempty" json:"load_balancing_policy,omitempty"`
+	UpstreamEjectionFailures uint32              `mapstructure:"upstream_ejection_failures" yaml:"upstream_ejection_failures,omitempty" json:"upstream_ejection_failures,omitempty"`
+	UpstreamEjectionTime     time.Duration       `mapstructure:"upstream_ejection_time" yaml:"upstream_ejection_time,omitempty" json:"upstream_ejection_time,omitempty"`
+
+	// AuthorizeURL overrides the authorize service used by the proxy's forward
+	// auth checks for this route, e.g. to use a tenant's own authorize
+	// cluster. Requests proxied by envoy always use the global authorize
+	// service. Defaults to the global authorize service URL.
+	AuthorizeURLString string   `mapstructure:"authorize_service_url" yaml:"authorize_service_url,omitempty"`
+	AuthorizeURL       *url.URL `yaml:",omitempty" json:"authorize_url,omitempty" hash:"ignore"`
+
+	// AuthorizationHeader controls whether the client's Authorization header
+	// is forwarded to the upstream, stripped, or replaced with a bearer token
+	// containing the signed JWT assertion. Defaults to forwarding it.
+	AuthorizationHeader AuthorizationHeaderMode `mapstructure:"authorization_header" yaml:"authorization_header,omitempty"`
+
+	// AuthorizeRequestHeaders, if set, limits the request headers passed to
+	// the authorization policy to the ones listed. The method, URL and client
+	// IP are always included. If unset, all headers are included.
+	AuthorizeRequestHeaders []string `mapstructure:"authorize_request_headers" yaml:"authorize_request_headers,omitempty"`
+
 	// PassIdentityHeaders controls whether to add a user's identity headers to the downstream request.
 	// These includes:
 	//
@@ -114,6 +190,13 @@ type Policy struct {
 	//
 	PassIdentityHeaders bool `mapstructure:"pass_identity_headers" yaml:"pass_identity_headers,omitempty"`
 
+	// MaxInjectedHeaderBytes bounds the total size of the X-Pomerium-Claim-*
+	// headers added to requests for this route. Claims listed in
+	// PriorityClaimHeaders are kept first, the remaining claims are dropped
+	// once the budget is exhausted.
+	MaxInjectedHeaderBytes int      `mapstructure:"max_injected_header_bytes" yaml:"max_injected_header_bytes,omitempty"`
+	PriorityClaimHeaders   []string `mapstructure:"priority_claim_headers" yaml:"priority_claim_headers,omitempty"`
+
 	// KubernetesServiceAccountToken is the kubernetes token to use for upstream requests.
 	KubernetesServiceAccountToken string `mapstructure:"kubernetes_service_account_token" yaml:"kubernetes_service_account_token,omitempty"`
 	// KubernetesServiceAccountTokenFile contains the kubernetes token to use for upstream requests.
@@ -247,9 +330,39 @@ func (p *Policy) Validate() error {
 
 	p.Source = &StringURL{source}
 
-	p.Destination, err = urlutil.ParseAndValidateURL(p.To)
-	if err != nil {
-		return fmt.Errorf("config: policy bad destination url %w", err)
+	if len(p.Upstreams) > 0 {
+		if p.To != "" {
+			return fmt.Errorf("config: policy to and upstreams are mutually exclusive")
+		}
+		for i := range p.Upstreams {
+			u := &p.Upstreams[i]
+			u.Destination, err = urlutil.ParseAndValidateURL(u.URL)
+			if err != nil {
+				return fmt.Errorf("config: policy bad upstream url %w", err)
+			}
+			// a route's upstreams share its tls settings
+			if u.Destination.Scheme != p.Upstreams[0].Destination.Scheme {
+				return fmt.Errorf("config: policy upstreams must all use the same scheme")
+			}
+			// and so the server name their certificates are verified against
+			if u.Destination.Scheme == "https" && p.TLSServerName == "" &&
+				u.Destination.Hostname() != p.Upstreams[0].Destination.Hostname() {
+				return fmt.Errorf("config: policy https upstreams with different hostnames require tls_server_name")
+			}
+		}
+		p.Destination = p.Upstreams[0].Destination
+	} else {
+		p.Destination, err = urlutil.ParseAndValidateURL(p.To)
+		if err != nil {
+			return fmt.Errorf("config: policy bad destination url %w", err)
+		}
+	}
+
+	if p.AuthorizeURLString != "" {
+		p.AuthorizeURL, err = urlutil.ParseAndValidateURL(p.AuthorizeURLString)
+		if err != nil {
+			return fmt.Errorf("config: policy bad authorize service url %w", err)
+		}
 	}
 
 	// Only allow public access if no other whitelists are in place
@@ -302,9 +415,85 @@ func (p *Policy) Validate() error {
 		return fmt.Errorf("config: only prefix_rewrite or regex_rewrite_pattern can be specified, but not both")
 	}
 
+	if err := p.AuthorizationHeader.Validate(); err != nil {
+		return fmt.Errorf("config: bad authorization_header: %w", err)
+	}
+	if p.AuthorizationHeader != "" && p.AuthorizationHeader != AuthorizationHeaderForward &&
+		(p.KubernetesServiceAccountToken != "" || p.EnableGoogleCloudServerlessAuthentication) {
+		return fmt.Errorf("config: authorization_header cannot be set when pomerium provides the upstream's Authorization header")
+	}
+
+	if p.WebsocketReauthorizeInterval < 0 {
+		return fmt.Errorf("config: websocket_reauthorize_interval cannot be negative")
+	}
+
+	if p.MaxWebsocketConnections < 0 {
+		return fmt.Errorf("config: max_websocket_connections cannot be negative")
+	}
+
+	if p.MaxInjectedHeaderBytes < 0 {
+		return fmt.Errorf("config: max_injected_header_bytes cannot be negative")
+	}
+
+	if err := validateSecurityHeaders(p.SecurityHeaders); err != nil {
+		return fmt.Errorf("config: bad security_headers: %w", err)
+	}
+	if err := p.SecurityHeadersMode.Validate(); err != nil {
+		return fmt.Errorf("config: bad security_headers_mode: %w", err)
+	}
+	if err := p.UpstreamSetCookieMode.Validate(); err != nil {
+		return fmt.Errorf("config: bad upstream_set_cookie_mode: %w", err)
+	}
+	if err := p.LoadBalancingPolicy.Validate(); err != nil {
+		return fmt.Errorf("config: bad load_balancing_policy: %w", err)
+	}
+	if p.UpstreamEjectionTime < 0 {
+		return fmt.Errorf("config: upstream_ejection_time must not be negative")
+	}
+	if p.UpstreamSetCookieLimit < 0 {
+		return fmt.Errorf("config: upstream_set_cookie_limit must not be negative")
+	}
+	if p.UpstreamSetCookieLimit > 0 && p.UpstreamSetCookieMode != UpstreamSetCookieLimit {
+		return fmt.Errorf("config: upstream_set_cookie_limit requires upstream_set_cookie_mode %q", UpstreamSetCookieLimit)
+	}
+	if p.UpstreamSetCookieMode == UpstreamSetCookieLimit && p.UpstreamSetCookieLimit == 0 {
+		return fmt.Errorf("config: upstream_set_cookie_mode %q requires an upstream_set_cookie_limit", UpstreamSetCookieLimit)
+	}
+
+	deprecation, err := p.GetDeprecation()
+	if err != nil {
+		return fmt.Errorf("config: bad deprecation: %w", err)
+	}
+	sunset, err := p.GetSunset()
+	if err != nil {
+		return fmt.Errorf("config: bad sunset: %w", err)
+	}
+	if !deprecation.IsZero() && !sunset.IsZero() && sunset.Before(deprecation) {
+		return fmt.Errorf("config: sunset cannot be before deprecation")
+	}
+
 	return nil
 }
 
+// GetDeprecation returns when the route was deprecated. It returns the zero
+// time if the route isn't deprecated.
+func (p *Policy) GetDeprecation() (time.Time, error) {
+	return parsePolicyTime(p.Deprecation)
+}
+
+// GetSunset returns when the route will stop responding. It returns the zero
+// time if no sunset is set.
+func (p *Policy) GetSunset() (time.Time, error) {
+	return parsePolicyTime(p.Sunset)
+}
+
+func parsePolicyTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
 // Checksum returns the xxhash hash for the policy.
 func (p *Policy) Checksum() uint64 {
 	cs, _ := hashstructure.Hash(p, &hashstructure.HashOptions{
@@ -322,6 +511,9 @@ func (p *Policy) RouteID() uint64 {
 		Path:        p.Path,
 		Regex:       p.Regex,
 	}
+	for _, upstream := range p.Upstreams {
+		id.Upstreams = append(id.Upstreams, upstream.URL)
+	}
 
 	cs, _ := hashstructure.Hash(id, &hashstructure.HashOptions{
 		Hasher: xxhash.New(),
@@ -385,4 +577,5 @@ type routeID struct {
 	Prefix      string
 	Path        string
 	Regex       string
+	Upstreams   []string
 }
@@ -0,0 +1,27 @@
+package config
+
+import "fmt"
+
+// A RefreshTokenLimitAction determines what happens when an identity provider
+// returns a refresh token larger than the configured maximum.
+type RefreshTokenLimitAction string
+
+// RefreshTokenLimitAction values.
+const (
+	// RefreshTokenLimitActionReject fails the sign in.
+	RefreshTokenLimitActionReject RefreshTokenLimitAction = "reject"
+	// RefreshTokenLimitActionDiscard stores the session without the refresh
+	// token, so that the user must sign in again once the session would
+	// otherwise be refreshed.
+	RefreshTokenLimitActionDiscard RefreshTokenLimitAction = "discard"
+)
+
+// Validate checks that the action is known. The empty action is treated as
+// RefreshTokenLimitActionReject.
+func (a RefreshTokenLimitAction) Validate() error {
+	switch a {
+	case "", RefreshTokenLimitActionReject, RefreshTokenLimitActionDiscard:
+		return nil
+	}
+	return fmt.Errorf("unknown refresh token limit action %q", a)
+}
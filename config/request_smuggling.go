@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// A RequestSmugglingProtection determines how strictly requests with
+// ambiguous framing headers, which could be used for request smuggling, are
+// rejected before they are proxied.
+type RequestSmugglingProtection string
+
+// RequestSmugglingProtection values.
+const (
+	// RequestSmugglingProtectionStrict rejects requests with both a
+	// Content-Length and a Transfer-Encoding header, and requests with more
+	// than one Content-Length.
+	RequestSmugglingProtectionStrict RequestSmugglingProtection = "strict"
+	// RequestSmugglingProtectionLenient only rejects requests with
+	// conflicting Content-Length values, for legacy clients that send
+	// redundant framing headers.
+	RequestSmugglingProtectionLenient RequestSmugglingProtection = "lenient"
+	// RequestSmugglingProtectionOff disables the checks.
+	RequestSmugglingProtectionOff RequestSmugglingProtection = "off"
+)
+
+// Validate checks that the protection is known. The empty protection is
+// treated as RequestSmugglingProtectionStrict.
+func (p RequestSmugglingProtection) Validate() error {
+	switch p {
+	case "", RequestSmugglingProtectionStrict, RequestSmugglingProtectionLenient, RequestSmugglingProtectionOff:
+		return nil
+	}
+	return fmt.Errorf("unknown request smuggling protection %q", p)
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// securityHeaders are the response headers which can be set per route with
+// security_headers.
+var securityHeaders = map[string]struct{}{
+	"Content-Security-Policy": {},
+	"X-Content-Type-Options":  {},
+	"X-Frame-Options":         {},
+	"Referrer-Policy":         {},
+}
+
+// A SecurityHeadersMode determines how a route's security headers are
+// combined with the ones sent by its upstream.
+type SecurityHeadersMode string
+
+// SecurityHeadersMode values.
+const (
+	// SecurityHeadersOverride replaces the upstream's headers.
+	SecurityHeadersOverride SecurityHeadersMode = "override"
+	// SecurityHeadersMerge keeps the upstream's headers and only adds the
+	// ones it didn't send. Content-Security-Policy is the exception: both
+	// policies are sent, and browsers enforce all of them.
+	SecurityHeadersMerge SecurityHeadersMode = "merge"
+)
+
+// Validate checks that the mode is known. The empty mode is treated as
+// SecurityHeadersOverride.
+func (m SecurityHeadersMode) Validate() error {
+	switch m {
+	case "", SecurityHeadersOverride, SecurityHeadersMerge:
+		return nil
+	}
+	return fmt.Errorf("unknown security headers mode %q", m)
+}
+
+func validateSecurityHeaders(headers map[string]string) error {
+	for k, v := range headers {
+		if _, ok := securityHeaders[http.CanonicalHeaderKey(k)]; !ok {
+			return fmt.Errorf("unsupported header %q", k)
+		}
+		if v == "" {
+			return fmt.Errorf("empty value for header %q", k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// DefaultServiceAccountSessionTTL is the lifetime of service account sessions
+// when the service account does not set one.
+const DefaultServiceAccountSessionTTL = time.Hour
+
+// ServiceAccount is a non-interactive identity, such as a CI system, that can
+// exchange a signed credential for a session without going through the
+// identity provider.
+type ServiceAccount struct {
+	// ID identifies the service account. Credentials must use it as their
+	// subject.
+	ID string `mapstructure:"id" yaml:"id"`
+	// UserID is the user minted sessions belong to, used when evaluating
+	// policy. Defaults to ID.
+	UserID string `mapstructure:"user_id" yaml:"user_id,omitempty"`
+	// PublicKey is an optional base64 encoded PEM ECDSA public key. When set,
+	// credentials must be ES256 signed by the matching private key. Otherwise
+	// they must be HS256 signed with the shared secret.
+	PublicKey string `mapstructure:"public_key" yaml:"public_key,omitempty"`
+	// SessionTTL is the lifetime of minted sessions.
+	SessionTTL time.Duration `mapstructure:"session_ttl" yaml:"session_ttl,omitempty"`
+}
+
+// Validate checks the service account for errors.
+func (sa *ServiceAccount) Validate() error {
+	if sa.ID == "" {
+		return errors.New("missing id")
+	}
+	if sa.SessionTTL < 0 {
+		return fmt.Errorf("%s: session_ttl cannot be negative", sa.ID)
+	}
+	if sa.PublicKey != "" {
+		if _, err := sa.GetPublicKey(); err != nil {
+			return fmt.Errorf("%s: bad public_key: %w", sa.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetUserID returns the user minted sessions belong to.
+func (sa *ServiceAccount) GetUserID() string {
+	if sa.UserID != "" {
+		return sa.UserID
+	}
+	return sa.ID
+}
+
+// GetSessionTTL returns the lifetime of minted sessions.
+func (sa *ServiceAccount) GetSessionTTL() time.Duration {
+	if sa.SessionTTL > 0 {
+		return sa.SessionTTL
+	}
+	return DefaultServiceAccountSessionTTL
+}
+
+// GetPublicKey decodes the service account's public key. It returns nil if
+// none is set.
+func (sa *ServiceAccount) GetPublicKey() (*ecdsa.PublicKey, error) {
+	if sa.PublicKey == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(sa.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return cryptutil.DecodePublicKey(raw)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// A SessionExpirySource determines which expiry governs how long a session is
+// valid: the session cookie's expiry (cookie_expire) or the expiry of the
+// identity provider's token.
+type SessionExpirySource string
+
+// SessionExpirySource values.
+const (
+	// SessionExpirySourceStrictest expires sessions at the earlier of the
+	// cookie expiry and the identity provider token's expiry.
+	SessionExpirySourceStrictest SessionExpirySource = "strictest"
+	// SessionExpirySourceIDToken only honors the identity provider token's
+	// expiry, so sessions last as long as the token keeps being refreshed.
+	SessionExpirySourceIDToken SessionExpirySource = "id_token"
+)
+
+// Validate checks that the source is known. The empty source is treated as
+// SessionExpirySourceStrictest.
+func (src SessionExpirySource) Validate() error {
+	switch src {
+	case "", SessionExpirySourceStrictest, SessionExpirySourceIDToken:
+		return nil
+	}
+	return fmt.Errorf("unknown session expiry source %q", src)
+}
+
+// Expiry returns when a session expires, given the cookie's and the identity
+// provider token's expiry. A zero time means the expiry is unknown, and a zero
+// result means the session doesn't expire.
+func (src SessionExpirySource) Expiry(cookieExpiry, idTokenExpiry time.Time) time.Time {
+	if src == SessionExpirySourceIDToken {
+		return idTokenExpiry
+	}
+	if cookieExpiry.IsZero() || (!idTokenExpiry.IsZero() && idTokenExpiry.Before(cookieExpiry)) {
+		return idTokenExpiry
+	}
+	return cookieExpiry
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionExpirySource_Expiry(t *testing.T) {
+	now := time.Now()
+	cookieExpiry := now.Add(14 * time.Hour)
+	idTokenExpiry := now.Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		src           SessionExpirySource
+		cookieExpiry  time.Time
+		idTokenExpiry time.Time
+		want          time.Time
+	}{
+		{"default uses the stricter id token expiry", "", cookieExpiry, idTokenExpiry, idTokenExpiry},
+		{"default uses the stricter cookie expiry", "", idTokenExpiry, cookieExpiry, idTokenExpiry},
+		{"strictest", SessionExpirySourceStrictest, cookieExpiry, idTokenExpiry, idTokenExpiry},
+		{"strictest without id token expiry", SessionExpirySourceStrictest, cookieExpiry, time.Time{}, cookieExpiry},
+		{"strictest without cookie expiry", SessionExpirySourceStrictest, time.Time{}, idTokenExpiry, idTokenExpiry},
+		{"id token", SessionExpirySourceIDToken, idTokenExpiry, cookieExpiry, cookieExpiry},
+		{"id token without id token expiry", SessionExpirySourceIDToken, cookieExpiry, time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.src.Expiry(tt.cookieExpiry, tt.idTokenExpiry))
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import "fmt"
+
+// A SNIHostConsistency determines how requests whose Host header doesn't
+// match the server name (SNI) of their TLS connection are handled.
+type SNIHostConsistency string
+
+// SNIHostConsistency values.
+const (
+	// SNIHostConsistencyLenient routes requests by their Host header,
+	// regardless of the server name.
+	SNIHostConsistencyLenient SNIHostConsistency = "lenient"
+	// SNIHostConsistencyStrict rejects requests for protected routes whose
+	// Host header doesn't match the server name with a 421 Misdirected
+	// Request.
+	SNIHostConsistencyStrict SNIHostConsistency = "strict"
+)
+
+// Validate checks that the consistency is known. The empty consistency is
+// treated as SNIHostConsistencyLenient.
+func (c SNIHostConsistency) Validate() error {
+	switch c {
+	case "", SNIHostConsistencyLenient, SNIHostConsistencyStrict:
+		return nil
+	}
+	return fmt.Errorf("unknown sni host consistency %q", c)
+}
@@ -0,0 +1,31 @@
+package config
+
+import "fmt"
+
+// An UpstreamSetCookieMode determines how the Set-Cookie headers sent by a
+// route's upstream are handled. Pomerium's own session cookie is added after
+// the upstream's cookies have been handled, so it's never dropped.
+type UpstreamSetCookieMode string
+
+// UpstreamSetCookieMode values.
+const (
+	// UpstreamSetCookiePass passes all the upstream's cookies through.
+	UpstreamSetCookiePass UpstreamSetCookieMode = "pass"
+	// UpstreamSetCookieFilter drops the upstream's cookies which would
+	// overwrite Pomerium's session cookie.
+	UpstreamSetCookieFilter UpstreamSetCookieMode = "filter"
+	// UpstreamSetCookieLimit filters the upstream's cookies like
+	// UpstreamSetCookieFilter, then drops all but the first
+	// UpstreamSetCookieLimit of the remaining ones.
+	UpstreamSetCookieLimit UpstreamSetCookieMode = "limit"
+)
+
+// Validate checks that the mode is known. The empty mode is treated as
+// UpstreamSetCookiePass.
+func (m UpstreamSetCookieMode) Validate() error {
+	switch m {
+	case "", UpstreamSetCookiePass, UpstreamSetCookieFilter, UpstreamSetCookieLimit:
+		return nil
+	}
+	return fmt.Errorf("unknown upstream set cookie mode %q", m)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gorilla/handlers"
+	"github.com/rs/zerolog"
 
 	"github.com/pomerium/pomerium/internal/frontend"
 	"github.com/pomerium/pomerium/internal/httputil"
@@ -31,6 +32,7 @@ func (srv *Server) addHTTPMiddleware() {
 	root.Use(handlers.RecoveryHandler())
 	root.Use(log.HeadersHandler(httputil.HeadersXForwarded))
 	root.Use(log.RemoteAddrHandler("ip"))
+	root.Use(srv.clientIPHandler("client_ip"))
 	root.Use(log.UserAgentHandler("user_agent"))
 	root.Use(log.RefererHandler("referer"))
 	root.Use(log.RequestIDHandler("request-id"))
@@ -39,3 +41,21 @@ func (srv *Server) addHTTPMiddleware() {
 	root.HandleFunc("/ping", httputil.HealthCheck)
 	root.PathPrefix("/.pomerium/assets/").Handler(http.StripPrefix("/.pomerium/assets/", frontend.MustAssetHandler()))
 }
+
+// clientIPHandler adds the client IP, derived from the X-Forwarded-For chain,
+// as a field to the context's logger using fieldKey as field key.
+func (srv *Server) clientIPHandler(fieldKey string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			options := srv.currentConfig.Load().Options
+			ip := httputil.ClientIP(r.Header.Get(httputil.HeaderForwardedFor), r.RemoteAddr, options.GetClientIPOptions())
+			if ip != "" {
+				log := zerolog.Ctx(r.Context())
+				log.UpdateContext(func(c zerolog.Context) zerolog.Context {
+					return c.Str(fieldKey, ip)
+				})
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
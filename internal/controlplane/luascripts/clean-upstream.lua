@@ -10,10 +10,103 @@ function has_prefix(str, prefix)
     return str ~= nil and str:sub(1, #prefix) == prefix
 end
 
+function remove_query_param(path, name)
+    local base, query = path:match("^([^?]*)%?(.*)$")
+    if query == nil then
+        return path
+    end
+    local params = {}
+    for param in query:gmatch("[^&]+") do
+        if param ~= name and not has_prefix(param, name .. "=") then
+            table.insert(params, param)
+        end
+    end
+    if #params == 0 then
+        return base
+    end
+    return base .. "?" .. table.concat(params, "&")
+end
+
+function remove_websocket_protocol(protocols, prefix)
+    local kept = {}
+    local removed = nil
+    for protocol in protocols:gmatch("[^,]+") do
+        protocol = protocol:match("^%s*(.-)%s*$")
+        if has_prefix(protocol, prefix) then
+            removed = protocol
+        elseif protocol ~= "" then
+            table.insert(kept, protocol)
+        end
+    end
+    return table.concat(kept, ", "), removed
+end
+
+function is_pomerium_cookie(cookie_name, name)
+    if name == cookie_name then
+        return true
+    end
+    -- large sessions are split into numbered chunks, e.g. _pomerium_1
+    return has_prefix(name, cookie_name .. "_") and name:sub(#cookie_name + 2):match("^%d+$") ~= nil
+end
+
+function filter_set_cookies(values, cookie_name, limit)
+    local kept = {}
+    for _, value in ipairs(values) do
+        local name = value:match("^%s*([^=;%s]+)")
+        if not is_pomerium_cookie(cookie_name, name) and (limit == nil or #kept < limit) then
+            table.insert(kept, value)
+        end
+    end
+    return kept
+end
+
+-- has_ambiguous_framing returns true if the request's framing headers could
+-- be interpreted differently by the upstream. Repeated headers are joined by
+-- commas. With the lenient protection, only conflicting content-length values
+-- are ambiguous.
+function has_ambiguous_framing(content_length, transfer_encoding, protection)
+    if content_length == nil then
+        return false
+    end
+    local values = {}
+    for value in (content_length .. ","):gmatch("([^,]*),") do
+        table.insert(values, value:match("^%s*(.-)%s*$"))
+    end
+    for i = 2, #values do
+        if values[i] ~= values[1] then
+            return true
+        end
+    end
+    if protection == "lenient" then
+        return false
+    end
+    return #values > 1 or transfer_encoding ~= nil
+end
+
 function envoy_on_request(request_handle)
     local headers = request_handle:headers()
     local metadata = request_handle:metadata()
 
+    local framing_protection = metadata:get("check_request_framing")
+    if framing_protection then
+        if has_ambiguous_framing(headers:get("content-length"), headers:get("transfer-encoding"), framing_protection) then
+            request_handle:respond({[":status"] = "400"}, "Bad Request")
+            return
+        end
+    end
+
+    -- the rbac filter records whether a PROXY protocol header was sent by a
+    -- trusted peer. If not, the client address envoy took from it is ignored.
+    local rbac_meta = request_handle:streamInfo():dynamicMetadata():get("envoy.filters.http.rbac")
+    if rbac_meta ~= nil and rbac_meta["shadow_engine_result"] == "denied" then
+        headers:remove("x-forwarded-for")
+        headers:remove("x-envoy-external-address")
+    end
+
+    -- the authorize service's timings are only meant for envoy, even if
+    -- server timing headers aren't enabled for the proxy
+    headers:remove("x-pomerium-server-timing")
+
     local remove_cookie_name = metadata:get("remove_pomerium_cookie")
     if remove_cookie_name then
         local cookie = headers:get("cookie")
@@ -31,8 +124,89 @@ function envoy_on_request(request_handle)
             headers:remove("authorization")
         end
     end
+
+    local remove_query_param_name = metadata:get("remove_pomerium_query_param")
+    if remove_query_param_name then
+        local path = headers:get(":path")
+        if path ~= nil then
+            headers:replace(":path", remove_query_param(path, remove_query_param_name))
+        end
+    end
+
+    local remove_protocol_prefix = metadata:get("remove_pomerium_websocket_protocol")
+    if remove_protocol_prefix then
+        local protocols = headers:get("sec-websocket-protocol")
+        if protocols ~= nil then
+            local kept, removed = remove_websocket_protocol(protocols, remove_protocol_prefix)
+            if kept == "" then
+                headers:remove("sec-websocket-protocol")
+                -- the client only offered the token, so no protocol can be
+                -- selected upstream. Browsers fail the handshake unless one
+                -- of the offered protocols is selected, so echo it back.
+                if removed ~= nil then
+                    request_handle:streamInfo():dynamicMetadata():set("envoy.filters.http.lua",
+                        "pomerium_websocket_protocol", removed)
+                end
+            elseif removed ~= nil then
+                headers:replace("sec-websocket-protocol", kept)
+            end
+        end
+    end
 end
 
 function envoy_on_response(response_handle)
+    local metadata = response_handle:metadata()
 
+    local location_from = metadata:get("rewrite_response_location_from")
+    local location_to = metadata:get("rewrite_response_location_to")
+    if location_from and location_to then
+        local headers = response_handle:headers()
+        local location = headers:get("location")
+        if has_prefix(location, location_from) then
+            local rest = location:sub(#location_from + 1)
+            -- only match whole host names and path segments
+            local next_char = rest:sub(1, 1)
+            if next_char == "" or next_char == "/" or next_char == "?" or next_char == "#" then
+                headers:replace("location", location_to .. rest)
+            end
+        end
+    end
+
+    local dynamic_meta = response_handle:streamInfo():dynamicMetadata():get("envoy.filters.http.lua")
+    if dynamic_meta ~= nil and dynamic_meta["pomerium_websocket_protocol"] ~= nil then
+        local headers = response_handle:headers()
+        if headers:get("sec-websocket-protocol") == nil then
+            headers:add("sec-websocket-protocol", dynamic_meta["pomerium_websocket_protocol"])
+        end
+    end
+
+    -- pomerium's own set-cookie is added by a filter which handles the
+    -- response after this one, so it's never dropped here
+    local set_cookie_filter = metadata:get("filter_upstream_set_cookie")
+    if set_cookie_filter then
+        local headers = response_handle:headers()
+        local values = {}
+        for name, value in pairs(headers) do
+            if name == "set-cookie" then
+                table.insert(values, value)
+            end
+        end
+        local kept = filter_set_cookies(values, set_cookie_filter["cookie_name"], set_cookie_filter["limit"])
+        if #kept ~= #values then
+            headers:remove("set-cookie")
+            for _, value in ipairs(kept) do
+                headers:add("set-cookie", value)
+            end
+        end
+    end
+
+    local missing_headers = metadata:get("add_missing_response_headers")
+    if missing_headers then
+        local headers = response_handle:headers()
+        for name, value in pairs(missing_headers) do
+            if headers:get(name) == nil then
+                headers:add(name, value)
+            end
+        end
+    end
 end
@@ -0,0 +1,6 @@
+-- the server-timing filter only trusts the authorize service's timings if
+-- ext_authz added them, so any sent by the client are removed before it runs.
+-- Routes which skip ext_authz don't get a server-timing header.
+function envoy_on_request(request_handle)
+    request_handle:headers():remove("x-pomerium-server-timing")
+end
@@ -0,0 +1,13 @@
+-- seconds a client should wait before retrying a request that was shed
+local retry_after = "1"
+
+function envoy_on_response(response_handle)
+    local headers = response_handle:headers()
+    -- envoy marks the 503s of requests it dropped because the upstream's
+    -- circuit breaker was open as overloaded
+    if headers:get(":status") == "503" and
+        headers:get("x-envoy-overloaded") ~= nil and
+        headers:get("retry-after") == nil then
+        headers:add("retry-after", retry_after)
+    end
+end
@@ -0,0 +1,23 @@
+function envoy_on_request(request_handle)
+    local headers = request_handle:headers()
+    local dynamic_meta = request_handle:streamInfo():dynamicMetadata()
+    if headers:get("x-pomerium-server-timing") ~= nil then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_server_timing",
+                         headers:get("x-pomerium-server-timing"))
+        headers:remove("x-pomerium-server-timing")
+    end
+end
+
+function envoy_on_response(response_handle)
+    local headers = response_handle:headers()
+    local dynamic_meta = response_handle:streamInfo():dynamicMetadata()
+    local tbl = dynamic_meta:get("envoy.filters.http.lua")
+    if tbl ~= nil and tbl["pomerium_server_timing"] ~= nil then
+        local server_timing = tbl["pomerium_server_timing"]
+        local upstream = headers:get("x-envoy-upstream-service-time")
+        if upstream ~= nil then
+            server_timing = server_timing .. ", upstream;dur=" .. upstream
+        end
+        headers:add("server-timing", server_timing)
+    end
+end
@@ -9,6 +9,6 @@ import (
 const Luascripts = "luascripts" // static asset namespace
 
 func init() {
-	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\x88\xbd\xbbP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00clean-upstream.luaUT\x05\x00\x01P\xfb\xce^\x94S\xc1n\x9c0\x10\xbd\xf3\x15O\xf4PV%\x91z\xdd\xc8\xff\xd0{\xd5\"\x17f\x17\xab`\xbb\xf68\x9b\xe4\xd0o\xaf\x00\xc3\xe2\x00\xaa\xe2\x03\x1e\xcb\xf3\xde<\xde\x8c/A\xd7\xac\x8c\x86\xa3\xde<SeMON\x85\xbe\xaa\x8d\xf9\xad\xa8\x98\xb6J\xcb\x9eJL\x87S\x06\x00\x0f\x0f\xe8\x82Dc\xc8\xeb\xcf\x0c\x1f\xac5\x8ea\xec\xc0&;\xd4\xd2rp\x84\xab3\xc1\xfa\x19\xe2\x0dn\x04G\xb6\x935\x81oj\xf8\x1a\xb4R7\x1da..^^\xdf \x19\xdc\x12H70\x971\xf4\xec\x94\xbe\x8eT\x93\x12\x88\x18\x9c\xaf>\xfcZk\xc5\xe3#r\xf1\xfd\xe7\xd3\x8f/O\xc8K\xe4\xf9\xe9\xa3\xb8\x15\xca\x11\x07\xa7#&#\xddd\xd9\xe2[+}e\x1d]\xd4K\xe1\xd9\x95\x98\xe2\x04\xe7\xd9\xe1\xaf\x80V\x1d\xa4n\x86\xe3y(\xfb\xb5\xc4\xa7\x98\x0d!\"\xf0\x1d;\xe9g\xf3Z\x19]9\xfa\x13\xc8s\x11\xf7jrl*\xd3\x99ZvhI6\xe4<\x04\xd2\x9cs\xbc(\xd6\xc9=\xb1l$\xcbm\xf6|S\x9c\xb2U~\x9c\x8e\xb5Sb!9_\x89\x8b|\x7f\x80\xa2\x83\xea\xb2G\xc1-\xe9\xf1\xfa^hiPT=q'\\\x91/fFc\x13\xaaai\xba-\\\x07\xb3\xbdU\x94\x8e\xf8\xbcf)ql\x179\xe5\xbd\xc8\x1d0\xf4o\xde\xb7\x06\xca\xc0\xadq\xeaM\x8e\xdd\xfd\x9f\x85I\xf6\xc6\xc9\x94k\xc7\xcb\xf7\xc5\x12K\xf7\xb8\x0f\xa0q\xbe!\x90\x7f\x8b\xd2\x90\xaf[\xb1z\x03	\xb0\xdc\xe59m\x9buwx\xf8\xb3cqks\x0f\x1f\x8a\xb7F\xfb\xa1\xbbS\xb0<\x95\x11\xf1/\x00\x00\xff\xffPK\x07\x08\xfb\x06j<\xa2\x01\x00\x00\xf0\x04\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\x88\xbd\xbbP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x00	\x00ext-authz-set-cookie.luaUT\x05\x00\x01P\xfb\xce^\x8c\x91An\x830\x10E\xf7\x9cb\xc4\xcaHI\x0e\x80\x94\x03t\xd1\x13T\x955\xc5C\xb0j\x8fS{\x88\x9aM\xcf^AM\x04\x0dM\x19	\x01\xe2\xff?\xf8\xbf\xb6\xe7Fl` \xbe\x84\xab\x0e\xac#}\xf4\x94D\xe5\xbb\xee\x90\x8d\xa3\xaa\x00\x00p\xa1A\x07\x1d\xa1\xa1\x98\xe0\x08KM\x9d?\xa8\xb9\xd8\\\x19\xbdm\xb4'\xc1{G\x92H\xe8\x9f\xb8\x0d\xaa\xaa\xb3\xf4\x99\x04\x0d\n\xe6\x18\xdbN\x0b\xeb\x13\x89*?\xf7\xe7\xe0)\xda\xde\xef\x13\xc9\xbe	\xe1\xddRY\xc1\xd7\x11\xd8:\x90\x8ex\xf4\x0d3_^\xa7\xc1=\x1e\xf3\xd0Z'\x14\xd3\xa1\x139\x1f\\\x8f\xe5\x0e\xca)U'\x12\x9dSw\xb7\xa4\xbb\xd9\xf2OU\xf1[\x1d\xc9\x87\x0b\xfdi\x18\xf5\xc4\xa6\x18\xaeb\x8dM:\x07N\xa4\xa6\x87\x7f\xe8,D\xdb\xf0,-\x1b\xf8\xfc\xe4\xc8\x9b\x83\xe3\xb2\xef\xd3\x83\xbeoh\x07_&\x87l\x86\xd7\x97U\x12\xaf\xab|\xa7Z\xd1\x18U\xce\x8a\xdc=\x08Z\x96\xfc\x1d\x00\x00\xff\xffPK\x07\x08\x93\xe7\xad\x94\x07\x01\x00\x00\x00\x03\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x88\xbd\xbbP\xfb\x06j<\xa2\x01\x00\x00\xf0\x04\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00clean-upstream.luaUT\x05\x00\x01P\xfb\xce^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x88\xbd\xbbP\x93\xe7\xad\x94\x07\x01\x00\x00\x00\x03\x00\x00\x18\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xeb\x01\x00\x00ext-authz-set-cookie.luaUT\x05\x00\x01P\xfb\xce^PK\x05\x06\x00\x00\x00\x00\x02\x00\x02\x00\x98\x00\x00\x00A\x03\x00\x00\x00\x00"
+	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\x1aMO]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00clean-upstream.luaUT\x05\x00\x01\xa5\x9f\xd0j\xa4Y\xdfs\xe3\xb6\x11~\xf7_\xb1C\xe7r\xe2\x99Rs\x99>9Uo&oy\xe84\x93\x97\xb6\xe3\xf1q r)\xa2\xa6\x00\x06\x00-;\x99\xe4o\xef,~\x90\x00I\xd9\xbe\x9e\x1el\n\\,\x16\xdf~\xf8\x00\xac\x9aAT\x86K\x01\nO\xf2\x11\xcb^\x9eP\xf1\xe1TVR>p\xdc\xb8\x7f\xa5`',\xc0}\xc9\xaf\x00\x00\xb6[\xe8\x06\x06\xb5D-\xde\x1b\xd0C\xdfKe@\xf6\xe4\x8duP\xb1\xde\x0c\n\xe1\xa8\xe4\xd0\xeb\xd0EK8#(\xec;V!\x983\xa7\xbf\x12Z&\xea\x0e!\x0c\xbe\x7fz\xfe\x0d\x98\x01\xd3\"\xa0\xa8A6\xf6Q\x1b\xc5\xc5\xd1\xbar\x91\xc0\xde?\xdc\x1e\xf5p\x88c\x85\xdd\x0e\xb2\xfd\xdd\xe7\x1f\xeeo~\x80\xac\x80,\xcb\xbf\xb4_\xd4K\xa1\x19\x94\xf0c]\xa1\xa8\xaf\xaeF\xdcZ\xa6\xcb^a\xc3\x9f6\xda\xa8\x02\xdcs\xd2O\x1b\x05\x7f\xeeA\xf0\x0e\x98\xa8\xe9\xeb-\x85\xfb\xb1\x80ko\x0d\xfb\xbd\xef8\xf3\xee\xb3\xf2\xeb\x80\xea\xb9\xec\x99b\xa7M\xcfL[\x00\x05\xeb\x06\xe9d\xc5:80\x8d\x05X;\xd8\x03\xd9\xdc\x9e\x98\xa9\xdaM\xf6ys\xf7\xf9\xd3\xfd\x87\xfc\xdd\xa7\xcd\xeeC\xfe\x8d\x07\x827\xc1\xd8\x05fZ\x14\xd6]\x147y\xb1m\x14\xd34\x94\x0dC\xc3\x1e~\xff\xc3\xb66R\xb96\xe0\xc29\xbd=\xfa\xb1\xef>\x7f{\x7f\x93\xe5P\xcb\xd17o\xbc1!B\x99\"H\x8441\x90\xd6\xc0\xcd\xd1%2\xcb\xd3\x00\xe9c\xd8\xa1\xc3\x1d\x17\x1a\x95q=t\xe1\\\xe7\xa3]\x08<\xfc\xe7\x0d\\\x87\xf0\xf7\xf0\xdd\xea\xac	\xc9d\xd6Q\xbb\x8d\xe6SF\xff\xdc\xf0\x95\x14\x15\x9b\x86\xcf\xbe\xcd\xf2\xf5\x0c\x9e\xf1\xa0e\xf5\x80\xa6\xec\x954\xb2\x92\xdd&<\xe8\x944.\x9f\x0f\xd8\x9b	b\xd7\xe6\\\xd5`36A\xef\xdd\x10\xfa\xa3\xcb(\x03\xc5<\x03c\x87\xfd\xf88r\xe5\x9d\xfe\xb0\xd9m\xf3w\xfaC \x8a\xcfY\x9c\x1d\xdfi\x0c{\x99\x9b)\xd2`<\xbe\xc6N#o\xc6v\xa2A\x96\xbd\x92]\x02\xa3\x18]]N\xaf\xcfT\x92\x1a\xd7\x97\x16s^\x84\xb8f)\xe2\xfae\xd9\x9b\xd6\x1ao\xec\x17\"O,\x1aI\xf4!\x085\xa44\xdan\xa1c\xea\x88\xa0Qk.\x85\x06\xa6\x10t\xdfq\x03\\\x18	b8\x1dPa\x0dU;\x88\x07]\x00\xee\x8e;\x98B\xfb\x18\xb31\xcaG\xac\xcd\x93\x88\x95Y\xee\x96\x16;\xa1\x95\x9b\xeb8\xe2\x1b\xf8>\x9f\x92^\xdf|\x93\xe5^\xa2f\xd84\xbc3\xa8J\x8d\xc6\xef\x08z\xf3\xc8\xba\x01u\x01	F\x1d?qs\x99\xc0\xa4\x11e\x01\xb6+\x11\x95\xf7\x8c\xab\xe0*\xd1\x07\xd7\x9b\xa2\x86\xbd\xb3O\xc8y\xf7y\xff\xc3;}\x7f\x93\xa7\xf4$\x01yS\x1a-&\x1b\x1bm\x90>\xa9\xe0\xdaF\xfb7?\x8b7\xb1\xd1\x866\xc5\x10\xc4b&\x1a\xe4\xd7!\xba\xdd\xda\x9c\xb1\xd3\x81\x1f\x079\xe8\xb2Q\xec\xc4\xc5\xd1\xf3E[\xc2\x10\xc1h\xa7S\xf8\xeb\x80\xda\xbc\xd7\x10\xacZd5*\x0d\x95\x1c\xba\x9a\x9c\x1d\x08G\x83\xaaWh\xb0\x86\x9a7\x0d*\x14\xa6{\x86\xc3\xb3u2\xf4\xda(d\xa7\x1d\xfc\x82=2\xb2\n^\x88y\xff\x95\\`\x0d\x87g\xf2V\xc9\xd3\x89\xe9\x1d\xfc\x8b\x9b\xd6v\xeePp\x14\xc6.:\xb4T(@\x8a\xee\x19*)\x9a\x8eW\x86B\xaf\xa40(\xcc\xb6Cq4\xad\x83D\x93;\xf2?\xcet7\xb1i\x15\x81\x8dwS:7\x05\x18\xc5\x84nP\x95(*Ysq,\xa20\xc6\xed+\xed\x15\x92\x99\xa4\xce'\xa1a\xddL\xd2\x1d\xc9\\\xbc\xe9F62t\x16\x15I~Vd\xf9(\xac\x1bR\xd6\x0fy\x91jk\xb2)\x85\x85\xb2d\xf1(\xb1y\x12\x16\xad\x12\x0e{\xf8\xbe\x80k\x1f\\\xb42x\xe3#\xbe\xe3\xf7\xb4X\xfd\x97\x8f\xf7K\xbe\xce5(\x1e$\x0c\xe6E\xd8%\x97\xe0\xcb|\xcegj|\x11D\xff\"D\xfaw\xf8HKi\x91\xbcu]A\xf1(\x9fK)JO\xf5\x8d\xff_\xba\xa3`\xac$\x81\xb4{Hmn\xfd\x8bMl|B\xc3jf\xd8\xd2:\xbc\xd9\xe4W\x91\xbd\xe7`9\x11\x0c\xf6\xa3\x93\xdb#\x9aMV\xb5X=\x848\xc3\xb2\xf5\xf2\xc3\x9b5\x0f	~\xbc\xb9@{\x1f\xbe\x1f$YJ\xb4W%\xaf\x03\xaa\xdb\xb0$\xc8b9\xf2\xeaV\x9c\xa0\xa0P\xf7R\xd4\x9b\xdf\xef\xb2[m\x98\x19tv\x0f{\xc8\xfe\xfa\xddw\xd9\x1f\x05d?\xb2\x1a~q]\"\x85\x9dx\xb0J\xa7p\xba'\xe1P\x07V\xf9]\x03\x14VR\xd5\x1a\xce-\x9a\x16\x150\xf8\xf9\x97\x7f\xfe\xfb?\xe3N\xee\xe7\x08g\xa6A\x93\xda\x1c\x9e\x81\x8d\xce\xd4\xa0I\xb3zD\xb5\x83\x9f\xac\xc6\x17v\x88\xaa#\x9a\x02\xabk\x85Z;*\x81\x91\xf2\x01\x1a%O@\xfb\xa9\x06~\x14Ra\xbd\x8brM\xa1\x95'\\#\x87S\xca\x9fD#7\xf9m\xfd,\xd8\x89W\xff\x18	\xe3Rd\xc7\xd9\xb9\xa9\xe9]kL\xbf#\x8f\x13\x13&\xff\xd1i\x7fl\xbc\xcbt\xcbjy.Q\x1c\xb9\xc0R\xa1\x1e:C\xe8\xef!\xabi\xe9\xd5\xb3\x95\x17\x18\xe0N-\x9b\xeci\xdbHuf\xaa\xc6\x9a\x9e\xb2\xfc\x05K\x1b\xec\x16\x9f\x0c*\xc1\xba\xad\xc7*\xcbWs\xc6\x06\xd3J\xc5\x7f\xa3\xb3\x89z\xe4\x15\xbe\xd7`8q\xcbm\x15V\xf8O\xc8\x84\xb1\x12e}\x17\x80\x8f(\x807!_\x1a\xd5#*\xdf/DD\xdd\xe9z\x88\x82\x84\xb1\xb6\xdd)\x85\xbd\x92O\xcfW\xeb\xa1\x87\x03\xcf\xd6y\xdc:\x8fY\xb2l\xfd\xbd(>\xd2\xcc\x97\xad7\x19\x8fO\xce\xd6#\xc0\x9b5\x17	\xfaNO\xc6\x0b\xe3l\xb5F\xbe\xc6\xfd\x88\xdaB\xea\x13W\xf4\x11x\x1e}\xad\x87\xb6YF\x94^\xb9\xc3'\x84\xe2\xaf\xd1c8\xc54\xc8\xd4!\xa8\xf5\x98\xf3\xf8.Q\x86\xcc3\xab[\xafA\x98X/\x90L}\xad`\x99\x1a\xcc ]\xf3=\x85\x9b\xbc\xf5\xf7m\x92\xad\x9f=\x84\x90\xcd\xf5\xd6_%\x93\x8e\xc5\xaa\x9f\x15\xd1\x9c\xaf\xa7\xb5\x89\xbf\x0entk\x7f\x1bE\xa3\x0e\x0bt\x17\xceV\x00\xa6\x0b\xfb\x1c\xd7[j\xcc\xf2\x18\x1ej\xb9\xc8\xd3\x05\xb9\x9c\x83b%\x0e_\x8aX\xbe\xb0\xb3\xf5g\x9b\xd7a\n\xbb\x81O\xc7\xab(-\xaf\xd2\x0b\xb0\xe6.\xd7\xb0\xf2&z\x0e\x98\xc6j;\x0e\xb1\x9d\x0d\x11\x10\xf4\xad\xfa\"\x8c\xd3->\x00D\xb7a?\x95\x97\x8b\x01a\xbe\xe9\x1c&4}\x0c\xe4\xdb\x1e\xdbf\xbb\xc62\x91\x04\xc9\x1b\xe6\x15>\xdbm\xbc\xcbZ\xed\x97\xf6rQ\xd3@`\xe4\x03\x8a\x82*yBN;y\xc5\x04\x1cp\xcd\x97\xc6\x0e+\xda\xc8\xa7\xfb\xc8\x8fJ\x9e5\xddf\x1a\xe6JO\xb6\x00\xa8[\xf6\x800\x88\x8e6u)V\x9d\xf9J`\x88'\x0c\xaf\x81\xebq \x1b\x1bV\xad\xa4\x93\xc0\x81U\x0f\xbb\x85\xa7Q\xfe\xeb\x8b	\x0c\x9f/;(\xe8\x0b\x07\x85n`Y\xb1\x08#|\xb2Q\x01\x96\xcc\x18W^\x9d2 ^W\xb3\xda\xca[\xa6\xb6X\xe6\x17\xe8QX\x9e\xa5C\x87-%\x0e\x81\xda.\x9e\xf0\xe9\xc8\xa9i\x7fs\x0f+g\xfc\xa0\x8bv\x8d$V\x17\xce\xed\xb4\xbeh\x98\xd2\x1e\xf9\x96\x92qV\xdc\xd83\x96\xf3\x95\x98g\xf9\x9a##\xbf\xc0\x8d\x91\xde	of\xa1P}al12E\x9f^\x84S\xaf^\x99kz\xa3Y\x069\xd7\xaa0\x90\x0f\xc6+CT\x1b\n\x06E\x1a\xe5\xca\x96\x17\x8e\x05\x9a\xaa6\xc1\xd8\x15\x8e\x92\xaep\x03\x1f\xf3\xab\xf9\xb2$\x91\xb0\x17\\8\xb7\xb2Ch\xa56\xb6\x88\xa3- \xb4Q\x80\xc6\xe3	\x85\xd1Ig_\xee\xc1'SV-S\x0e\x13\x13\xea\xe3\xb3\x91x\x13[Z\xe9\x93*\xee\xbc\x87\xec/+m\x9fV\xda\xae_\x95M\x7f\xb8\n\xb3\xcf\"\x0c\x8d\xa4z\x00E\xfa\xb6\x95\x111\xdd_.\xa6{H\xca\x80\xff\xf3\"B\xfa2\x122\x19\xc2\x8b\x00e!n\xbf{Qs\xeeC\xb7\xafd/oF\xa1yqo]\xad\xde\xc42\xc5\xea\xfa\xe2\x0eV|\xc9\xc4\xf2\xcb\xf9\xd9n\xc7\x1f\xa0\xdek\x90g\x01\x1a\xcd\xd6\x1f\xda\xb9\xa6\xfb\xa6\xad\x97\x01\x0b\xf7\xdbs\xcb\xab\xd6\xffx\xa5	\xabp\x17\n\xc8\x00k\x0c\xdd\x89Znw4\xbb3q*\xeb	\xa4\xbbR\xadd\xdf\xdb\xb2\x9c\xc2\x88\"S\xb1\xb5\xf4\x03\xcd\x85\xc9We\xc3\x8e\x1a\x95g'\x16,\xbd|e2\xd7\x8bf\xe1g\x08Z\xecQ\x81\xd7\xd5w}\xf2\x93\x12\xd9\xac\x8e\x9eM _X\x91\x97kjSlqB\xe7\xcf\xc9\x0f*/\xd4\xb3\x17\x80\xdd\xf9\x8b\x95=\xcef\xf7\xab\x16\xb6b\x1c\xd3\x8a~b\xb2c\xfd\xb9\x1fKc\x8bi\xcd\xef\x17\x11\x06\xe9\x9c.\xd4\xcdi\x80\x05\xa8+\xebe\xf4\xea]\xa4\xce\xdf\xa0V'\xae5\x17\xc7\xd2\x07\xbc \"\xab\xeb2\xd8L\x0cr\xc6\x13\x15\xe7^\xberO\xbcH\xb7\xd98k\xb4\xf3\xaf\xac\x1cQZ/K\xcf\x1c\xce\x88\xe0o\x83\x11E}\xf5\xbf\x01\x00PK\x07\x08\xe3N\xd9\xec\xd9\x08\x00\x00_\x1f\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\x94q)Q\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x00	\x00ext-authz-set-cookie.luaUT\x05\x00\x01\xd8\xe2X_\x8c\x92Qn\x830\x0c\x86\xdf9\x85\xc5S\x90\xda\x1e\x00\xa9\x07\xd8\xc3N0M\x91GL\x89\x968]b\xaa\xf5eg\x9f`\xa1\x82\x95uXB\x80\xf8\xff\xdf\xd8_\xda\x9e\x1b\xb1\x81\x81\xf8\x12\xae:\xb0\x8e\xf4\xd1S\x12\x95\xef\xbaC6\x8e\xaa\x02\x00\xc0\x85\x06\x1dt\x84\x86b\x82#,5u\xfe\xa0\xe6bse\xf4\xb6\xd1\x9e\x04\xef\x1dI\"\xa1\x7f\xe26\xa8\xaa\xce\xd2g\x124(\x98cl;5\xacO$\xaa\xfc\xdc\x9f\x83\xa7h{\xbfO$\xfb&\x84wKe\x05_G`\xeb@:\xe2\xb1\xfdP\xf3\xe6u\x1a\xdc\xe3\x98\x87\xd6:\xa1\x98\x0e\x9d\xc8\xf9\xe0z,wPN\xa9:\x91\xe8\x9c\xba\xbb%\xdd\xd5\x96\x7f\xaa\x8a\xdf\xeaH>\\\xe8O\xc3\xa8'6\xc5p\x15kl\xd29p\"5=\xfcCg!\xda\x86gi\xd9\xc0\xe7'G\xde\x1c\x1c\x97\xfb>=\xd8\xf7\x0d\xed\xe0\xcb\xe4\x90\xcd\xf0\xfa\xb2J\xe2u\x95o\x9e\xa8FcT9;\x0d\xbb\x07A\xcb%\x7f\x0f\x00PK\x07\x08\x93\xe7\xad\x94\x06\x01\x00\x00\x00\x03\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\x1aMO]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x00	\x00remove-server-timing.luaUT\x05\x00\x01\xa5\x9f\xd0j\\\x8eMn\xc20\x10\x85\xf79\xc5\x13\x1b@\xaa9\x00\xc7\xe8\x05\"\x13\xbf`\xab\xc9\x98\xce\x8c)\xe1\xf4U\x9a.JW\xa3y\xfa\xdeO\x08\xf0L\x18\xf5N\x0d^\xe6\"W\x8cer*\xaaL\x0b\\\x9b\xb9\xfd@\xb1y\xaeZ\x9e\x1b^\x06\xee\x0d\x9b\xc3P\xc6.\x04\xf0\xe1\xfdJ=\x11SbZ]\xf3\x1b\xac\"\xca\x02\xa38.\xcb*b\x98\xca\xfaE%\x94s\xbd3\xe1\xc2\xb1*Q\x1c\xda\xc4Nk\xdc{mN\xc3W.C\x86}\x94\xdb\x9f\x82Te\xef\xb8\xd2\x11\xff\xad\xcf\x8c\x89z\xea\xc6&\x83\x97*\xa0\xdc\xeb\xd2W\xe9\x95\x9f\x8d\xe6\x87\xdf\xdb\xe7(i\xe2\xb1\x03\x80W\xed\xbc\x85\xd8\xe1x\xde\xf6\x1dv\x8fp\xab3\xb5\xb49\xbc\xf4\xed\x8e\x1d%u\xdf\x03\x00PK\x07\x08j\x1aKK\xca\x00\x00\x00I\x01\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\x15LO]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x00	\x00retry-after.luaUT\x05\x00\x01\xbb\x9d\xd0jt\x91\xc1\x8a\xe30\x10D\xef\xfe\x8a\xc2\x97M`\x0dYB.\x01\x7f\x8b\xe9X\xe5X\xc4iy\xd5\xedds\xd9o\x1fb\x8f\x99\xc9\x0c\xa3\x93@O\xd5U\xd5U\x05c\x9b4\x18\x04\xed\x10\xa9\x0e\xeb\xd34\x04\xdc%:N\xecR&2=?\xa2\x9e!\xc8\xfc;\xd1\x1c\xde\x8b\xe3.\x06\xeb\x19\x8a!\xb52,X#\x9d3\xa3F\xf9\xa7,\x8an\xd2\xd6cRPo\xe9\xd1$m2mLj\xdc\xac\x97\xa6\x17\x0d\x03\xb7\x05\x00,B=%0\x1bj|\x81\x8e\xef/\x9b\x85\xae\xaaE\x17W\xc9\x17\x83\xf7\xc4a\xb77\xa4n\xf5i\x88\x8e\x90\xd382\xe0\xc4V&\xe3\xccM\xa3y\xa6\\\x7f\xd9\xaa\xd4\xc6\xdcN\xcf\xcc\x99ra\x9e\xc3\xa5\x91\n1\xa4\x1b\xf3\x90$0\xcct\xecV\x8b\xc73}S\x1e\xcd\xc5'+\xb7\xa8k\x94\x87\xdd\xbe\x84\xe8\x82>\xcf\x0b\xfb\xaf\x9a-W\x1f\x92\xe5\x16\xffkh\x1c~\xfe47[\xcd\xcd.C\x9e\xb4\xf7\xd4o3$\x84W\xfc\xf7\xe7\xb5,\xb5QCA\x0d\xc5\xdb\x00PK\x07\x08P\xa8g=\x0f\x01\x00\x00\xfe\x01\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xd6CO]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x11\x00	\x00server-timing.luaUT\x05\x00\x015\x8f\xd0j\x8c\x93[n\xab0\x10\x86\xdfY\xc5\xc8O \x01\x0b\xe0\x88\x05\x9c\x87\xb3\x82\xa3\n\xb9x\x08\x96|\xa1\xf6\x105/]{e\xb0Q\x9c\xa4i,E\xf1e\xfe\xdf3\xf3\x99i5#Ik\x00\xcd\xd9^\x06k\x06\x87\x1f+z*\xe3\xff0s#\x14V\x05\x00\x80\xb2#W0#\x17\xe8<\xf4\x90\xc7t\xf1\xa0\xbc\x0e\x16\x17\xc3\xb5\x1c\x07\x8d\xc4\xef\x15\x9e\x1cr\xfd\xd7L\xb6\xac\xba\x18\xfa\x0f\x89\x0bN<\xda\xc8)]\xd8\x9d\x90J\xf6\xd9,V\xa3\x93\xabn<\xba3\xba\x86\xa4\x96\xe6\xc4*\xf8\xea\xc1H\x054\xa3\xd92\x08\xe3\xfa\xfe\xce\x07\x83\xad\xd2v\x92\x8a\xd0\xf9v&ZZ\xb5rV\x03K\xc6\xc3n<D\xe3\xfa0\xbb\x1b/fV\x15\xb7\x02\x87\xda\x9e\xf1\x99f\x93\xa0\x11E\xf8\x15\x8f8\xf9\xc5\x1a\x8fe\x9a\xfcB*\x0bz\x0dU.y\x81\xd5\xeeC\xef\n\xfa\xbc\xf1\xa7'\x8d?0\x07]D\xc8\x8d\x08\xcb\xff?!y{\xc8z\xbf>\x83\x07\xfds\x9f\x03\xcc\xae]\x97\xbdJ\xe8o\xd1n}o\xd2\xf9\x06K\x8e\x18\x08c\xac VqX<\xca0\x8c\xdb\xfc\xf2u\xdb\x02\xab\x0f\x93?bu=\x0b\x9bi\xe7\xb0\n\xcf\"\xcdS\xae\\\x88\x92\xe5_E\x9d\xfb\xe7\xcf\xea{\x00PK\x07\x08.\x99Y+F\x01\x00\x00\xfe\x03\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x1aMO]\xe3N\xd9\xec\xd9\x08\x00\x00_\x1f\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\x00\x00\x00\x00clean-upstream.luaUT\x05\x00\x01\xa5\x9f\xd0jPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x94q)Q\x93\xe7\xad\x94\x06\x01\x00\x00\x00\x03\x00\x00\x18\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\"	\x00\x00ext-authz-set-cookie.luaUT\x05\x00\x01\xd8\xe2X_PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x1aMO]j\x1aKK\xca\x00\x00\x00I\x01\x00\x00\x18\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81w\n\x00\x00remove-server-timing.luaUT\x05\x00\x01\xa5\x9f\xd0jPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x15LO]P\xa8g=\x0f\x01\x00\x00\xfe\x01\x00\x00\x0f\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\x90\x0b\x00\x00retry-after.luaUT\x05\x00\x01\xbb\x9d\xd0jPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xd6CO].\x99Y+F\x01\x00\x00\xfe\x03\x00\x00\x11\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\xe5\x0c\x00\x00server-timing.luaUT\x05\x00\x015\x8f\xd0jPK\x05\x06\x00\x00\x00\x00\x05\x00\x05\x00u\x01\x00\x00s\x0e\x00\x00\x00\x00"
 	fs.RegisterWithNamespace("luascripts", data)
 }
@@ -2,11 +2,15 @@ package controlplane
 
 import (
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/testutil"
@@ -303,3 +307,182 @@ func Test_buildCluster(t *testing.T) {
 		`, cluster)
 	})
 }
+
+func Test_buildPolicyClusterConnectionPool(t *testing.T) {
+	policy := &config.Policy{Destination: mustParseURL("http://example.com")}
+
+	t.Run("defaults", func(t *testing.T) {
+		cluster := buildPolicyCluster(&config.Options{}, policy)
+		assert.Nil(t, cluster.CommonHttpProtocolOptions)
+		assert.Nil(t, cluster.CircuitBreakers)
+	})
+	t.Run("configured", func(t *testing.T) {
+		cluster := buildPolicyCluster(&config.Options{
+			UpstreamIdleTimeout:    90 * time.Second,
+			UpstreamMaxConnections: 64,
+		}, policy)
+		testutil.AssertProtoJSONEqual(t, `{
+			"idleTimeout": "90s"
+		}`, cluster.CommonHttpProtocolOptions)
+		testutil.AssertProtoJSONEqual(t, `{
+			"thresholds": [{
+				"maxConnections": 64
+			}]
+		}`, cluster.CircuitBreakers)
+	})
+}
+
+func Test_buildPolicyClusterRequestLimit(t *testing.T) {
+	t.Run("websockets disabled", func(t *testing.T) {
+		policy := &config.Policy{
+			Destination:             mustParseURL("http://example.com"),
+			MaxWebsocketConnections: 10,
+		}
+		assert.False(t, hasPolicyWebsocketCluster(policy))
+		assert.Nil(t, buildPolicyCluster(&config.Options{}, policy).CircuitBreakers)
+	})
+	t.Run("configured", func(t *testing.T) {
+		opts := &config.Options{UpstreamMaxConnections: 64}
+		policy := &config.Policy{
+			Destination:             mustParseURL("http://example.com"),
+			AllowWebsockets:         true,
+			MaxWebsocketConnections: 10,
+		}
+		require.True(t, hasPolicyWebsocketCluster(policy))
+
+		cluster := buildPolicyCluster(opts, policy)
+		testutil.AssertProtoJSONEqual(t, `{
+			"thresholds": [{
+				"maxConnections": 64
+			}]
+		}`, cluster.CircuitBreakers, "the websocket limit shouldn't apply to other requests")
+
+		websocketCluster := buildPolicyWebsocketCluster(opts, policy)
+		assert.Equal(t, cluster.Name+"-websocket", websocketCluster.Name)
+		assert.Equal(t, websocketCluster.Name, websocketCluster.LoadAssignment.ClusterName)
+		testutil.AssertProtoJSONEqual(t, `{
+			"thresholds": [{
+				"maxConnections": 64,
+				"maxRequests": 10
+			}]
+		}`, websocketCluster.CircuitBreakers)
+	})
+	t.Run("in-flight limit", func(t *testing.T) {
+		opts := &config.Options{ProxyMaxRouteInflightRequests: 100}
+		cluster := buildPolicyCluster(opts, &config.Policy{
+			Destination: mustParseURL("http://example.com"),
+		})
+		testutil.AssertProtoJSONEqual(t, `{
+			"thresholds": [{
+				"maxRequests": 100
+			}]
+		}`, cluster.CircuitBreakers)
+
+		cluster = buildPolicyWebsocketCluster(opts, &config.Policy{
+			Destination:             mustParseURL("http://example.com"),
+			AllowWebsockets:         true,
+			MaxWebsocketConnections: 500,
+		})
+		testutil.AssertProtoJSONEqual(t, `{
+			"thresholds": [{
+				"maxRequests": 100
+			}]
+		}`, cluster.CircuitBreakers, "the lower limit applies")
+	})
+}
+
+func Test_buildPolicyClusterRouteMetrics(t *testing.T) {
+	policy := &config.Policy{From: "https://from.example.com", To: "https://to.example.com"}
+	require.NoError(t, policy.Validate())
+
+	cluster := buildPolicyCluster(&config.Options{}, policy)
+	assert.Empty(t, cluster.AltStatName)
+
+	cluster = buildPolicyCluster(&config.Options{ProxyRouteMetrics: true}, policy)
+	assert.Equal(t, fmt.Sprintf("route-%d", policy.RouteID()), cluster.AltStatName)
+}
+
+func Test_buildPolicyClusterLoadBalancing(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cluster := buildPolicyCluster(&config.Options{}, &config.Policy{Destination: mustParseURL("http://example.com")})
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_ROUND_ROBIN, cluster.LbPolicy)
+		assert.Nil(t, cluster.OutlierDetection)
+	})
+	t.Run("weighted upstreams", func(t *testing.T) {
+		policy := &config.Policy{
+			Upstreams: []config.WeightedUpstream{
+				{Destination: mustParseURL("http://127.0.0.1:8080"), Weight: 3},
+				{Destination: mustParseURL("http://localhost:8081"), Weight: 1},
+			},
+			LoadBalancingPolicy:      config.LoadBalancingLeastRequest,
+			UpstreamEjectionFailures: 5,
+			UpstreamEjectionTime:     time.Minute,
+		}
+		policy.Destination = policy.Upstreams[0].Destination
+		cluster := buildPolicyCluster(&config.Options{}, policy)
+		testutil.AssertProtoJSONEqual(t, `{
+			"lbEndpoints": [{
+				"endpoint": {
+					"address": {
+						"socketAddress": {
+							"address": "127.0.0.1",
+							"ipv4Compat": true,
+							"portValue": 8080
+						}
+					}
+				},
+				"loadBalancingWeight": 3
+			}, {
+				"endpoint": {
+					"address": {
+						"socketAddress": {
+							"address": "127.0.0.1",
+							"ipv4Compat": true,
+							"portValue": 8081
+						}
+					}
+				},
+				"loadBalancingWeight": 1
+			}]
+		}`, cluster.LoadAssignment.Endpoints[0])
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_STATIC, cluster.GetType())
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_LEAST_REQUEST, cluster.LbPolicy)
+		testutil.AssertProtoJSONEqual(t, `{
+			"consecutive5xx": 5,
+			"baseEjectionTime": "60s",
+			"maxEjectionPercent": 50
+		}`, cluster.OutlierDetection)
+	})
+	t.Run("dns upstreams", func(t *testing.T) {
+		policy := &config.Policy{
+			Upstreams: []config.WeightedUpstream{
+				{Destination: mustParseURL("https://127.0.0.1")},
+				{Destination: mustParseURL("https://b.example.com")},
+			},
+			LoadBalancingPolicy:      config.LoadBalancingRandom,
+			UpstreamEjectionFailures: 3,
+		}
+		policy.Destination = policy.Upstreams[0].Destination
+		cluster := buildPolicyCluster(&config.Options{}, policy)
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_STRICT_DNS, cluster.GetType())
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_RANDOM, cluster.LbPolicy)
+		if assert.Len(t, cluster.LoadAssignment.Endpoints[0].LbEndpoints, 2) {
+			testutil.AssertProtoJSONEqual(t, `{
+				"endpoint": {
+					"address": {
+						"socketAddress": {
+							"address": "b.example.com",
+							"ipv4Compat": true,
+							"portValue": 443
+						}
+					}
+				}
+			}`, cluster.LoadAssignment.Endpoints[0].LbEndpoints[1])
+		}
+		testutil.AssertProtoJSONEqual(t, `{
+			"consecutive5xx": 3,
+			"baseEjectionTime": "30s",
+			"maxEjectionPercent": 50
+		}`, cluster.OutlierDetection)
+	})
+}
@@ -2,6 +2,7 @@ package controlplane
 
 import (
 	"encoding/base64"
+	"fmt"
 	"net"
 	"net/url"
 	"strings"
@@ -13,12 +14,17 @@ import (
 	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/urlutil"
 )
 
+// defaultUpstreamEjectionTime is how long upstreams are ejected for, if
+// ejection is enabled without a time.
+const defaultUpstreamEjectionTime = 30 * time.Second
+
 func (srv *Server) buildClusters(options *config.Options) []*envoy_config_cluster_v3.Cluster {
 	grpcURL := &url.URL{
 		Scheme: "http",
@@ -42,7 +48,10 @@ func (srv *Server) buildClusters(options *config.Options) []*envoy_config_cluste
 
 	if config.IsProxy(options.Services) {
 		for _, policy := range options.Policies {
-			clusters = append(clusters, buildPolicyCluster(&policy))
+			clusters = append(clusters, buildPolicyCluster(options, &policy))
+			if hasPolicyWebsocketCluster(&policy) {
+				clusters = append(clusters, buildPolicyWebsocketCluster(options, &policy))
+			}
 		}
 	}
 
@@ -53,9 +62,128 @@ func buildInternalCluster(options *config.Options, name string, endpoint *url.UR
 	return buildCluster(name, endpoint, buildInternalTransportSocket(options, endpoint), forceHTTP2, false)
 }
 
-func buildPolicyCluster(policy *config.Policy) *envoy_config_cluster_v3.Cluster {
+func buildPolicyCluster(options *config.Options, policy *config.Policy) *envoy_config_cluster_v3.Cluster {
 	name := getPolicyName(policy)
-	return buildCluster(name, policy.Destination, buildPolicyTransportSocket(policy), false, policy.EnableGoogleCloudServerlessAuthentication)
+	cluster := buildCluster(name, policy.Destination, buildPolicyTransportSocket(policy), false, policy.EnableGoogleCloudServerlessAuthentication)
+	setUpstreamConnectionPool(options, cluster)
+	setPolicyLoadBalancing(policy, cluster)
+	setUpstreamRequestLimit(cluster, options.ProxyMaxRouteInflightRequests)
+	if options.ProxyRouteMetrics {
+		// envoy's upstream request stats for the cluster are exported as the
+		// route's metrics
+		cluster.AltStatName = fmt.Sprintf("route-%d", policy.RouteID())
+	}
+	return cluster
+}
+
+// hasPolicyWebsocketCluster returns true if the policy's websocket upgrades
+// are sent to a cluster of their own, so that their connection limit doesn't
+// apply to the route's other requests.
+func hasPolicyWebsocketCluster(policy *config.Policy) bool {
+	return policy.AllowWebsockets && policy.MaxWebsocketConnections > 0
+}
+
+func getPolicyWebsocketClusterName(policy *config.Policy) string {
+	return getPolicyName(policy) + "-websocket"
+}
+
+// buildPolicyWebsocketCluster builds the cluster for the policy's websocket
+// upgrades. It's the same as the policy's cluster, except that it limits the
+// open websocket connections, as an upgraded connection counts as a request
+// for as long as it remains open.
+func buildPolicyWebsocketCluster(options *config.Options, policy *config.Policy) *envoy_config_cluster_v3.Cluster {
+	cluster := buildPolicyCluster(options, policy)
+	cluster.Name = getPolicyWebsocketClusterName(policy)
+	cluster.LoadAssignment.ClusterName = cluster.Name
+	if cluster.AltStatName != "" {
+		cluster.AltStatName += "-websocket"
+	}
+	limit := policy.MaxWebsocketConnections
+	if options.ProxyMaxRouteInflightRequests > 0 && options.ProxyMaxRouteInflightRequests < limit {
+		limit = options.ProxyMaxRouteInflightRequests
+	}
+	setUpstreamRequestLimit(cluster, limit)
+	return cluster
+}
+
+// setUpstreamRequestLimit bounds the number of requests envoy has open to the
+// cluster at once, shedding load beyond the limit. Zero leaves it unbounded.
+func setUpstreamRequestLimit(cluster *envoy_config_cluster_v3.Cluster, limit int) {
+	if limit <= 0 {
+		return
+	}
+	getCircuitBreakerThresholds(cluster).MaxRequests = &wrappers.UInt32Value{Value: uint32(limit)}
+}
+
+// getCircuitBreakerThresholds returns the cluster's circuit breaker
+// thresholds for the default priority, adding them if needed.
+func getCircuitBreakerThresholds(cluster *envoy_config_cluster_v3.Cluster) *envoy_config_cluster_v3.CircuitBreakers_Thresholds {
+	if cluster.CircuitBreakers == nil {
+		cluster.CircuitBreakers = &envoy_config_cluster_v3.CircuitBreakers{}
+	}
+	if len(cluster.CircuitBreakers.Thresholds) == 0 {
+		cluster.CircuitBreakers.Thresholds = []*envoy_config_cluster_v3.CircuitBreakers_Thresholds{{}}
+	}
+	return cluster.CircuitBreakers.Thresholds[0]
+}
+
+// setPolicyLoadBalancing distributes requests across the policy's weighted
+// upstreams, if it has any, and configures envoy to temporarily eject
+// upstreams which fail repeatedly.
+func setPolicyLoadBalancing(policy *config.Policy, cluster *envoy_config_cluster_v3.Cluster) {
+	if len(policy.Upstreams) > 0 {
+		defaultPort := getDefaultPort(cluster.TransportSocket)
+		lbEndpoints := make([]*envoy_config_endpoint_v3.LbEndpoint, 0, len(policy.Upstreams))
+		allIPs := true
+		for _, upstream := range policy.Upstreams {
+			endpoint := normalizeEndpoint(upstream.Destination)
+			lbEndpoint := buildLbEndpoint(endpoint, defaultPort)
+			if upstream.Weight > 0 {
+				lbEndpoint.LoadBalancingWeight = &wrappers.UInt32Value{Value: upstream.Weight}
+			}
+			lbEndpoints = append(lbEndpoints, lbEndpoint)
+			allIPs = allIPs && net.ParseIP(urlutil.StripPort(endpoint.Host)) != nil
+		}
+		cluster.LoadAssignment.Endpoints[0].LbEndpoints = lbEndpoints
+		if !allIPs {
+			cluster.ClusterDiscoveryType = &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_STRICT_DNS}
+		}
+	}
+
+	switch policy.LoadBalancingPolicy {
+	case config.LoadBalancingRandom:
+		cluster.LbPolicy = envoy_config_cluster_v3.Cluster_RANDOM
+	case config.LoadBalancingLeastRequest:
+		cluster.LbPolicy = envoy_config_cluster_v3.Cluster_LEAST_REQUEST
+	}
+
+	if policy.UpstreamEjectionFailures > 0 {
+		ejectionTime := policy.UpstreamEjectionTime
+		if ejectionTime == 0 {
+			ejectionTime = defaultUpstreamEjectionTime
+		}
+		// connection failures count as 5xx responses, and at least one
+		// upstream is always kept
+		cluster.OutlierDetection = &envoy_config_cluster_v3.OutlierDetection{
+			Consecutive_5Xx:    &wrappers.UInt32Value{Value: policy.UpstreamEjectionFailures},
+			BaseEjectionTime:   ptypes.DurationProto(ejectionTime),
+			MaxEjectionPercent: &wrappers.UInt32Value{Value: 50},
+		}
+	}
+}
+
+// setUpstreamConnectionPool configures how envoy pools the connections it
+// makes to the cluster. Connections are always reused across requests, these
+// options bound how long idle connections are kept and how many are opened.
+func setUpstreamConnectionPool(options *config.Options, cluster *envoy_config_cluster_v3.Cluster) {
+	if options.UpstreamIdleTimeout > 0 {
+		cluster.CommonHttpProtocolOptions = &envoy_config_core_v3.HttpProtocolOptions{
+			IdleTimeout: ptypes.DurationProto(options.UpstreamIdleTimeout),
+		}
+	}
+	if options.UpstreamMaxConnections > 0 {
+		getCircuitBreakerThresholds(cluster).MaxConnections = &wrappers.UInt32Value{Value: uint32(options.UpstreamMaxConnections)}
+	}
 }
 
 func buildInternalTransportSocket(options *config.Options, endpoint *url.URL) *envoy_config_core_v3.TransportSocket {
@@ -182,17 +310,8 @@ func buildCluster(
 	forceHTTP2 bool,
 	forceIPV4 bool,
 ) *envoy_config_cluster_v3.Cluster {
-	defaultPort := 80
-	if transportSocket != nil && transportSocket.Name == "tls" {
-		defaultPort = 443
-	}
-
-	if endpoint.Hostname() == "localhost" {
-		u := new(url.URL)
-		*u = *endpoint
-		u.Host = strings.Replace(endpoint.Host, "localhost", "127.0.0.1", -1)
-		endpoint = u
-	}
+	defaultPort := getDefaultPort(transportSocket)
+	endpoint = normalizeEndpoint(endpoint)
 
 	cluster := &envoy_config_cluster_v3.Cluster{
 		Name:           name,
@@ -200,13 +319,9 @@ func buildCluster(
 		LoadAssignment: &envoy_config_endpoint_v3.ClusterLoadAssignment{
 			ClusterName: name,
 			Endpoints: []*envoy_config_endpoint_v3.LocalityLbEndpoints{{
-				LbEndpoints: []*envoy_config_endpoint_v3.LbEndpoint{{
-					HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
-						Endpoint: &envoy_config_endpoint_v3.Endpoint{
-							Address: buildAddress(endpoint.Host, defaultPort),
-						},
-					},
-				}},
+				LbEndpoints: []*envoy_config_endpoint_v3.LbEndpoint{
+					buildLbEndpoint(endpoint, defaultPort),
+				},
 			}},
 		},
 		RespectDnsTtl:   true,
@@ -232,3 +347,32 @@ func buildCluster(
 
 	return cluster
 }
+
+func getDefaultPort(transportSocket *envoy_config_core_v3.TransportSocket) int {
+	if transportSocket != nil && transportSocket.Name == "tls" {
+		return 443
+	}
+	return 80
+}
+
+// normalizeEndpoint replaces localhost with 127.0.0.1, so that it's treated
+// as an IP.
+func normalizeEndpoint(endpoint *url.URL) *url.URL {
+	if endpoint.Hostname() != "localhost" {
+		return endpoint
+	}
+	u := new(url.URL)
+	*u = *endpoint
+	u.Host = strings.Replace(endpoint.Host, "localhost", "127.0.0.1", -1)
+	return u
+}
+
+func buildLbEndpoint(endpoint *url.URL, defaultPort int) *envoy_config_endpoint_v3.LbEndpoint {
+	return &envoy_config_endpoint_v3.LbEndpoint{
+		HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+			Endpoint: &envoy_config_endpoint_v3.Endpoint{
+				Address: buildAddress(endpoint.Host, defaultPort),
+			},
+		},
+	}
+}
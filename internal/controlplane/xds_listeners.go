@@ -9,9 +9,12 @@ import (
 
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_extensions_filters_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	envoy_extensions_filters_http_lua_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	envoy_extensions_filters_listener_proxy_protocol_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/proxy_protocol/v3"
 	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
@@ -52,9 +55,17 @@ func buildListeners(options *config.Options) []*envoy_config_listener_v3.Listene
 }
 
 func buildMainListener(options *config.Options) *envoy_config_listener_v3.Listener {
+	li := buildMainIngressListener(options)
+	if options.UseProxyProtocol {
+		addProxyProtocol(li)
+	}
+	return li
+}
+
+func buildMainIngressListener(options *config.Options) *envoy_config_listener_v3.Listener {
 	if options.InsecureServer {
 		filter := buildMainHTTPConnectionManagerFilter(options,
-			getAllRouteableDomains(options, options.Addr))
+			getAllRouteableDomains(options, options.Addr), "*")
 
 		return &envoy_config_listener_v3.Listener{
 			Name:    "http-ingress",
@@ -79,7 +90,7 @@ func buildMainListener(options *config.Options) *envoy_config_listener_v3.Listen
 		}},
 		FilterChains: buildFilterChains(options, options.Addr,
 			func(tlsDomain string, httpDomains []string) *envoy_config_listener_v3.FilterChain {
-				filter := buildMainHTTPConnectionManagerFilter(options, httpDomains)
+				filter := buildMainHTTPConnectionManagerFilter(options, httpDomains, tlsDomain)
 				filterChain := &envoy_config_listener_v3.FilterChain{
 					Filters: []*envoy_config_listener_v3.Filter{filter},
 				}
@@ -104,6 +115,66 @@ func buildMainListener(options *config.Options) *envoy_config_listener_v3.Listen
 	return li
 }
 
+// addProxyProtocol makes li read the PROXY protocol (v1 or v2) header sent by
+// an L4 load balancer, so that the client address it carries is used for
+// logging, the x-forwarded-for header and authorization. Since envoy requires
+// the header on every connection once enabled, it's read from every peer, and
+// the address is ignored for peers outside of the trusted CIDRs by
+// buildProxyProtocolRBACFilter.
+func addProxyProtocol(li *envoy_config_listener_v3.Listener) {
+	proxyProtocolCfg, _ := ptypes.MarshalAny(new(envoy_extensions_filters_listener_proxy_protocol_v3.ProxyProtocol))
+	li.ListenerFilters = append([]*envoy_config_listener_v3.ListenerFilter{{
+		Name: "envoy.filters.listener.proxy_protocol",
+		ConfigType: &envoy_config_listener_v3.ListenerFilter_TypedConfig{
+			TypedConfig: proxyProtocolCfg,
+		},
+	}}, li.ListenerFilters...)
+}
+
+// buildProxyProtocolRBACFilter builds an RBAC filter which only has shadow
+// rules, so it never denies a request, but records whether the peer which sent
+// the PROXY protocol header is one of the trusted CIDRs in the dynamic
+// metadata. The authorize service and the clean upstream lua script use it to
+// ignore the client address of untrusted peers.
+func buildProxyProtocolRBACFilter(trustedCIDRs []string) *envoy_http_connection_manager.HttpFilter {
+	var principals []*envoy_config_rbac_v3.Principal
+	for _, cidr := range trustedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Warn().Err(err).Str("cidr", cidr).Msg("ignoring invalid proxy protocol trusted cidr")
+			continue
+		}
+		prefixLen, _ := ipNet.Mask.Size()
+		principals = append(principals, &envoy_config_rbac_v3.Principal{
+			Identifier: &envoy_config_rbac_v3.Principal_DirectRemoteIp{
+				DirectRemoteIp: &envoy_config_core_v3.CidrRange{
+					AddressPrefix: ipNet.IP.String(),
+					PrefixLen:     &wrappers.UInt32Value{Value: uint32(prefixLen)},
+				},
+			},
+		})
+	}
+	rbacCfg, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_rbac_v3.RBAC{
+		ShadowRules: &envoy_config_rbac_v3.RBAC{
+			Action: envoy_config_rbac_v3.RBAC_ALLOW,
+			Policies: map[string]*envoy_config_rbac_v3.Policy{
+				"trusted-proxies": {
+					Permissions: []*envoy_config_rbac_v3.Permission{{
+						Rule: &envoy_config_rbac_v3.Permission_Any{Any: true},
+					}},
+					Principals: principals,
+				},
+			},
+		},
+	})
+	return &envoy_http_connection_manager.HttpFilter{
+		Name: "envoy.filters.http.rbac",
+		ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+			TypedConfig: rbacCfg,
+		},
+	}
+}
+
 func buildFilterChains(
 	options *config.Options, addr string,
 	callback func(tlsDomain string, httpDomains []string) *envoy_config_listener_v3.FilterChain,
@@ -119,7 +190,9 @@ func buildFilterChains(
 	return chains
 }
 
-func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []string) *envoy_config_listener_v3.Filter {
+// buildMainHTTPConnectionManagerFilter builds the http connection manager for
+// the filter chain matching tlsDomain, or "*" if it matches any server name.
+func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []string, tlsDomain string) *envoy_config_listener_v3.Filter {
 	var virtualHosts []*envoy_config_route_v3.VirtualHost
 	for _, domain := range domains {
 		vh := &envoy_config_route_v3.VirtualHost{
@@ -140,7 +213,11 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 
 		// if we're the proxy, add all the policy routes
 		if config.IsProxy(options.Services) {
-			vh.Routes = append(vh.Routes, buildPolicyRoutes(options, domain)...)
+			routes := buildPolicyRoutes(options, domain)
+			if isMisdirectedDomain(options, tlsDomain, domain) {
+				routes = misdirectProtectedRoutes(routes)
+			}
+			vh.Routes = append(vh.Routes, routes...)
 		}
 
 		if len(vh.Routes) > 0 {
@@ -160,6 +237,11 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 		grpcClientTimeout = ptypes.DurationProto(30 * time.Second)
 	}
 
+	var metadataContextNamespaces []string
+	if options.UseProxyProtocol {
+		metadataContextNamespaces = append(metadataContextNamespaces, "envoy.filters.http.rbac")
+	}
+
 	extAuthZ, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_ext_authz_v3.ExtAuthz{
 		StatusOnError: &envoy_type_v3.HttpStatus{
 			Code: envoy_type_v3.StatusCode_InternalServerError,
@@ -174,7 +256,8 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 				},
 			},
 		},
-		IncludePeerCertificate: true,
+		IncludePeerCertificate:    true,
+		MetadataContextNamespaces: metadataContextNamespaces,
 	})
 
 	extAuthzSetCookieLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
@@ -183,6 +266,67 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 	cleanUpstreamLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
 		InlineCode: luascripts.CleanUpstream,
 	})
+	retryAfterLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
+		InlineCode: luascripts.RetryAfter,
+	})
+
+	var filters []*envoy_http_connection_manager.HttpFilter
+	if options.UseProxyProtocol {
+		filters = append(filters, buildProxyProtocolRBACFilter(options.ProxyProtocolTrustedCIDRs))
+	}
+	if options.ServerTimingHeaders {
+		removeServerTimingLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
+			InlineCode: luascripts.RemoveServerTiming,
+		})
+		filters = append(filters, &envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.lua",
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: removeServerTimingLua,
+			},
+		})
+	}
+	filters = append(filters,
+		&envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.ext_authz",
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: extAuthZ,
+			},
+		},
+		&envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.lua",
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: extAuthzSetCookieLua,
+			},
+		},
+	)
+	if options.ServerTimingHeaders {
+		serverTimingLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
+			InlineCode: luascripts.ServerTiming,
+		})
+		filters = append(filters, &envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.lua",
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: serverTimingLua,
+			},
+		})
+	}
+	filters = append(filters,
+		&envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.lua",
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: cleanUpstreamLua,
+			},
+		},
+		&envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.lua",
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: retryAfterLua,
+			},
+		},
+		&envoy_http_connection_manager.HttpFilter{
+			Name: "envoy.filters.http.router",
+		},
+	)
 
 	var maxStreamDuration *durationpb.Duration
 	if options.WriteTimeout > 0 {
@@ -195,30 +339,8 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 		RouteSpecifier: &envoy_http_connection_manager.HttpConnectionManager_RouteConfig{
 			RouteConfig: buildRouteConfiguration("main", virtualHosts),
 		},
-		HttpFilters: []*envoy_http_connection_manager.HttpFilter{
-			{
-				Name: "envoy.filters.http.ext_authz",
-				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
-					TypedConfig: extAuthZ,
-				},
-			},
-			{
-				Name: "envoy.filters.http.lua",
-				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
-					TypedConfig: extAuthzSetCookieLua,
-				},
-			},
-			{
-				Name: "envoy.filters.http.lua",
-				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
-					TypedConfig: cleanUpstreamLua,
-				},
-			},
-			{
-				Name: "envoy.filters.http.router",
-			},
-		},
-		AccessLog: buildAccessLogs(options),
+		HttpFilters: filters,
+		AccessLog:   buildAccessLogs(options),
 		CommonHttpProtocolOptions: &envoy_config_core_v3.HttpProtocolOptions{
 			IdleTimeout:       ptypes.DurationProto(options.IdleTimeout),
 			MaxStreamDuration: maxStreamDuration,
@@ -435,6 +557,21 @@ func getAllRouteableDomains(options *config.Options, addr string) []string {
 	return domains
 }
 
+// isMisdirectedDomain returns true if requests for domain received over a
+// connection for tlsDomain should be rejected, because strict SNI and host
+// consistency is enabled and the two differ. TLS connections without a
+// matching server name ("*") can't be for any route's domain, while insecure
+// servers have no server name to check.
+func isMisdirectedDomain(options *config.Options, tlsDomain, domain string) bool {
+	if options.SNIHostConsistency != config.SNIHostConsistencyStrict || options.InsecureServer {
+		return false
+	}
+	if tlsDomain == "*" {
+		return true
+	}
+	return urlutil.StripPort(tlsDomain) != urlutil.StripPort(domain)
+}
+
 func hostMatchesDomain(u *url.URL, host string) bool {
 	var defaultPort string
 	if u.Scheme == "http" {
@@ -4,10 +4,16 @@ import (
 	"crypto/tls"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_extensions_filters_http_lua_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/testutil"
@@ -21,7 +27,7 @@ const (
 
 func Test_buildMainHTTPConnectionManagerFilter(t *testing.T) {
 	options := config.NewDefaultOptions()
-	filter := buildMainHTTPConnectionManagerFilter(options, []string{"example.com"})
+	filter := buildMainHTTPConnectionManagerFilter(options, []string{"example.com"}, "*")
 	testutil.AssertProtoJSONEqual(t, `{
 		"name": "envoy.filters.network.http_connection_manager",
 		"typedConfig": {
@@ -71,7 +77,14 @@ func Test_buildMainHTTPConnectionManagerFilter(t *testing.T) {
 					"name": "envoy.filters.http.lua",
 					"typedConfig": {
 						"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
-						"inlineCode": "function remove_pomerium_cookie(cookie_name, cookie)\n    -- lua doesn't support optional capture groups\n    -- so we replace twice to handle pomerium=xyz at the end of the string\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+; \", \"\")\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+\", \"\")\n    return cookie\nend\n\nfunction has_prefix(str, prefix)\n    return str ~= nil and str:sub(1, #prefix) == prefix\nend\n\nfunction envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local metadata = request_handle:metadata()\n\n    local remove_cookie_name = metadata:get(\"remove_pomerium_cookie\")\n    if remove_cookie_name then\n        local cookie = headers:get(\"cookie\")\n        if cookie ~= nil then\n            newcookie = remove_pomerium_cookie(remove_cookie_name, cookie)\n            headers:replace(\"cookie\", newcookie)\n        end\n    end\n\n    local remove_authorization = metadata:get(\"remove_pomerium_authorization\")\n    if remove_authorization then\n        local authorization = headers:get(\"authorization\")\n        local authorization_prefix = \"Pomerium \"\n        if has_prefix(authorization, authorization_prefix) then\n            headers:remove(\"authorization\")\n        end\n    end\nend\n\nfunction envoy_on_response(response_handle)\n\nend\n"
+						"inlineCode": "function remove_pomerium_cookie(cookie_name, cookie)\n    -- lua doesn't support optional capture groups\n    -- so we replace twice to handle pomerium=xyz at the end of the string\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+; \", \"\")\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+\", \"\")\n    return cookie\nend\n\nfunction has_prefix(str, prefix)\n    return str ~= nil and str:sub(1, #prefix) == prefix\nend\n\nfunction remove_query_param(path, name)\n    local base, query = path:match(\"^([^?]*)%?(.*)$\")\n    if query == nil then\n        return path\n    end\n    local params = {}\n    for param in query:gmatch(\"[^&]+\") do\n        if param ~= name and not has_prefix(param, name .. \"=\") then\n            table.insert(params, param)\n        end\n    end\n    if #params == 0 then\n        return base\n    end\n    return base .. \"?\" .. table.concat(params, \"&\")\nend\n\nfunction remove_websocket_protocol(protocols, prefix)\n    local kept = {}\n    local removed = nil\n    for protocol in protocols:gmatch(\"[^,]+\") do\n        protocol = protocol:match(\"^%s*(.-)%s*$\")\n        if has_prefix(protocol, prefix) then\n            removed = protocol\n        elseif protocol ~= \"\" then\n            table.insert(kept, protocol)\n        end\n    end\n    return table.concat(kept, \", \"), removed\nend\n\nfunction is_pomerium_cookie(cookie_name, name)\n    if name == cookie_name then\n        return true\n    end\n    -- large sessions are split into numbered chunks, e.g. _pomerium_1\n    return has_prefix(name, cookie_name .. \"_\") and name:sub(#cookie_name + 2):match(\"^%d+$\") ~= nil\nend\n\nfunction filter_set_cookies(values, cookie_name, limit)\n    local kept = {}\n    for _, value in ipairs(values) do\n        local name = value:match(\"^%s*([^=;%s]+)\")\n        if not is_pomerium_cookie(cookie_name, name) and (limit == nil or #kept < limit) then\n            table.insert(kept, value)\n        end\n    end\n    return kept\nend\n\n-- has_ambiguous_framing returns true if the request's framing headers could\n-- be interpreted differently by the upstream. Repeated headers are joined by\n-- commas. With the lenient protection, only conflicting content-length values\n-- are ambiguous.\nfunction has_ambiguous_framing(content_length, transfer_encoding, protection)\n    if content_length == nil then\n        return false\n    end\n    local values = {}\n    for value in (content_length .. \",\"):gmatch(\"([^,]*),\") do\n        table.insert(values, value:match(\"^%s*(.-)%s*$\"))\n    end\n    for i = 2, #values do\n        if values[i] ~= values[1] then\n            return true\n        end\n    end\n    if protection == \"lenient\" then\n        return false\n    end\n    return #values > 1 or transfer_encoding ~= nil\nend\n\nfunction envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local metadata = request_handle:metadata()\n\n    local framing_protection = metadata:get(\"check_request_framing\")\n    if framing_protection then\n        if has_ambiguous_framing(headers:get(\"content-length\"), headers:get(\"transfer-encoding\"), framing_protection) then\n            request_handle:respond({[\":status\"] = \"400\"}, \"Bad Request\")\n            return\n        end\n    end\n\n    -- the rbac filter records whether a PROXY protocol header was sent by a\n    -- trusted peer. If not, the client address envoy took from it is ignored.\n    local rbac_meta = request_handle:streamInfo():dynamicMetadata():get(\"envoy.filters.http.rbac\")\n    if rbac_meta ~= nil and rbac_meta[\"shadow_engine_result\"] == \"denied\" then\n        headers:remove(\"x-forwarded-for\")\n        headers:remove(\"x-envoy-external-address\")\n    end\n\n    -- the authorize service's timings are only meant for envoy, even if\n    -- server timing headers aren't enabled for the proxy\n    headers:remove(\"x-pomerium-server-timing\")\n\n    local remove_cookie_name = metadata:get(\"remove_pomerium_cookie\")\n    if remove_cookie_name then\n        local cookie = headers:get(\"cookie\")\n        if cookie ~= nil then\n            newcookie = remove_pomerium_cookie(remove_cookie_name, cookie)\n            headers:replace(\"cookie\", newcookie)\n        end\n    end\n\n    local remove_authorization = metadata:get(\"remove_pomerium_authorization\")\n    if remove_authorization then\n        local authorization = headers:get(\"authorization\")\n        local authorization_prefix = \"Pomerium \"\n        if has_prefix(authorization, authorization_prefix) then\n            headers:remove(\"authorization\")\n        end\n    end\n\n    local remove_query_param_name = metadata:get(\"remove_pomerium_query_param\")\n    if remove_query_param_name then\n        local path = headers:get(\":path\")\n        if path ~= nil then\n            headers:replace(\":path\", remove_query_param(path, remove_query_param_name))\n        end\n    end\n\n    local remove_protocol_prefix = metadata:get(\"remove_pomerium_websocket_protocol\")\n    if remove_protocol_prefix then\n        local protocols = headers:get(\"sec-websocket-protocol\")\n        if protocols ~= nil then\n            local kept, removed = remove_websocket_protocol(protocols, remove_protocol_prefix)\n            if kept == \"\" then\n                headers:remove(\"sec-websocket-protocol\")\n                -- the client only offered the token, so no protocol can be\n                -- selected upstream. Browsers fail the handshake unless one\n                -- of the offered protocols is selected, so echo it back.\n                if removed ~= nil then\n                    request_handle:streamInfo():dynamicMetadata():set(\"envoy.filters.http.lua\",\n                        \"pomerium_websocket_protocol\", removed)\n                end\n            elseif removed ~= nil then\n                headers:replace(\"sec-websocket-protocol\", kept)\n            end\n        end\n    end\nend\n\nfunction envoy_on_response(response_handle)\n    local metadata = response_handle:metadata()\n\n    local location_from = metadata:get(\"rewrite_response_location_from\")\n    local location_to = metadata:get(\"rewrite_response_location_to\")\n    if location_from and location_to then\n        local headers = response_handle:headers()\n        local location = headers:get(\"location\")\n        if has_prefix(location, location_from) then\n            local rest = location:sub(#location_from + 1)\n            -- only match whole host names and path segments\n            local next_char = rest:sub(1, 1)\n            if next_char == \"\" or next_char == \"/\" or next_char == \"?\" or next_char == \"#\" then\n                headers:replace(\"location\", location_to .. rest)\n            end\n        end\n    end\n\n    local dynamic_meta = response_handle:streamInfo():dynamicMetadata():get(\"envoy.filters.http.lua\")\n    if dynamic_meta ~= nil and dynamic_meta[\"pomerium_websocket_protocol\"] ~= nil then\n        local headers = response_handle:headers()\n        if headers:get(\"sec-websocket-protocol\") == nil then\n            headers:add(\"sec-websocket-protocol\", dynamic_meta[\"pomerium_websocket_protocol\"])\n        end\n    end\n\n    -- pomerium's own set-cookie is added by a filter which handles the\n    -- response after this one, so it's never dropped here\n    local set_cookie_filter = metadata:get(\"filter_upstream_set_cookie\")\n    if set_cookie_filter then\n        local headers = response_handle:headers()\n        local values = {}\n        for name, value in pairs(headers) do\n            if name == \"set-cookie\" then\n                table.insert(values, value)\n            end\n        end\n        local kept = filter_set_cookies(values, set_cookie_filter[\"cookie_name\"], set_cookie_filter[\"limit\"])\n        if #kept ~= #values then\n            headers:remove(\"set-cookie\")\n            for _, value in ipairs(kept) do\n                headers:add(\"set-cookie\", value)\n            end\n        end\n    end\n\n    local missing_headers = metadata:get(\"add_missing_response_headers\")\n    if missing_headers then\n        local headers = response_handle:headers()\n        for name, value in pairs(missing_headers) do\n            if headers:get(name) == nil then\n                headers:add(name, value)\n            end\n        end\n    end\nend\n"
+					}
+				},
+				{
+					"name": "envoy.filters.http.lua",
+					"typedConfig": {
+						"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+						"inlineCode": "-- seconds a client should wait before retrying a request that was shed\nlocal retry_after = \"1\"\n\nfunction envoy_on_response(response_handle)\n    local headers = response_handle:headers()\n    -- envoy marks the 503s of requests it dropped because the upstream's\n    -- circuit breaker was open as overloaded\n    if headers:get(\":status\") == \"503\" and\n        headers:get(\"x-envoy-overloaded\") ~= nil and\n        headers:get(\"retry-after\") == nil then\n        headers:add(\"retry-after\", retry_after)\n    end\nend\n"
 					}
 				},
 				{
@@ -415,3 +428,137 @@ func Test_buildRouteConfiguration(t *testing.T) {
 	assert.Equal(t, virtualHosts, routeConfig.GetVirtualHosts())
 	assert.False(t, routeConfig.GetValidateClusters().GetValue())
 }
+
+func Test_buildMainListenerProxyProtocol(t *testing.T) {
+	options := config.NewDefaultOptions()
+	options.InsecureServer = true
+
+	li := buildMainListener(options)
+	assert.Empty(t, li.ListenerFilters)
+	assert.Len(t, li.FilterChains[0].Filters, 1)
+
+	options.UseProxyProtocol = true
+	options.ProxyProtocolTrustedCIDRs = []string{"10.0.0.0/8", "192.168.1.1/32"}
+	li = buildMainListener(options)
+	testutil.AssertProtoJSONEqual(t, `[{
+		"name": "envoy.filters.listener.proxy_protocol",
+		"typedConfig": {
+			"@type": "type.googleapis.com/envoy.extensions.filters.listener.proxy_protocol.v3.ProxyProtocol"
+		}
+	}]`, li.ListenerFilters)
+	// untrusted peers aren't refused, the rbac filter only records whether
+	// they're trusted
+	assert.Len(t, li.FilterChains[0].Filters, 1)
+
+	var hcm envoy_http_connection_manager.HttpConnectionManager
+	require.NoError(t, ptypes.UnmarshalAny(li.FilterChains[0].Filters[0].GetTypedConfig(), &hcm))
+	testutil.AssertProtoJSONEqual(t, `{
+		"name": "envoy.filters.http.rbac",
+		"typedConfig": {
+			"@type": "type.googleapis.com/envoy.extensions.filters.http.rbac.v3.RBAC",
+			"shadowRules": {
+				"policies": {
+					"trusted-proxies": {
+						"permissions": [{ "any": true }],
+						"principals": [
+							{ "directRemoteIp": { "addressPrefix": "10.0.0.0", "prefixLen": 8 } },
+							{ "directRemoteIp": { "addressPrefix": "192.168.1.1", "prefixLen": 32 } }
+						]
+					}
+				}
+			}
+		}
+	}`, hcm.GetHttpFilters()[0])
+
+	var extAuthz envoy_extensions_filters_http_ext_authz_v3.ExtAuthz
+	require.NoError(t, ptypes.UnmarshalAny(hcm.GetHttpFilters()[1].GetTypedConfig(), &extAuthz))
+	assert.Equal(t, []string{"envoy.filters.http.rbac"}, extAuthz.GetMetadataContextNamespaces())
+}
+
+func Test_buildMainHTTPConnectionManagerFilterServerTiming(t *testing.T) {
+	getLuaScripts := func(options *config.Options) []string {
+		filter := buildMainHTTPConnectionManagerFilter(options, []string{"example.com"}, "*")
+		var hcm envoy_http_connection_manager.HttpConnectionManager
+		if !assert.NoError(t, ptypes.UnmarshalAny(filter.GetTypedConfig(), &hcm)) {
+			return nil
+		}
+		var scripts []string
+		for _, f := range hcm.GetHttpFilters() {
+			if f.GetName() != "envoy.filters.http.lua" {
+				continue
+			}
+			var lua envoy_extensions_filters_http_lua_v3.Lua
+			if assert.NoError(t, ptypes.UnmarshalAny(f.GetTypedConfig(), &lua)) {
+				scripts = append(scripts, lua.GetInlineCode())
+			}
+		}
+		return scripts
+	}
+
+	options := config.NewDefaultOptions()
+	assert.NotContains(t, getLuaScripts(options), luascripts.ServerTiming)
+
+	options.ServerTimingHeaders = true
+	assert.Equal(t, []string{
+		luascripts.RemoveServerTiming,
+		luascripts.ExtAuthzSetCookie,
+		luascripts.ServerTiming,
+		luascripts.CleanUpstream,
+		luascripts.RetryAfter,
+	}, getLuaScripts(options))
+}
+
+func Test_buildMainHTTPConnectionManagerFilterSNIHostConsistency(t *testing.T) {
+	// getRouteStatuses returns the direct response status of each of the
+	// policy routes for host, when requested over a connection for sni.
+	getRouteStatuses := func(options *config.Options, sni, host string) []uint32 {
+		filter := buildMainHTTPConnectionManagerFilter(options, []string{"a.example.com", "b.example.com"}, sni)
+		var hcm envoy_http_connection_manager.HttpConnectionManager
+		if !assert.NoError(t, ptypes.UnmarshalAny(filter.GetTypedConfig(), &hcm)) {
+			return nil
+		}
+		var statuses []uint32
+		for _, vh := range hcm.GetRouteConfig().GetVirtualHosts() {
+			if vh.GetName() != host {
+				continue
+			}
+			for _, route := range vh.GetRoutes() {
+				if strings.HasPrefix(route.GetName(), "policy-") {
+					statuses = append(statuses, route.GetDirectResponse().GetStatus())
+				}
+			}
+		}
+		return statuses
+	}
+
+	options := config.NewDefaultOptions()
+	options.Services = "proxy"
+	options.Policies = []config.Policy{
+		{From: "https://a.example.com", To: "https://to.example.com", AllowedUsers: []string{"user@example.com"}},
+		{From: "https://b.example.com", To: "https://to.example.com", AllowedUsers: []string{"user@example.com"}},
+		{From: "https://b.example.com", To: "https://to.example.com", Prefix: "/public/", AllowPublicUnauthenticatedAccess: true},
+	}
+	for i := range options.Policies {
+		assert.NoError(t, options.Policies[i].Validate())
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		assert.Equal(t, []uint32{0, 0}, getRouteStatuses(options, "a.example.com", "b.example.com"))
+	})
+	t.Run("strict", func(t *testing.T) {
+		options.SNIHostConsistency = config.SNIHostConsistencyStrict
+		assert.Equal(t, []uint32{0}, getRouteStatuses(options, "a.example.com", "a.example.com"),
+			"matching sni and host should be routed")
+		assert.Equal(t, []uint32{421, 0}, getRouteStatuses(options, "a.example.com", "b.example.com"),
+			"protected routes with a mismatched sni should be rejected, while public routes are routed")
+		assert.Equal(t, []uint32{421, 0}, getRouteStatuses(options, "*", "b.example.com"),
+			"protected routes on connections without a matching sni should be rejected")
+	})
+	t.Run("strict insecure server", func(t *testing.T) {
+		options.SNIHostConsistency = config.SNIHostConsistencyStrict
+		options.InsecureServer = true
+		defer func() { options.InsecureServer = false }()
+		assert.Equal(t, []uint32{0, 0}, getRouteStatuses(options, "*", "b.example.com"),
+			"connections without tls have no sni to check")
+	})
+}
@@ -13,8 +13,11 @@ import (
 //go:generate go fmt ./luascripts/statik.go
 
 var luascripts struct {
-	ExtAuthzSetCookie string
-	CleanUpstream     string
+	ExtAuthzSetCookie  string
+	CleanUpstream      string
+	ServerTiming       string
+	RetryAfter         string
+	RemoveServerTiming string
 }
 
 func init() {
@@ -26,6 +29,9 @@ func init() {
 	fileToField := map[string]*string{
 		"/clean-upstream.lua":       &luascripts.CleanUpstream,
 		"/ext-authz-set-cookie.lua": &luascripts.ExtAuthzSetCookie,
+		"/server-timing.lua":        &luascripts.ServerTiming,
+		"/retry-after.lua":          &luascripts.RetryAfter,
+		"/remove-server-timing.lua": &luascripts.RemoveServerTiming,
 	}
 
 	err = fs.Walk(hfs, "/", func(p string, fi os.FileInfo, err error) error {
@@ -0,0 +1,317 @@
+package controlplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaEnvoyMock implements the parts of envoy's lua filter api used by the
+// scripts. Header maps keep their entries in order and, like envoy's, can be
+// iterated with pairs.
+const luaEnvoyMock = `
+local raw_pairs = pairs
+function pairs(t)
+    local mt = getmetatable(t)
+    if mt ~= nil and mt.__pairs ~= nil then
+        return mt.__pairs(t)
+    end
+    return raw_pairs(t)
+end
+
+local Headers = {}
+Headers.__index = Headers
+Headers.__pairs = function(self)
+    local i = 0
+    return function()
+        i = i + 1
+        local entry = self.entries[i]
+        if entry ~= nil then
+            return entry[1], entry[2]
+        end
+    end
+end
+
+function new_headers(entries)
+    local headers = setmetatable({entries = {}}, Headers)
+    for _, entry in ipairs(entries or {}) do
+        headers:add(entry[1], entry[2])
+    end
+    return headers
+end
+
+function Headers:get(name)
+    for _, entry in ipairs(self.entries) do
+        if entry[1] == name then
+            return entry[2]
+        end
+    end
+    return nil
+end
+
+function Headers:values(name)
+    local values = {}
+    for _, entry in ipairs(self.entries) do
+        if entry[1] == name then
+            table.insert(values, entry[2])
+        end
+    end
+    return values
+end
+
+function Headers:add(name, value)
+    table.insert(self.entries, {name, value})
+end
+
+function Headers:remove(name)
+    local kept = {}
+    for _, entry in ipairs(self.entries) do
+        if entry[1] ~= name then
+            table.insert(kept, entry)
+        end
+    end
+    self.entries = kept
+end
+
+function Headers:replace(name, value)
+    for _, entry in ipairs(self.entries) do
+        if entry[1] == name then
+            entry[2] = value
+            return
+        end
+    end
+    self:add(name, value)
+end
+
+local Metadata = {}
+Metadata.__index = Metadata
+
+function Metadata:get(key)
+    return self.values[key]
+end
+
+function Metadata:set(filter, key, value)
+    self.values[filter] = self.values[filter] or {}
+    self.values[filter][key] = value
+end
+
+local Handle = {}
+Handle.__index = Handle
+
+function new_handle(headers, metadata)
+    return setmetatable({
+        _headers = headers,
+        _metadata = setmetatable({values = metadata or {}}, Metadata),
+        _dynamic_metadata = setmetatable({values = {}}, Metadata),
+    }, Handle)
+end
+
+function Handle:headers()
+    return self._headers
+end
+
+function Handle:metadata()
+    return self._metadata
+end
+
+function Handle:streamInfo()
+    local handle = self
+    return {
+        dynamicMetadata = function()
+            return handle._dynamic_metadata
+        end,
+    }
+end
+
+function Handle:respond(headers, body)
+    self.response = {headers = headers, body = body}
+end
+`
+
+func newLuaState(t *testing.T, script string) *lua.LState {
+	L := lua.NewState()
+	t.Cleanup(L.Close)
+	require.NoError(t, L.DoString(luaEnvoyMock))
+	require.NoError(t, L.DoString(script))
+	return L
+}
+
+func TestLua_hasAmbiguousFraming(t *testing.T) {
+	L := newLuaState(t, luascripts.CleanUpstream)
+
+	tests := []struct {
+		name             string
+		contentLength    lua.LValue
+		transferEncoding lua.LValue
+		protection       string
+		want             bool
+	}{
+		{"no framing headers", lua.LNil, lua.LNil, "strict", false},
+		{"content-length", lua.LString("5"), lua.LNil, "strict", false},
+		{"transfer-encoding", lua.LNil, lua.LString("chunked"), "strict", false},
+		{"both", lua.LString("5"), lua.LString("chunked"), "strict", true},
+		{"both lenient", lua.LString("5"), lua.LString("chunked"), "lenient", false},
+		{"duplicate content-length", lua.LString("5, 5"), lua.LNil, "strict", true},
+		{"duplicate content-length lenient", lua.LString("5, 5"), lua.LNil, "lenient", false},
+		{"conflicting content-length", lua.LString("5,6"), lua.LNil, "lenient", true},
+		{"empty content-length value", lua.LString("5,"), lua.LNil, "lenient", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, L.CallByParam(lua.P{
+				Fn:      L.GetGlobal("has_ambiguous_framing"),
+				NRet:    1,
+				Protect: true,
+			}, tt.contentLength, tt.transferEncoding, lua.LString(tt.protection)))
+			got := L.Get(-1)
+			L.Pop(1)
+			assert.Equal(t, lua.LBool(tt.want), got)
+		})
+	}
+}
+
+func TestLua_checkRequestFraming(t *testing.T) {
+	L := newLuaState(t, luascripts.CleanUpstream)
+
+	require.NoError(t, L.DoString(`
+		rejected = new_handle(new_headers({
+			{"content-length", "5"},
+			{"transfer-encoding", "chunked"},
+		}), {check_request_framing = "strict"})
+		envoy_on_request(rejected)
+
+		unchecked = new_handle(new_headers({
+			{"content-length", "5"},
+			{"transfer-encoding", "chunked"},
+		}), {})
+		envoy_on_request(unchecked)
+
+		rejected_status = rejected.response and rejected.response.headers[":status"]
+		unchecked_response = unchecked.response
+	`))
+	assert.Equal(t, lua.LString("400"), L.GetGlobal("rejected_status"))
+	assert.Equal(t, lua.LNil, L.GetGlobal("unchecked_response"))
+}
+
+func TestLua_untrustedProxyProtocolPeer(t *testing.T) {
+	L := newLuaState(t, luascripts.CleanUpstream)
+
+	require.NoError(t, L.DoString(`
+		function forwarded_for(result)
+			local handle = new_handle(new_headers({
+				{"x-forwarded-for", "198.51.100.1"},
+				{"x-envoy-external-address", "198.51.100.1"},
+			}), {})
+			if result ~= nil then
+				handle:streamInfo():dynamicMetadata():set("envoy.filters.http.rbac", "shadow_engine_result", result)
+			end
+			envoy_on_request(handle)
+			return table.concat(handle:headers():values("x-forwarded-for"), "|") ..
+				";" .. table.concat(handle:headers():values("x-envoy-external-address"), "|")
+		end
+
+		trusted = forwarded_for("allowed")
+		untrusted = forwarded_for("denied")
+		disabled = forwarded_for(nil)
+	`))
+	assert.Equal(t, lua.LString("198.51.100.1;198.51.100.1"), L.GetGlobal("trusted"))
+	assert.Equal(t, lua.LString(";"), L.GetGlobal("untrusted"))
+	assert.Equal(t, lua.LString("198.51.100.1;198.51.100.1"), L.GetGlobal("disabled"))
+}
+
+func TestLua_filterUpstreamSetCookie(t *testing.T) {
+	// response filters run in the reverse order, so the upstream's cookies
+	// are filtered before pomerium's own set-cookie is added
+	L := newLuaState(t, luascripts.CleanUpstream)
+	require.NoError(t, L.DoString(`clean_upstream_on_response = envoy_on_response`))
+	require.NoError(t, L.DoString(luascripts.ExtAuthzSetCookie))
+
+	require.NoError(t, L.DoString(`
+		handle = new_handle(new_headers({
+			{"set-cookie", "a=1; Path=/"},
+			{"set-cookie", "_pomerium=spoofed"},
+			{"set-cookie", "_pomerium_0=spoofed"},
+			{"set-cookie", "b=2"},
+			{"set-cookie", "c=3"},
+			{"set-cookie", "d=4"},
+		}), {filter_upstream_set_cookie = {cookie_name = "_pomerium", limit = 2}})
+		handle:streamInfo():dynamicMetadata():set("envoy.filters.http.lua", "pomerium_set_cookie", "_pomerium=session")
+
+		clean_upstream_on_response(handle)
+		envoy_on_response(handle)
+
+		set_cookies = table.concat(handle:headers():values("set-cookie"), "|")
+	`))
+	assert.Equal(t, lua.LString("a=1; Path=/|b=2|_pomerium=session"), L.GetGlobal("set_cookies"),
+		"cookies beyond the limit and spoofed session cookies should be dropped, while the session cookie is kept")
+}
+
+func TestLua_retryAfter(t *testing.T) {
+	L := newLuaState(t, luascripts.RetryAfter)
+
+	require.NoError(t, L.DoString(`
+		function retry_after(entries)
+			local handle = new_handle(new_headers(entries), {})
+			envoy_on_response(handle)
+			return handle:headers():get("retry-after")
+		end
+
+		shed = retry_after({{":status", "503"}, {"x-envoy-overloaded", "true"}})
+		unavailable = retry_after({{":status", "503"}})
+		upstream = retry_after({{":status", "503"}, {"x-envoy-overloaded", "true"}, {"retry-after", "30"}})
+		ok = retry_after({{":status", "200"}})
+	`))
+	assert.Equal(t, lua.LString("1"), L.GetGlobal("shed"))
+	assert.Equal(t, lua.LNil, L.GetGlobal("unavailable"))
+	assert.Equal(t, lua.LString("30"), L.GetGlobal("upstream"))
+	assert.Equal(t, lua.LNil, L.GetGlobal("ok"))
+}
+
+func TestLua_serverTimingSpoofing(t *testing.T) {
+	L := newLuaState(t, luascripts.RemoveServerTiming)
+	require.NoError(t, L.DoString(`remove_server_timing_on_request = envoy_on_request`))
+	require.NoError(t, L.DoString(luascripts.ServerTiming))
+
+	require.NoError(t, L.DoString(`
+		-- server_timing runs the filters for a request whose client sent its
+		-- own timings. If the route is authorized, ext_authz adds the
+		-- authorize service's timings in between.
+		function server_timing(authorized)
+			local handle = new_handle(new_headers({
+				{"x-pomerium-server-timing", "authorize;dur=0"},
+			}), {})
+			remove_server_timing_on_request(handle)
+			if authorized then
+				handle:headers():add("x-pomerium-server-timing", "authorize;dur=5")
+			end
+			envoy_on_request(handle)
+			local upstream = handle:headers():get("x-pomerium-server-timing")
+
+			handle._headers = new_headers({})
+			envoy_on_response(handle)
+			return tostring(handle:headers():get("server-timing")) .. ";" .. tostring(upstream)
+		end
+
+		authorized = server_timing(true)
+		public = server_timing(false)
+	`))
+	assert.Equal(t, lua.LString("authorize;dur=5;nil"), L.GetGlobal("authorized"))
+	assert.Equal(t, lua.LString("nil;nil"), L.GetGlobal("public"),
+		"timings sent by the client should never be trusted")
+}
+
+func TestLua_cleanUpstreamServerTiming(t *testing.T) {
+	L := newLuaState(t, luascripts.CleanUpstream)
+
+	require.NoError(t, L.DoString(`
+		handle = new_handle(new_headers({
+			{"x-pomerium-server-timing", "authorize;dur=5"},
+		}), {})
+		envoy_on_request(handle)
+		server_timing = handle:headers():get("x-pomerium-server-timing")
+	`))
+	assert.Equal(t, lua.LNil, L.GetGlobal("server_timing"),
+		"the authorize service's timings shouldn't be sent upstream")
+}
@@ -2,7 +2,11 @@ package controlplane
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
@@ -10,11 +14,13 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/urlutil"
 )
 
 func buildGRPCRoutes() []*envoy_config_route_v3.Route {
@@ -108,7 +114,6 @@ var getPolicyName = func(policy *config.Policy) string {
 
 func buildPolicyRoutes(options *config.Options, domain string) []*envoy_config_route_v3.Route {
 	var routes []*envoy_config_route_v3.Route
-	responseHeadersToAdd := toEnvoyHeaders(options.Headers)
 
 	for i, policy := range options.Policies {
 		if !hostMatchesDomain(policy.Source.URL, domain) {
@@ -119,27 +124,70 @@ func buildPolicyRoutes(options *config.Options, domain string) []*envoy_config_r
 		clusterName := getPolicyName(&policy)
 		requestHeadersToAdd := toEnvoyHeaders(policy.SetRequestHeaders)
 		requestHeadersToRemove := getRequestHeadersToRemove(options, &policy)
+		responseHeadersToAdd := getResponseHeadersToAdd(options, &policy)
 		routeTimeout := getRouteTimeout(options, &policy)
 		prefixRewrite, regexRewrite := getRewriteOptions(&policy)
 
-		routes = append(routes, &envoy_config_route_v3.Route{
+		luaMetadata := map[string]*structpb.Value{
+			"remove_pomerium_cookie": {
+				Kind: &structpb.Value_StringValue{
+					StringValue: options.CookieName,
+				},
+			},
+			"remove_pomerium_authorization": {
+				Kind: &structpb.Value_BoolValue{
+					BoolValue: true,
+				},
+			},
+			"remove_pomerium_websocket_protocol": {
+				Kind: &structpb.Value_StringValue{
+					StringValue: httputil.WebSocketProtocolPomerium,
+				},
+			},
+		}
+		if !policy.PreserveSessionQueryParam {
+			luaMetadata["remove_pomerium_query_param"] = &structpb.Value{
+				Kind: &structpb.Value_StringValue{StringValue: urlutil.QuerySession},
+			}
+		}
+		if policy.RewriteResponseLocation {
+			from, to := getRewriteResponseLocation(&policy)
+			luaMetadata["rewrite_response_location_from"] = &structpb.Value{
+				Kind: &structpb.Value_StringValue{StringValue: from},
+			}
+			luaMetadata["rewrite_response_location_to"] = &structpb.Value{
+				Kind: &structpb.Value_StringValue{StringValue: to},
+			}
+		}
+		if filter := getUpstreamSetCookieFilter(options, &policy); filter != nil {
+			luaMetadata["filter_upstream_set_cookie"] = &structpb.Value{
+				Kind: &structpb.Value_StructValue{StructValue: filter},
+			}
+		}
+		// authorize checks the request framing, but isn't called for routes
+		// which skip authorization
+		if skipsAuthorization(options, &policy) && options.RequestSmugglingProtection != config.RequestSmugglingProtectionOff {
+			protection := options.RequestSmugglingProtection
+			if protection == "" {
+				protection = config.RequestSmugglingProtectionStrict
+			}
+			luaMetadata["check_request_framing"] = &structpb.Value{
+				Kind: &structpb.Value_StringValue{StringValue: string(protection)},
+			}
+		}
+		if defaults := getDefaultResponseHeaders(&policy); defaults != nil {
+			luaMetadata["add_missing_response_headers"] = &structpb.Value{
+				Kind: &structpb.Value_StructValue{StructValue: defaults},
+			}
+		}
+
+		route := &envoy_config_route_v3.Route{
 			Name:  fmt.Sprintf("policy-%d", i),
 			Match: match,
 			Metadata: &envoy_config_core_v3.Metadata{
 				FilterMetadata: map[string]*structpb.Struct{
 					"envoy.filters.http.lua": {
-						Fields: map[string]*structpb.Value{
-							"remove_pomerium_cookie": {
-								Kind: &structpb.Value_StringValue{
-									StringValue: options.CookieName,
-								},
-							},
-							"remove_pomerium_authorization": {
-								Kind: &structpb.Value_BoolValue{
-									BoolValue: true,
-								},
-							},
-						},
+						Fields: luaMetadata,
 					},
 				},
 			},
@@ -169,11 +217,88 @@ func buildPolicyRoutes(options *config.Options, domain string) []*envoy_config_r
 			RequestHeadersToAdd:    requestHeadersToAdd,
 			RequestHeadersToRemove: requestHeadersToRemove,
 			ResponseHeadersToAdd:   responseHeadersToAdd,
-		})
+		}
+		if skipsAuthorization(options, &policy) {
+			route.TypedPerFilterConfig = map[string]*any.Any{
+				"envoy.filters.http.ext_authz": disableExtAuthz,
+			}
+		}
+		if websocketRoute := buildPolicyWebsocketRoute(route, &policy); websocketRoute != nil {
+			routes = append(routes, websocketRoute)
+		}
+		routes = append(routes, route)
 	}
 	return routes
 }
 
+// buildPolicyWebsocketRoute returns a copy of the policy's route which only
+// matches websocket upgrades, if they need to be handled differently from the
+// route's other requests. Envoy has no way to re-authorize an upgraded
+// connection, so the route's timeout closes it after the reauthorize
+// interval, and the client's reconnect is checked by the authorize service
+// again. If the policy limits its websocket connections, upgrades are sent
+// to a separate cluster which enforces the limit.
+func buildPolicyWebsocketRoute(route *envoy_config_route_v3.Route, policy *config.Policy) *envoy_config_route_v3.Route {
+	if !policy.AllowWebsockets || (policy.WebsocketReauthorizeInterval <= 0 && !hasPolicyWebsocketCluster(policy)) {
+		return nil
+	}
+
+	websocketRoute := proto.Clone(route).(*envoy_config_route_v3.Route)
+	websocketRoute.Name += "-websocket"
+	websocketRoute.Match.Headers = append(websocketRoute.Match.Headers, &envoy_config_route_v3.HeaderMatcher{
+		Name: "upgrade",
+		HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: &envoy_type_matcher_v3.RegexMatcher{
+				EngineType: &envoy_type_matcher_v3.RegexMatcher_GoogleRe2{
+					GoogleRe2: &envoy_type_matcher_v3.RegexMatcher_GoogleRE2{},
+				},
+				Regex: "(?i)websocket",
+			},
+		},
+	})
+	if policy.WebsocketReauthorizeInterval > 0 &&
+		(policy.UpstreamTimeout == 0 || policy.WebsocketReauthorizeInterval < policy.UpstreamTimeout) {
+		websocketRoute.GetRoute().Timeout = ptypes.DurationProto(policy.WebsocketReauthorizeInterval)
+	}
+	if hasPolicyWebsocketCluster(policy) {
+		websocketRoute.GetRoute().ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{
+			Cluster: getPolicyWebsocketClusterName(policy),
+		}
+	}
+	return websocketRoute
+}
+
+// misdirectProtectedRoutes replaces the routes which require authorization
+// with ones that reply 421 Misdirected Request, so that clients retry over a
+// connection for the right server name. Public routes are kept as is.
+func misdirectProtectedRoutes(routes []*envoy_config_route_v3.Route) []*envoy_config_route_v3.Route {
+	misdirected := make([]*envoy_config_route_v3.Route, 0, len(routes))
+	for _, route := range routes {
+		if _, ok := route.GetTypedPerFilterConfig()["envoy.filters.http.ext_authz"]; ok {
+			misdirected = append(misdirected, route)
+			continue
+		}
+		misdirected = append(misdirected, &envoy_config_route_v3.Route{
+			Name:  route.GetName(),
+			Match: route.GetMatch(),
+			Action: &envoy_config_route_v3.Route_DirectResponse{
+				DirectResponse: &envoy_config_route_v3.DirectResponseAction{
+					Status: http.StatusMisdirectedRequest,
+					Body: &envoy_config_core_v3.DataSource{
+						Specifier: &envoy_config_core_v3.DataSource_InlineString{
+							InlineString: http.StatusText(http.StatusMisdirectedRequest),
+						},
+					},
+				},
+			},
+			TypedPerFilterConfig: map[string]*any.Any{
+				"envoy.filters.http.ext_authz": disableExtAuthz,
+			},
+		})
+	}
+	return misdirected
+}
+
 func mkEnvoyHeader(k, v string) *envoy_config_core_v3.HeaderValueOption {
 	return &envoy_config_core_v3.HeaderValueOption{
 		Header: &envoy_config_core_v3.HeaderValue{
@@ -214,17 +339,129 @@ func mkRouteMatch(policy *config.Policy) *envoy_config_route_v3.RouteMatch {
 	return match
 }
 
+// skipsAuthorization returns true if requests to the route are proxied
+// without calling the authorize service. Public routes don't require a
+// session, so skip authorization entirely, unless every route is restricted
+// to administrators.
+func skipsAuthorization(options *config.Options, policy *config.Policy) bool {
+	return policy.AllowPublicUnauthenticatedAccess && !options.AdminsOnly
+}
+
 func getRequestHeadersToRemove(options *config.Options, policy *config.Policy) []string {
 	requestHeadersToRemove := policy.RemoveRequestHeaders
-	if !policy.PassIdentityHeaders {
+	if policy.AuthorizationHeader == config.AuthorizationHeaderStrip ||
+		(policy.AuthorizationHeader == config.AuthorizationHeaderReplace && skipsAuthorization(options, policy)) {
+		requestHeadersToRemove = append(requestHeadersToRemove, "Authorization")
+	}
+	// authorize overwrites the identity headers sent by clients, but when
+	// it's skipped they must be removed so they can't be spoofed
+	if !policy.PassIdentityHeaders || skipsAuthorization(options, policy) {
 		requestHeadersToRemove = append(requestHeadersToRemove, httputil.HeaderPomeriumJWTAssertion)
 		for _, claim := range options.JWTClaimsHeaders {
 			requestHeadersToRemove = append(requestHeadersToRemove, httputil.PomeriumJWTHeaderName(claim))
 		}
 	}
+	if options.UserIDHeader != "" && skipsAuthorization(options, policy) {
+		requestHeadersToRemove = append(requestHeadersToRemove, options.UserIDHeader)
+	}
 	return requestHeadersToRemove
 }
 
+func getResponseHeadersToAdd(options *config.Options, policy *config.Policy) []*envoy_config_core_v3.HeaderValueOption {
+	// the route's security headers take precedence over the global headers
+	globalHeaders := make(map[string]string, len(options.Headers))
+	for k, v := range options.Headers {
+		if !hasSecurityHeader(policy, k) {
+			globalHeaders[k] = v
+		}
+	}
+	responseHeadersToAdd := toEnvoyHeaders(globalHeaders)
+	// responses to authenticated requests must never be stored by shared caches
+	if !policy.AllowPublicUnauthenticatedAccess && !policy.AllowResponseCaching {
+		responseHeadersToAdd = append(responseHeadersToAdd, mkEnvoyHeader("Cache-Control", "private, no-store"))
+	}
+	// announce deprecated routes, dates were checked when validating the policy
+	if deprecation, _ := policy.GetDeprecation(); !deprecation.IsZero() {
+		responseHeadersToAdd = append(responseHeadersToAdd, mkEnvoyHeader("Deprecation", "@"+strconv.FormatInt(deprecation.Unix(), 10)))
+	}
+	if sunset, _ := policy.GetSunset(); !sunset.IsZero() {
+		responseHeadersToAdd = append(responseHeadersToAdd, mkEnvoyHeader("Sunset", sunset.UTC().Format(http.TimeFormat)))
+	}
+	for _, k := range sortedKeys(policy.SecurityHeaders) {
+		v := policy.SecurityHeaders[k]
+		switch {
+		case policy.SecurityHeadersMode != config.SecurityHeadersMerge:
+			responseHeadersToAdd = append(responseHeadersToAdd, mkEnvoyHeader(k, v))
+		case strings.EqualFold(k, "Content-Security-Policy"):
+			// browsers enforce every policy they receive, so sending ours
+			// alongside the upstream's can only tighten it
+			header := mkEnvoyHeader(k, v)
+			header.Append = &wrappers.BoolValue{Value: true}
+			responseHeadersToAdd = append(responseHeadersToAdd, header)
+		}
+	}
+	return responseHeadersToAdd
+}
+
+// getDefaultResponseHeaders returns the security headers which the lua
+// filter adds to responses only if the upstream didn't set them.
+func getDefaultResponseHeaders(policy *config.Policy) *structpb.Struct {
+	if policy.SecurityHeadersMode != config.SecurityHeadersMerge {
+		return nil
+	}
+	defaults := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	for k, v := range policy.SecurityHeaders {
+		if strings.EqualFold(k, "Content-Security-Policy") {
+			continue
+		}
+		defaults.Fields[strings.ToLower(k)] = &structpb.Value{
+			Kind: &structpb.Value_StringValue{StringValue: v},
+		}
+	}
+	if len(defaults.Fields) == 0 {
+		return nil
+	}
+	return defaults
+}
+
+// getUpstreamSetCookieFilter returns the settings which the lua filter uses
+// to filter the Set-Cookie headers sent by the upstream, or nil if they are
+// passed through.
+func getUpstreamSetCookieFilter(options *config.Options, policy *config.Policy) *structpb.Struct {
+	if policy.UpstreamSetCookieMode != config.UpstreamSetCookieFilter && policy.UpstreamSetCookieMode != config.UpstreamSetCookieLimit {
+		return nil
+	}
+	filter := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"cookie_name": {
+			Kind: &structpb.Value_StringValue{StringValue: options.CookieName},
+		},
+	}}
+	if policy.UpstreamSetCookieMode == config.UpstreamSetCookieLimit {
+		filter.Fields["limit"] = &structpb.Value{
+			Kind: &structpb.Value_NumberValue{NumberValue: float64(policy.UpstreamSetCookieLimit)},
+		}
+	}
+	return filter
+}
+
+func hasSecurityHeader(policy *config.Policy, name string) bool {
+	for k := range policy.SecurityHeaders {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func getRouteTimeout(options *config.Options, policy *config.Policy) *durationpb.Duration {
 	var routeTimeout *durationpb.Duration
 	if policy.AllowWebsockets {
@@ -264,6 +501,19 @@ func getRewriteOptions(policy *config.Policy) (prefixRewrite string, regexRewrit
 	return prefixRewrite, regexRewrite
 }
 
+// getRewriteResponseLocation returns the upstream URL prefix that response
+// Location headers are rewritten from, and the external URL prefix they are
+// rewritten to. Paths are only mapped back when the route rewrites a prefix.
+func getRewriteResponseLocation(policy *config.Policy) (from, to string) {
+	internal := url.URL{Scheme: policy.Destination.Scheme, Host: policy.Destination.Host}
+	external := url.URL{Scheme: policy.Source.Scheme, Host: policy.Source.Host}
+	if prefixRewrite, _ := getRewriteOptions(policy); prefixRewrite != "" {
+		internal.Path = prefixRewrite
+		external.Path = policy.Prefix
+	}
+	return strings.TrimSuffix(internal.String(), "/"), strings.TrimSuffix(external.String(), "/")
+}
+
 func hasPublicPolicyMatchingURL(options *config.Options, requestURL *url.URL) bool {
 	for _, policy := range options.Policies {
 		if policy.AllowPublicUnauthenticatedAccess && policy.Matches(requestURL) {
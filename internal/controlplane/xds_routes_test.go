@@ -2,9 +2,16 @@ package controlplane
 
 import (
 	"fmt"
+	"regexp"
 	"testing"
 	"time"
 
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/testutil"
 )
@@ -254,7 +261,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -266,7 +275,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": false, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-2",
@@ -277,7 +293,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -289,7 +307,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": true, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-3",
@@ -300,7 +325,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -319,6 +346,13 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						"key": "HEADER-KEY",
 						"value": "HEADER-VALUE"
 					}
+				}],
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
 				}]
 			},
 			{
@@ -333,7 +367,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -345,7 +381,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": false, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-5",
@@ -356,7 +399,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -369,7 +414,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
 				},
-				"requestHeadersToRemove": ["HEADER-KEY"]
+				"requestHeadersToRemove": ["HEADER-KEY"],
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-6",
@@ -380,7 +432,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -392,7 +446,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": false, "upgradeType": "websocket"},
 						{ "enabled": true, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-7",
@@ -403,7 +464,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -415,7 +478,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": true, "upgradeType": "websocket"},
 						{ "enabled": true, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-8",
@@ -426,7 +496,9 @@ func Test_buildPolicyRoutes(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -438,7 +510,14 @@ func Test_buildPolicyRoutes(t *testing.T) {
 						{ "enabled": true, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			}
 		]
 	`, routes)
@@ -474,7 +553,9 @@ func TestAddOptionsHeadersToResponse(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -493,6 +574,12 @@ func TestAddOptionsHeadersToResponse(t *testing.T) {
 						"key": "Strict-Transport-Security",
 						"value": "max-age=31536000; includeSubDomains; preload"
 					}
+				}, {
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
 				}]
 			}
 		]
@@ -540,7 +627,9 @@ func Test_buildPolicyRoutesRewrite(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -553,7 +642,14 @@ func Test_buildPolicyRoutesRewrite(t *testing.T) {
 						{ "enabled": false, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-1",
@@ -564,7 +660,9 @@ func Test_buildPolicyRoutesRewrite(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -577,7 +675,14 @@ func Test_buildPolicyRoutesRewrite(t *testing.T) {
 						{ "enabled": false, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			},
 			{
 				"name": "policy-2",
@@ -588,7 +693,9 @@ func Test_buildPolicyRoutesRewrite(t *testing.T) {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
 							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_cookie": "pomerium",
+							"remove_pomerium_query_param": "pomerium_session",
+							"remove_pomerium_websocket_protocol": "pomerium.session."
 						}
 					}
 				},
@@ -607,8 +714,423 @@ func Test_buildPolicyRoutesRewrite(t *testing.T) {
 						{ "enabled": false, "upgradeType": "websocket"},
 						{ "enabled": false, "upgradeType": "spdy/3.1"}
 					]
-				}
+				},
+				"responseHeadersToAdd": [{
+					"append": false,
+					"header": {
+						"key": "Cache-Control",
+						"value": "private, no-store"
+					}
+				}]
 			}
 		]
 	`, routes)
 }
+
+func Test_getRequestHeadersToRemove(t *testing.T) {
+	options := &config.Options{}
+	for _, tt := range []struct {
+		mode config.AuthorizationHeaderMode
+		want []string
+	}{
+		{"", []string{"x-pomerium-jwt-assertion"}},
+		{config.AuthorizationHeaderForward, []string{"x-pomerium-jwt-assertion"}},
+		{config.AuthorizationHeaderStrip, []string{"Authorization", "x-pomerium-jwt-assertion"}},
+		{config.AuthorizationHeaderReplace, []string{"x-pomerium-jwt-assertion"}},
+	} {
+		headers := getRequestHeadersToRemove(options, &config.Policy{AuthorizationHeader: tt.mode})
+		assert.Equal(t, tt.want, headers, "authorization header mode %q", tt.mode)
+	}
+
+	t.Run("user id header", func(t *testing.T) {
+		options := &config.Options{UserIDHeader: "X-User-Id"}
+		headers := getRequestHeadersToRemove(options, &config.Policy{PassIdentityHeaders: true})
+		assert.NotContains(t, headers, "X-User-Id", "authorize overwrites the header on protected routes")
+		headers = getRequestHeadersToRemove(options, &config.Policy{PassIdentityHeaders: true, AllowPublicUnauthenticatedAccess: true})
+		assert.Contains(t, headers, "X-User-Id", "public routes must strip the header")
+	})
+}
+
+func Test_getResponseHeadersToAdd(t *testing.T) {
+	options := &config.Options{}
+	noStore := `[{
+		"append": false,
+		"header": {
+			"key": "Cache-Control",
+			"value": "private, no-store"
+		}
+	}]`
+
+	t.Run("authenticated", func(t *testing.T) {
+		headers := getResponseHeadersToAdd(options, &config.Policy{})
+		testutil.AssertProtoJSONEqual(t, noStore, headers)
+	})
+	t.Run("caching allowed", func(t *testing.T) {
+		headers := getResponseHeadersToAdd(options, &config.Policy{AllowResponseCaching: true})
+		assert.Empty(t, headers)
+	})
+	t.Run("public", func(t *testing.T) {
+		headers := getResponseHeadersToAdd(options, &config.Policy{AllowPublicUnauthenticatedAccess: true})
+		assert.Empty(t, headers)
+	})
+	t.Run("deprecated", func(t *testing.T) {
+		headers := getResponseHeadersToAdd(options, &config.Policy{
+			AllowPublicUnauthenticatedAccess: true,
+			Deprecation:                      "2026-01-01T00:00:00Z",
+			Sunset:                           "2026-07-01T12:00:00+02:00",
+		})
+		testutil.AssertProtoJSONEqual(t, `[{
+			"append": false,
+			"header": {
+				"key": "Deprecation",
+				"value": "@1767225600"
+			}
+		}, {
+			"append": false,
+			"header": {
+				"key": "Sunset",
+				"value": "Wed, 01 Jul 2026 10:00:00 GMT"
+			}
+		}]`, headers)
+	})
+	t.Run("security headers override", func(t *testing.T) {
+		headers := getResponseHeadersToAdd(&config.Options{
+			Headers: map[string]string{"X-Frame-Options": "SAMEORIGIN"},
+		}, &config.Policy{
+			AllowPublicUnauthenticatedAccess: true,
+			SecurityHeaders: map[string]string{
+				"Content-Security-Policy": "default-src 'self'",
+				"X-Frame-Options":         "DENY",
+			},
+		})
+		testutil.AssertProtoJSONEqual(t, `[{
+			"append": false,
+			"header": {
+				"key": "Content-Security-Policy",
+				"value": "default-src 'self'"
+			}
+		}, {
+			"append": false,
+			"header": {
+				"key": "X-Frame-Options",
+				"value": "DENY"
+			}
+		}]`, headers)
+	})
+	t.Run("security headers merge", func(t *testing.T) {
+		policy := &config.Policy{
+			AllowPublicUnauthenticatedAccess: true,
+			SecurityHeaders: map[string]string{
+				"Content-Security-Policy": "default-src 'self'",
+				"X-Frame-Options":         "DENY",
+			},
+			SecurityHeadersMode: config.SecurityHeadersMerge,
+		}
+		headers := getResponseHeadersToAdd(&config.Options{
+			Headers: map[string]string{"X-Frame-Options": "SAMEORIGIN"},
+		}, policy)
+		testutil.AssertProtoJSONEqual(t, `[{
+			"append": true,
+			"header": {
+				"key": "Content-Security-Policy",
+				"value": "default-src 'self'"
+			}
+		}]`, headers)
+		testutil.AssertProtoJSONEqual(t, `{
+			"x-frame-options": "DENY"
+		}`, getDefaultResponseHeaders(policy))
+	})
+}
+
+func Test_buildPolicyRoutesRewriteResponseLocation(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName: "pomerium",
+		Policies: []config.Policy{
+			{
+				Source:                  &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:             mustParseURL("http://internal.example.com:8080"),
+				RewriteResponseLocation: true,
+			},
+			{
+				Source:                  &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:             mustParseURL("http://internal.example.com:8080"),
+				Prefix:                  "/app/",
+				PrefixRewrite:           "/",
+				RewriteResponseLocation: true,
+			},
+			{
+				Source:      &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination: mustParseURL("http://internal.example.com:8080"),
+			},
+		},
+	}, "from.example.com")
+	if !assert.Len(t, routes, 3) {
+		return
+	}
+
+	assert.Equal(t, map[string]string{
+		"remove_pomerium_cookie":             "pomerium",
+		"remove_pomerium_query_param":        "pomerium_session",
+		"remove_pomerium_websocket_protocol": "pomerium.session.",
+		"rewrite_response_location_from":     "http://internal.example.com:8080",
+		"rewrite_response_location_to":       "https://from.example.com",
+	}, luaStringMetadata(routes[0]))
+	assert.Equal(t, map[string]string{
+		"remove_pomerium_cookie":             "pomerium",
+		"remove_pomerium_query_param":        "pomerium_session",
+		"remove_pomerium_websocket_protocol": "pomerium.session.",
+		"rewrite_response_location_from":     "http://internal.example.com:8080",
+		"rewrite_response_location_to":       "https://from.example.com/app",
+	}, luaStringMetadata(routes[1]))
+	assert.Equal(t, map[string]string{
+		"remove_pomerium_cookie":             "pomerium",
+		"remove_pomerium_query_param":        "pomerium_session",
+		"remove_pomerium_websocket_protocol": "pomerium.session.",
+	}, luaStringMetadata(routes[2]))
+}
+
+func Test_buildPolicyRoutesSessionQueryParam(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName: "pomerium",
+		Policies: []config.Policy{
+			{
+				Source:      &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination: mustParseURL("http://internal.example.com"),
+			},
+			{
+				Source:                    &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:               mustParseURL("http://internal.example.com"),
+				PreserveSessionQueryParam: true,
+			},
+		},
+	}, "from.example.com")
+	if !assert.Len(t, routes, 2) {
+		return
+	}
+
+	assert.Equal(t, "pomerium_session", luaStringMetadata(routes[0])["remove_pomerium_query_param"],
+		"session query param should be stripped by default")
+	assert.NotContains(t, luaStringMetadata(routes[1]), "remove_pomerium_query_param")
+}
+
+func Test_buildPolicyRoutesUpstreamSetCookie(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName: "_pomerium",
+		Policies: []config.Policy{
+			{
+				Source:      &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination: mustParseURL("http://internal.example.com"),
+			},
+			{
+				Source:                &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:           mustParseURL("http://internal.example.com"),
+				UpstreamSetCookieMode: config.UpstreamSetCookieFilter,
+			},
+			{
+				Source:                 &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:            mustParseURL("http://internal.example.com"),
+				UpstreamSetCookieMode:  config.UpstreamSetCookieLimit,
+				UpstreamSetCookieLimit: 20,
+			},
+		},
+	}, "from.example.com")
+	if !assert.Len(t, routes, 3) {
+		return
+	}
+
+	getFilter := func(route *envoy_config_route_v3.Route) *structpb.Value {
+		return route.GetMetadata().GetFilterMetadata()["envoy.filters.http.lua"].GetFields()["filter_upstream_set_cookie"]
+	}
+	assert.Nil(t, getFilter(routes[0]), "upstream cookies should be passed through by default")
+	testutil.AssertProtoJSONEqual(t, `{
+		"cookie_name": "_pomerium"
+	}`, getFilter(routes[1]))
+	testutil.AssertProtoJSONEqual(t, `{
+		"cookie_name": "_pomerium",
+		"limit": 20
+	}`, getFilter(routes[2]))
+}
+
+// luaStringMetadata returns the string values of a route's lua filter metadata.
+func luaStringMetadata(route *envoy_config_route_v3.Route) map[string]string {
+	m := map[string]string{}
+	for k, v := range route.GetMetadata().GetFilterMetadata()["envoy.filters.http.lua"].GetFields() {
+		if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			m[k] = s.StringValue
+		}
+	}
+	return m
+}
+
+func Test_buildPolicyRoutesPublicAccess(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName:       "pomerium",
+		JWTClaimsHeaders: []string{"email"},
+		Policies: []config.Policy{
+			{
+				Source:                           &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:                      mustParseURL("http://internal.example.com"),
+				Prefix:                           "/assets/",
+				AllowPublicUnauthenticatedAccess: true,
+				PassIdentityHeaders:              true,
+			},
+			{
+				Source:              &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:         mustParseURL("http://internal.example.com"),
+				AllowedUsers:        []string{"user@example.com"},
+				PassIdentityHeaders: true,
+			},
+		},
+	}, "from.example.com")
+	if !assert.Len(t, routes, 2) {
+		return
+	}
+
+	assert.Equal(t, disableExtAuthz, routes[0].GetTypedPerFilterConfig()["envoy.filters.http.ext_authz"],
+		"public routes should skip authorization")
+	assert.Equal(t, "pomerium", luaStringMetadata(routes[0])["remove_pomerium_cookie"],
+		"public routes should still strip the session cookie")
+	assert.Empty(t, routes[1].GetTypedPerFilterConfig(),
+		"protected routes should require authorization")
+
+	assert.Contains(t, routes[0].GetRequestHeadersToRemove(), "x-pomerium-jwt-assertion",
+		"public routes should strip spoofed identity headers")
+	assert.Contains(t, routes[0].GetRequestHeadersToRemove(), "x-pomerium-claim-email",
+		"public routes should strip spoofed identity headers")
+	assert.Equal(t, "strict", luaStringMetadata(routes[0])["check_request_framing"],
+		"public routes should check the request framing")
+	assert.NotContains(t, routes[1].GetRequestHeadersToRemove(), "x-pomerium-jwt-assertion",
+		"authorize sets the identity headers of protected routes")
+	assert.NotContains(t, luaStringMetadata(routes[1]), "check_request_framing",
+		"authorize checks the request framing of protected routes")
+}
+
+func Test_buildPolicyRoutesAdminsOnly(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName: "pomerium",
+		AdminsOnly: true,
+		Policies: []config.Policy{
+			{
+				Source:                           &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:                      mustParseURL("http://internal.example.com"),
+				AllowPublicUnauthenticatedAccess: true,
+			},
+		},
+	}, "from.example.com")
+	if !assert.Len(t, routes, 1) {
+		return
+	}
+
+	assert.Empty(t, routes[0].GetTypedPerFilterConfig(),
+		"public routes should require authorization in admins only mode")
+	assert.NotContains(t, luaStringMetadata(routes[0]), "check_request_framing",
+		"authorize checks the request framing")
+}
+
+func Test_buildPolicyRoutesWebsocketReauthorizeInterval(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName: "pomerium",
+		Policies: []config.Policy{
+			{
+				Source:                       &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:                  mustParseURL("http://internal.example.com"),
+				AllowWebsockets:              true,
+				WebsocketReauthorizeInterval: 5 * time.Minute,
+			},
+			{
+				Source:          &config.StringURL{URL: mustParseURL("https://from.example.com")},
+				Destination:     mustParseURL("http://internal.example.com"),
+				Prefix:          "/plain",
+				AllowWebsockets: true,
+			},
+		},
+	}, "from.example.com")
+	if !assert.Len(t, routes, 3) {
+		return
+	}
+
+	// upgrades are matched first, and closed after the interval so that the
+	// reconnect is authorized again
+	assert.Equal(t, "policy-0-websocket", routes[0].GetName())
+	testutil.AssertProtoJSONEqual(t, `{
+		"prefix": "/",
+		"headers": [{
+			"name": "upgrade",
+			"safeRegexMatch": { "googleRe2": {}, "regex": "(?i)websocket" }
+		}]
+	}`, routes[0].GetMatch())
+	assert.Equal(t, 5*time.Minute, routes[0].GetRoute().GetTimeout().AsDuration())
+	assert.Equal(t, routes[1].GetRoute().GetCluster(), routes[0].GetRoute().GetCluster())
+
+	// other requests are unaffected
+	assert.Equal(t, "policy-0", routes[1].GetName())
+	assert.Empty(t, routes[1].GetMatch().GetHeaders())
+	assert.Equal(t, time.Duration(0), routes[1].GetRoute().GetTimeout().AsDuration())
+	assert.Equal(t, "policy-1", routes[2].GetName())
+}
+
+func Test_buildPolicyRoutesWebsocketConnectionLimit(t *testing.T) {
+	options := &config.Options{
+		CookieName: "pomerium",
+		Policies: []config.Policy{{
+			Source:                  &config.StringURL{URL: mustParseURL("https://from.example.com")},
+			Destination:             mustParseURL("http://internal.example.com"),
+			AllowWebsockets:         true,
+			MaxWebsocketConnections: 2,
+		}},
+	}
+	policy := &options.Policies[0]
+	routes := buildPolicyRoutes(options, "from.example.com")
+	maxRequests := map[string]uint32{}
+	for _, cluster := range []*envoy_config_cluster_v3.Cluster{
+		buildPolicyCluster(options, policy),
+		buildPolicyWebsocketCluster(options, policy),
+	} {
+		for _, thresholds := range cluster.GetCircuitBreakers().GetThresholds() {
+			maxRequests[cluster.Name] = thresholds.GetMaxRequests().GetValue()
+		}
+	}
+
+	// send simulates envoy routing a request which stays open, such as an
+	// upgraded websocket connection, and reports whether the circuit breaker
+	// of the cluster it's routed to let it through
+	open := map[string]uint32{}
+	send := func(headers map[string]string) bool {
+		for _, route := range routes {
+			if !routeMatchesHeaders(t, route, headers) {
+				continue
+			}
+			cluster := route.GetRoute().GetCluster()
+			if limit := maxRequests[cluster]; limit > 0 && open[cluster] >= limit {
+				return false
+			}
+			open[cluster]++
+			return true
+		}
+		t.Fatal("no route matched")
+		return false
+	}
+	upgrade := map[string]string{"upgrade": "websocket"}
+
+	assert.True(t, send(upgrade))
+	assert.True(t, send(upgrade))
+	assert.False(t, send(upgrade), "upgrades beyond the limit should be rejected")
+	for i := 0; i < 10; i++ {
+		assert.True(t, send(nil), "other requests shouldn't count towards the websocket limit")
+	}
+}
+
+// routeMatchesHeaders returns true if the request headers match the route's
+// header matchers. Only the regex matchers used by the policy routes are
+// supported.
+func routeMatchesHeaders(t *testing.T, route *envoy_config_route_v3.Route, headers map[string]string) bool {
+	for _, matcher := range route.GetMatch().GetHeaders() {
+		re := matcher.GetSafeRegexMatch().GetRegex()
+		require.NotEmpty(t, re, "unsupported header matcher")
+		value, ok := headers[matcher.GetName()]
+		if !ok || !regexp.MustCompile("^(?:"+re+")$").MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
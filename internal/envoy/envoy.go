@@ -22,6 +22,8 @@ import (
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	envoy_config_metrics_v3 "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v3"
+	envoy_config_overload_v3 "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	envoy_config_resource_monitor_fixed_heap_v2alpha "github.com/envoyproxy/go-control-plane/envoy/config/resource_monitor/fixed_heap/v2alpha"
 	envoy_config_trace_v3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
 	"github.com/google/go-cmp/cmp"
 
@@ -47,6 +49,7 @@ type serverOptions struct {
 	services       string
 	logLevel       string
 	tracingOptions trace.TracingOptions
+	maxHeapBytes   uint64
 }
 
 // A Server is a pomerium proxy implemented via envoy.
@@ -124,6 +127,7 @@ func (srv *Server) update(cfg *config.Config) {
 		services:       cfg.Options.Services,
 		logLevel:       firstNonEmpty(cfg.Options.ProxyLogLevel, cfg.Options.LogLevel, "debug"),
 		tracingOptions: *tracingOptions,
+		maxHeapBytes:   cfg.Options.ProxyMaxHeapBytes,
 	}
 
 	if cmp.Equal(srv.options, options, cmp.AllowUnexported(serverOptions{})) {
@@ -307,6 +311,7 @@ func (srv *Server) buildBootstrapConfig() ([]byte, error) {
 		DynamicResources: dynamicCfg,
 		StaticResources:  staticCfg,
 		StatsConfig:      srv.buildStatsConfig(),
+		OverloadManager:  srv.buildOverloadManager(),
 	}
 
 	if err := srv.addTraceConfig(cfg); err != nil {
@@ -334,6 +339,37 @@ func (srv *Server) buildStatsConfig() *envoy_config_metrics_v3.StatsConfig {
 	return cfg
 }
 
+// buildOverloadManager configures envoy to stop accepting new requests while
+// its heap is over the configured size. Requests that were already accepted
+// are allowed to finish.
+func (srv *Server) buildOverloadManager() *envoy_config_overload_v3.OverloadManager {
+	if srv.options.maxHeapBytes == 0 {
+		return nil
+	}
+
+	heapTC, _ := ptypes.MarshalAny(&envoy_config_resource_monitor_fixed_heap_v2alpha.FixedHeapConfig{
+		MaxHeapSizeBytes: srv.options.maxHeapBytes,
+	})
+	return &envoy_config_overload_v3.OverloadManager{
+		RefreshInterval: ptypes.DurationProto(250 * time.Millisecond),
+		ResourceMonitors: []*envoy_config_overload_v3.ResourceMonitor{{
+			Name: "envoy.resource_monitors.fixed_heap",
+			ConfigType: &envoy_config_overload_v3.ResourceMonitor_TypedConfig{
+				TypedConfig: heapTC,
+			},
+		}},
+		Actions: []*envoy_config_overload_v3.OverloadAction{{
+			Name: "envoy.overload_actions.stop_accepting_requests",
+			Triggers: []*envoy_config_overload_v3.Trigger{{
+				Name: "envoy.resource_monitors.fixed_heap",
+				TriggerOneof: &envoy_config_overload_v3.Trigger_Threshold{
+					Threshold: &envoy_config_overload_v3.ThresholdTrigger{Value: 1},
+				},
+			}},
+		}},
+	}
+}
+
 func (srv *Server) addTraceConfig(bootCfg *envoy_config_bootstrap_v3.Bootstrap) error {
 	if !srv.options.tracingOptions.Enabled() {
 		return nil
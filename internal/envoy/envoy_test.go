@@ -98,6 +98,32 @@ func Test_buildStatsConfig(t *testing.T) {
 	}
 }
 
+func Test_buildOverloadManager(t *testing.T) {
+	srv := &Server{}
+	assert.Nil(t, srv.buildOverloadManager())
+
+	srv.options.maxHeapBytes = 1 << 30
+	testutil.AssertProtoJSONEqual(t, `{
+		"refreshInterval": "0.250s",
+		"resourceMonitors": [{
+			"name": "envoy.resource_monitors.fixed_heap",
+			"typedConfig": {
+				"@type": "type.googleapis.com/envoy.config.resource_monitor.fixed_heap.v2alpha.FixedHeapConfig",
+				"maxHeapSizeBytes": "1073741824"
+			}
+		}],
+		"actions": [{
+			"name": "envoy.overload_actions.stop_accepting_requests",
+			"triggers": [{
+				"name": "envoy.resource_monitors.fixed_heap",
+				"threshold": {
+					"value": 1
+				}
+			}]
+		}]
+	}`, srv.buildOverloadManager())
+}
+
 func TestServer_handleLogs(t *testing.T) {
 	logFormatRE := regexp.MustCompile(`^[[]LOG_FORMAT[]](.*?)--(.*?)--(.*?)$`)
 	line := "[LOG_FORMAT]debug--filter--[external/envoy/source/extensions/filters/listener/tls_inspector/tls_inspector.cc:78] tls inspector: new connection accepted"
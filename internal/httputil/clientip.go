@@ -0,0 +1,95 @@
+package httputil
+
+import (
+	"net"
+	"strings"
+)
+
+// privateRanges are the private, loopback, link-local and otherwise reserved
+// address ranges which never identify a client on the public internet.
+var privateRanges = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+// ClientIPOptions configures how the client IP is derived from a request.
+type ClientIPOptions struct {
+	// MaxLength is the maximum number of X-Forwarded-For entries considered.
+	// Only the right-most entries are kept. Zero means no limit.
+	MaxLength int
+	// FilterPrivateRanges skips private and reserved addresses.
+	FilterPrivateRanges bool
+}
+
+// ClientIP returns the IP address of the client which made a request, given
+// the value of its X-Forwarded-For header and the address of the peer which
+// sent it.
+//
+// Each proxy appends the address of the peer it received the request from,
+// so entries further left are supplied by the client and can't be trusted.
+// The chain is therefore walked from the right: it's first truncated to its
+// right-most MaxLength entries, then invalid and, optionally, private
+// addresses (the hops of trusted proxies) are skipped and the first remaining
+// address is returned. If no address remains, the peer address is used.
+func ClientIP(forwardedFor, remoteAddr string, opts ClientIPOptions) string {
+	var chain []string
+	if forwardedFor != "" {
+		chain = strings.Split(forwardedFor, ",")
+	}
+	if opts.MaxLength > 0 && len(chain) > opts.MaxLength {
+		chain = chain[len(chain)-opts.MaxLength:]
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(chain[i]))
+		if ip == nil {
+			continue
+		}
+		if opts.FilterPrivateRanges && isPrivateIP(ip) {
+			continue
+		}
+		return ip.String()
+	}
+
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, r := range privateRanges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
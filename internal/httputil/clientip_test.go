@@ -0,0 +1,37 @@
+package httputil
+
+import "testing"
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		forwardedFor string
+		remoteAddr   string
+		opts         ClientIPOptions
+		want         string
+	}{
+		{"no header", "", "203.0.113.9:443", ClientIPOptions{}, "203.0.113.9"},
+		{"no header or peer", "", "", ClientIPOptions{}, ""},
+		{"last entry", "198.51.100.1, 203.0.113.5", "10.0.0.1:443", ClientIPOptions{}, "203.0.113.5"},
+		{"spoofed left entry", "1.1.1.1, 198.51.100.1", "10.0.0.1:443", ClientIPOptions{}, "198.51.100.1"},
+		{"spoofed left entry behind private hops", "1.1.1.1, 198.51.100.1, 10.1.2.3", "10.0.0.1:443", ClientIPOptions{FilterPrivateRanges: true}, "198.51.100.1"},
+		{"private entries kept", "198.51.100.1, 10.1.2.3", "10.0.0.1:443", ClientIPOptions{}, "10.1.2.3"},
+		{"private entries filtered", "198.51.100.1, 10.1.2.3, 192.168.0.1, fd00::1, 172.16.0.9", "10.0.0.1:443", ClientIPOptions{FilterPrivateRanges: true}, "198.51.100.1"},
+		{"only private entries", "10.1.2.3, 127.0.0.1", "10.0.0.1:443", ClientIPOptions{FilterPrivateRanges: true}, "10.0.0.1"},
+		{"invalid entries skipped", "198.51.100.1, unknown, not-an-ip", "10.0.0.1:443", ClientIPOptions{}, "198.51.100.1"},
+		{"over-long chain truncated", "1.1.1.1, 2.2.2.2, 3.3.3.3, 10.0.0.2, 10.0.0.3", "198.51.100.1:443", ClientIPOptions{MaxLength: 2, FilterPrivateRanges: true}, "198.51.100.1"},
+		{"chain within limit", "198.51.100.1, 203.0.113.5", "10.0.0.1:443", ClientIPOptions{MaxLength: 2}, "203.0.113.5"},
+		{"truncated and filtered", "1.1.1.1, 198.51.100.1, 10.0.0.2", "10.0.0.1:443", ClientIPOptions{MaxLength: 2, FilterPrivateRanges: true}, "198.51.100.1"},
+		{"ipv6 peer", "", "[2001:db8::1]:443", ClientIPOptions{}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := ClientIP(tt.forwardedFor, tt.remoteAddr, tt.opts); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package httputil
 
 import (
+	"context"
 	"encoding/json"
 	"html/template"
 	"net/http"
+	"time"
 
 	"github.com/pomerium/pomerium/internal/frontend"
 	"github.com/pomerium/pomerium/internal/log"
@@ -57,16 +59,56 @@ type errResponse struct {
 	Version    string `json:"-"`
 }
 
+// JSONError is the body of error responses when JSON errors are enabled.
+type JSONError struct {
+	Status    int    `json:"status"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// NewJSONError returns the JSON error body for the given status and message.
+func NewJSONError(status int, message, requestID string) JSONError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return JSONError{
+		Status:    status,
+		Message:   message,
+		RequestID: requestID,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+type jsonErrorsKey struct{}
+
+// WithJSONErrors returns a copy of ctx in which ErrorResponse always replies
+// with a JSONError, regardless of what the client accepts.
+func WithJSONErrors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jsonErrorsKey{}, true)
+}
+
 // ErrorResponse replies to the request with the specified error message and HTTP code.
 // It does not otherwise end the request; the caller should ensure no further
 // writes are done to w.
 func (e *HTTPError) ErrorResponse(w http.ResponseWriter, r *http.Request) {
 	// indicate to clients that the error originates from Pomerium, not the app
 	w.Header().Set(HeaderPomeriumResponse, "true")
-	w.WriteHeader(e.Status)
 
 	log.FromRequest(r).Info().Err(e).Msg("httputil: ErrorResponse")
 	requestID := requestid.FromContext(r.Context())
+
+	if jsonErrors, _ := r.Context().Value(jsonErrorsKey{}).(bool); jsonErrors {
+		w.Header().Set("Content-Type", "application/json")
+		if requestID != "" {
+			w.Header().Set(requestid.HeaderName, requestID)
+		}
+		w.WriteHeader(e.Status)
+		_ = json.NewEncoder(w).Encode(NewJSONError(e.Status, e.Error(), requestID))
+		return
+	}
+
+	w.WriteHeader(e.Status)
 	response := errResponse{
 		Status:     e.Status,
 		StatusText: http.StatusText(e.Status),
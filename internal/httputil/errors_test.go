@@ -1,12 +1,18 @@
 package httputil
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 )
 
 func TestHTTPError_ErrorResponse(t *testing.T) {
@@ -51,6 +57,25 @@ func TestHTTPError_ErrorResponse(t *testing.T) {
 	}
 }
 
+func TestHTTPError_ErrorResponse_jsonErrors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(WithJSONErrors(requestid.WithValue(r.Context(), "REQUEST_ID")))
+	w := httptest.NewRecorder()
+	NewError(http.StatusForbidden, errors.New("denied")).(*HTTPError).ErrorResponse(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "REQUEST_ID", w.Header().Get(requestid.HeaderName))
+
+	var body JSONError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusForbidden, body.Status)
+	assert.Equal(t, "Forbidden: denied", body.Message)
+	assert.Equal(t, "REQUEST_ID", body.RequestID)
+	_, err := time.Parse(time.RFC3339, body.Timestamp)
+	assert.NoError(t, err)
+}
+
 func TestNewError(t *testing.T) {
 	tests := []struct {
 		name    string
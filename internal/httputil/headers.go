@@ -3,6 +3,10 @@ package httputil
 // AuthorizationTypePomerium is for Authorization: Pomerium JWT... headers
 const AuthorizationTypePomerium = "Pomerium"
 
+// WebSocketProtocolPomerium prefixes the session token when it's passed as one
+// of the protocols in a Sec-WebSocket-Protocol header.
+const WebSocketProtocolPomerium = "pomerium.session."
+
 // Standard headers
 const (
 	HeaderReferrer = "Referer"
@@ -17,6 +21,13 @@ const (
 	HeaderPomeriumResponse = "x-pomerium-intercepted-response"
 	// HeaderPomeriumJWTAssertion is the header key containing JWT signed user details.
 	HeaderPomeriumJWTAssertion = "x-pomerium-jwt-assertion"
+	// HeaderPomeriumServerTiming is used to pass authorization timings to
+	// envoy, which moves them to the Server-Timing response header.
+	HeaderPomeriumServerTiming = "x-pomerium-server-timing"
+	// HeaderPomeriumDenialReason and HeaderPomeriumDenialRoute surface why
+	// the authorize service denied a request, if enabled.
+	HeaderPomeriumDenialReason = "x-pomerium-denial-reason"
+	HeaderPomeriumDenialRoute  = "x-pomerium-denial-route"
 )
 
 // HeadersContentSecurityPolicy are the content security headers added to the service's handlers
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/pomerium/pomerium/internal/directory"
+	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 )
 
@@ -23,6 +24,11 @@ type config struct {
 	groupRefreshTimeout           time.Duration
 	sessionRefreshGracePeriod     time.Duration
 	sessionRefreshCoolOffDuration time.Duration
+	claimsLimit                   sessions.ClaimsLimit
+	sessionExpiryFromIDToken      bool
+	maxRefreshTokenBytes          int
+	discardLargeRefreshTokens     bool
+	sessionNonce                  bool
 }
 
 func newConfig(options ...Option) *config {
@@ -89,6 +95,42 @@ func WithSessionRefreshCoolOffDuration(dur time.Duration) Option {
 	}
 }
 
+// WithClaimsLimit sets the limit on the identity provider claims stored with
+// sessions and users when they are refreshed.
+func WithClaimsLimit(limit sessions.ClaimsLimit) Option {
+	return func(cfg *config) {
+		cfg.claimsLimit = limit
+	}
+}
+
+// WithSessionExpiryFromIDToken keeps the expiry of sessions in step with the
+// expiry of their id token when they are refreshed, rather than leaving it
+// fixed at the time they were created.
+func WithSessionExpiryFromIDToken(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.sessionExpiryFromIDToken = enabled
+	}
+}
+
+// WithRefreshTokenLimit sets the maximum size of the refresh tokens returned
+// when sessions are refreshed. Sessions with larger tokens are deleted, unless
+// discard is set, in which case the token is discarded and the session is
+// deleted on its next refresh instead.
+func WithRefreshTokenLimit(maxBytes int, discard bool) Option {
+	return func(cfg *config) {
+		cfg.maxRefreshTokenBytes = maxBytes
+		cfg.discardLargeRefreshTokens = discard
+	}
+}
+
+// WithSessionNonce keeps only the most recently created session of each user,
+// deleting the user's other sessions whenever one of them is updated.
+func WithSessionNonce(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.sessionNonce = enabled
+	}
+}
+
 type atomicConfig struct {
 	value atomic.Value
 }
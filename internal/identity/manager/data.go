@@ -12,16 +12,40 @@ import (
 	"github.com/pomerium/pomerium/pkg/grpc/user"
 )
 
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// monotonicNow returns the current time on a monotonic clock. Intervals
+// between refreshes are measured with it, so correcting the wall clock
+// doesn't shorten or extend them. Pulled out as a variable for tests.
+var monotonicNow = func() time.Duration {
+	return time.Since(processStart)
+}
+
+var processStart = time.Now()
+
+// sinceMonotonic returns when the given interval will have elapsed since the
+// monotonic time start, as a wall clock time. A zero start is treated as
+// never, and is always due.
+func sinceMonotonic(start, interval time.Duration) time.Time {
+	now := timeNow()
+	if start == 0 {
+		return now
+	}
+	return now.Add(interval - (monotonicNow() - start))
+}
+
 // A User is a user managed by the Manager.
 type User struct {
 	*user.User
-	lastRefresh     time.Time
+	// lastRefresh is the monotonic time the user was last refreshed.
+	lastRefresh     time.Duration
 	refreshInterval time.Duration
 }
 
 // NextRefresh returns the next time the user information needs to be refreshed.
 func (u User) NextRefresh() time.Time {
-	return u.lastRefresh.Add(u.refreshInterval)
+	return sinceMonotonic(u.lastRefresh, u.refreshInterval)
 }
 
 // UnmarshalJSON unmarshals json data into the user object.
@@ -63,11 +87,15 @@ func (u *User) UnmarshalJSON(data []byte) error {
 // A Session is a session managed by the Manager.
 type Session struct {
 	*session.Session
-	lastRefresh time.Time
+	// lastRefresh is the monotonic time the session was last refreshed.
+	lastRefresh time.Duration
 	// gracePeriod is the amount of time before expiration to attempt a refresh.
 	gracePeriod time.Duration
 	// coolOffDuration is the amount of time to wait before attempting another refresh.
 	coolOffDuration time.Duration
+	// createdAt is the time the session's databroker record was created,
+	// which is when the user signed in.
+	createdAt time.Time
 }
 
 // NextRefresh returns the next time the session needs to be refreshed.
@@ -93,8 +121,10 @@ func (s Session) NextRefresh() time.Time {
 		}
 	}
 
-	// don't refresh any quicker than the cool-off duration
-	min := s.lastRefresh.Add(s.coolOffDuration)
+	// don't refresh any quicker than the cool-off duration. Expiry times
+	// come from the wall clock, but the cool-off is measured on the
+	// monotonic clock so that wall clock jumps don't affect it.
+	min := sinceMonotonic(s.lastRefresh, s.coolOffDuration)
 	if tm.Before(min) {
 		tm = min
 	}
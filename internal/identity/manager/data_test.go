@@ -31,11 +31,25 @@ func TestUser_UnmarshalJSON(t *testing.T) {
 	}, u.Claims)
 }
 
+// setClock replaces the wall and monotonic clocks with ones reading the
+// given variables, until the test finishes.
+func setClock(t *testing.T, wall *time.Time, mono *time.Duration) {
+	oldTimeNow, oldMonotonicNow := timeNow, monotonicNow
+	timeNow = func() time.Time { return *wall }
+	monotonicNow = func() time.Duration { return *mono }
+	t.Cleanup(func() {
+		timeNow, monotonicNow = oldTimeNow, oldMonotonicNow
+	})
+}
+
 func TestSession_NextRefresh(t *testing.T) {
 	tm1 := time.Date(2020, 6, 5, 12, 0, 0, 0, time.UTC)
+	wall, mono := tm1, time.Hour
+	setClock(t, &wall, &mono)
+
 	s := Session{
 		Session:         &session.Session{},
-		lastRefresh:     tm1,
+		lastRefresh:     mono,
 		gracePeriod:     time.Second * 10,
 		coolOffDuration: time.Minute,
 	}
@@ -54,6 +68,46 @@ func TestSession_NextRefresh(t *testing.T) {
 	assert.Equal(t, tm3, s.NextRefresh())
 }
 
+func TestSession_NextRefreshClockJump(t *testing.T) {
+	tm1 := time.Date(2020, 6, 5, 12, 0, 0, 0, time.UTC)
+	wall, mono := tm1, time.Hour
+	setClock(t, &wall, &mono)
+
+	s := Session{
+		Session:         &session.Session{},
+		lastRefresh:     mono,
+		coolOffDuration: time.Minute,
+	}
+
+	// 20 seconds after the refresh, the wall clock is corrected an hour back
+	mono += 20 * time.Second
+	wall = tm1.Add(20 * time.Second).Add(-time.Hour)
+	assert.Equal(t, wall.Add(40*time.Second), s.NextRefresh(),
+		"cool-off should end 40 seconds from now")
+
+	// and then an hour forward again
+	wall = wall.Add(2 * time.Hour)
+	assert.Equal(t, wall.Add(40*time.Second), s.NextRefresh(),
+		"cool-off should end 40 seconds from now")
+}
+
+func TestUser_NextRefresh(t *testing.T) {
+	tm1 := time.Date(2020, 6, 5, 12, 0, 0, 0, time.UTC)
+	wall, mono := tm1, time.Hour
+	setClock(t, &wall, &mono)
+
+	u := User{refreshInterval: 10 * time.Minute}
+	assert.Equal(t, tm1, u.NextRefresh(), "users which were never refreshed should be due")
+
+	u.lastRefresh = mono
+	assert.Equal(t, tm1.Add(10*time.Minute), u.NextRefresh())
+
+	mono += time.Minute
+	wall = tm1.Add(time.Minute).Add(-time.Hour)
+	assert.Equal(t, wall.Add(9*time.Minute), u.NextRefresh(),
+		"refresh interval should be unaffected by the wall clock jump")
+}
+
 func TestSession_UnmarshalJSON(t *testing.T) {
 	tm := time.Date(2020, 6, 5, 12, 0, 0, 0, time.UTC)
 	pbtm, _ := ptypes.TimestampProto(tm)
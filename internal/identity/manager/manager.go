@@ -351,6 +351,16 @@ func (mgr *Manager) refreshSession(ctx context.Context, userID, sessionID string
 		return
 	}
 
+	if s.OauthToken.GetRefreshToken() == DiscardedRefreshToken {
+		mgr.log.Info().
+			Str("user_id", s.GetUserId()).
+			Str("session_id", s.GetId()).
+			Msg("session refresh token was discarded, deleting session")
+		mgr.deleteSession(ctx, s.Session)
+		return
+	}
+
+	idTokenExpiresAt := s.GetIdToken().GetExpiresAt()
 	newToken, err := mgr.cfg.Load().authenticator.Refresh(ctx, FromOAuthToken(s.OauthToken), &s)
 	if isTemporaryError(err) {
 		mgr.log.Error().Err(err).
@@ -366,7 +376,37 @@ func (mgr *Manager) refreshSession(ctx context.Context, userID, sessionID string
 		mgr.deleteSession(ctx, s.Session)
 		return
 	}
+	cfg := mgr.cfg.Load()
+	if err := LimitRefreshToken(newToken, cfg.maxRefreshTokenBytes, cfg.discardLargeRefreshTokens); err != nil {
+		mgr.log.Error().Err(err).
+			Str("user_id", s.GetUserId()).
+			Str("session_id", s.GetId()).
+			Msg("refresh token exceeds the limit, deleting session")
+		mgr.deleteSession(ctx, s.Session)
+		return
+	}
 	s.OauthToken = ToOAuthToken(newToken)
+	// many identity providers don't return a new id token on refresh, in
+	// which case the refreshed access token's expiry is used instead
+	if s.GetIdToken().GetExpiresAt() == idTokenExpiresAt && s.OauthToken.GetExpiresAt() != nil {
+		if s.IdToken == nil {
+			s.IdToken = new(session.IDToken)
+		}
+		s.IdToken.ExpiresAt = s.OauthToken.GetExpiresAt()
+	}
+	if mgr.cfg.Load().sessionExpiryFromIDToken {
+		s.ExpiresAt = s.GetIdToken().GetExpiresAt()
+	}
+
+	s.Session.Claims, err = mgr.cfg.Load().claimsLimit.ApplyAny(s.Session.Claims)
+	if err != nil {
+		mgr.log.Error().Err(err).
+			Str("user_id", s.GetUserId()).
+			Str("session_id", s.GetId()).
+			Msg("session claims exceed the limit, deleting session")
+		mgr.deleteSession(ctx, s.Session)
+		return
+	}
 
 	res, err := session.Set(ctx, mgr.cfg.Load().dataBrokerClient, s.Session)
 	if err != nil {
@@ -392,7 +432,7 @@ func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 			Msg("no user found for refresh")
 		return
 	}
-	u.lastRefresh = time.Now()
+	u.lastRefresh = monotonicNow()
 	mgr.userScheduler.Add(u.NextRefresh(), u.GetId())
 
 	for _, s := range mgr.sessions.GetSessionsForUser(userID) {
@@ -419,6 +459,16 @@ func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 			continue
 		}
 
+		u.User.Claims, err = mgr.cfg.Load().claimsLimit.ApplyAny(u.User.Claims)
+		if err != nil {
+			mgr.log.Error().Err(err).
+				Str("user_id", s.GetUserId()).
+				Str("session_id", s.GetId()).
+				Msg("user claims exceed the limit, deleting session")
+			mgr.deleteSession(ctx, s.Session)
+			continue
+		}
+
 		record, err := user.Set(ctx, mgr.cfg.Load().dataBrokerClient, u.User)
 		if err != nil {
 			mgr.log.Error().Err(err).
@@ -653,13 +703,20 @@ func (mgr *Manager) onUpdateSession(ctx context.Context, msg sessionMessage) {
 
 	// update session
 	s, _ := mgr.sessions.Get(msg.session.GetUserId(), msg.session.GetId())
-	s.lastRefresh = time.Now()
+	s.lastRefresh = monotonicNow()
 	s.gracePeriod = mgr.cfg.Load().sessionRefreshGracePeriod
 	s.coolOffDuration = mgr.cfg.Load().sessionRefreshCoolOffDuration
+	if createdAt, err := ptypes.Timestamp(msg.record.GetCreatedAt()); err == nil {
+		s.createdAt = createdAt
+	}
 	s.Session = msg.session
 	mgr.sessions.ReplaceOrInsert(s)
 	mgr.sessionScheduler.Add(s.NextRefresh(), toSessionSchedulerKey(msg.session.GetUserId(), msg.session.GetId()))
 
+	if mgr.cfg.Load().sessionNonce {
+		mgr.deleteSupersededSessions(ctx, msg.session.GetUserId())
+	}
+
 	// create the user if it doesn't exist yet
 	if _, ok := mgr.users.Get(msg.session.GetUserId()); !ok {
 		mgr.createUser(ctx, msg.session)
@@ -676,7 +733,7 @@ func (mgr *Manager) onUpdateUser(_ context.Context, msg userMessage) {
 	u, ok := mgr.users.Get(msg.user.GetId())
 	if ok {
 		// only reset the refresh time if this is an existing user
-		u.lastRefresh = time.Now()
+		u.lastRefresh = monotonicNow()
 	}
 	u.refreshInterval = mgr.cfg.Load().groupRefreshInterval
 	u.User = msg.user
@@ -717,6 +774,37 @@ func (mgr *Manager) deleteSession(ctx context.Context, pbSession *session.Sessio
 	}
 }
 
+// deleteSupersededSessions deletes all but the most recently created session
+// of the user. Ties are broken by session id, so concurrent sign-ins always
+// leave the same single session, whatever order they're seen in.
+func (mgr *Manager) deleteSupersededSessions(ctx context.Context, userID string) {
+	userSessions := mgr.sessions.GetSessionsForUser(userID)
+	if len(userSessions) < 2 {
+		return
+	}
+
+	latest := userSessions[0]
+	for _, s := range userSessions[1:] {
+		if s.createdAt.After(latest.createdAt) ||
+			(s.createdAt.Equal(latest.createdAt) && s.GetId() > latest.GetId()) {
+			latest = s
+		}
+	}
+
+	for _, s := range userSessions {
+		if s.GetId() == latest.GetId() {
+			continue
+		}
+		mgr.log.Info().
+			Str("user_id", userID).
+			Str("session_id", s.GetId()).
+			Msg("deleting superseded session")
+		mgr.sessions.Delete(userID, s.GetId())
+		mgr.sessionScheduler.Remove(toSessionSchedulerKey(userID, s.GetId()))
+		mgr.deleteSession(ctx, s.Session)
+	}
+}
+
 func isTemporaryError(err error) bool {
 	if err == nil {
 		return false
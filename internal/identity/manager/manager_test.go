@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+type mockDataBrokerServiceClient struct {
+	databroker.DataBrokerServiceClient
+
+	delete func(ctx context.Context, in *databroker.DeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	set    func(ctx context.Context, in *databroker.SetRequest, opts ...grpc.CallOption) (*databroker.SetResponse, error)
+}
+
+func (m mockDataBrokerServiceClient) Delete(ctx context.Context, in *databroker.DeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return m.delete(ctx, in, opts...)
+}
+
+func (m mockDataBrokerServiceClient) Set(ctx context.Context, in *databroker.SetRequest, opts ...grpc.CallOption) (*databroker.SetResponse, error) {
+	return m.set(ctx, in, opts...)
+}
+
+func TestManager_sessionNonce(t *testing.T) {
+	signIn := time.Now()
+	newMessage := func(id, userID string, createdAt time.Time) sessionMessage {
+		ts, _ := ptypes.TimestampProto(createdAt)
+		return sessionMessage{
+			record:  &databroker.Record{Id: id, CreatedAt: ts},
+			session: &session.Session{Id: id, UserId: userID},
+		}
+	}
+
+	// sync applies the messages in order, as the sessions are synced from the
+	// databroker, and returns the ids of the deleted sessions along with the
+	// ids of the sessions left for the user
+	sync := func(enabled bool, msgs ...sessionMessage) (deleted, kept []string) {
+		mgr := New(
+			WithSessionNonce(enabled),
+			WithDataBrokerClient(mockDataBrokerServiceClient{
+				delete: func(ctx context.Context, in *databroker.DeleteRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+					deleted = append(deleted, in.GetId())
+					return new(emptypb.Empty), nil
+				},
+				set: func(ctx context.Context, in *databroker.SetRequest, opts ...grpc.CallOption) (*databroker.SetResponse, error) {
+					return &databroker.SetResponse{Record: &databroker.Record{Id: in.GetId(), Data: in.GetData()}}, nil
+				},
+			}),
+		)
+		mgr.users.ReplaceOrInsert(User{User: &user.User{Id: "USER"}})
+		for _, msg := range msgs {
+			mgr.onUpdateSession(context.Background(), msg)
+		}
+		for _, s := range mgr.sessions.GetSessionsForUser("USER") {
+			kept = append(kept, s.GetId())
+		}
+		return deleted, kept
+	}
+
+	first := newMessage("FIRST", "USER", signIn)
+	second := newMessage("SECOND", "USER", signIn.Add(time.Second))
+	other := newMessage("OTHER", "OTHER_USER", signIn)
+
+	t.Run("disabled", func(t *testing.T) {
+		deleted, kept := sync(false, first, second)
+		assert.Empty(t, deleted)
+		assert.Equal(t, []string{"FIRST", "SECOND"}, kept)
+	})
+	t.Run("later sign-in", func(t *testing.T) {
+		deleted, kept := sync(true, first, other, second)
+		assert.Equal(t, []string{"FIRST"}, deleted)
+		assert.Equal(t, []string{"SECOND"}, kept)
+	})
+	t.Run("refresh of a superseded session", func(t *testing.T) {
+		// an earlier sign-in which is synced, e.g. after a refresh, once the
+		// later one is known is deleted rather than deleting the later one
+		deleted, kept := sync(true, second, first)
+		assert.Equal(t, []string{"FIRST"}, deleted)
+		assert.Equal(t, []string{"SECOND"}, kept)
+	})
+	t.Run("concurrent sign-ins", func(t *testing.T) {
+		a := newMessage("A", "USER", signIn)
+		b := newMessage("B", "USER", signIn)
+		for _, order := range [][]sessionMessage{{a, b}, {b, a}} {
+			deleted, kept := sync(true, order...)
+			assert.Equal(t, []string{"A"}, deleted, "the same session should be kept whatever the order")
+			assert.Equal(t, []string{"B"}, kept)
+		}
+	})
+}
@@ -100,6 +100,25 @@ func fromSessionSchedulerKey(key string) (userID, sessionID string) {
 	return userID, sessionID
 }
 
+// DiscardedRefreshToken is stored in place of a refresh token that was too
+// large to store. Sessions with it can't be refreshed, so are deleted when
+// they would be, which requires the user to sign in again.
+const DiscardedRefreshToken = "pomerium:discarded"
+
+// LimitRefreshToken enforces the maximum size of refresh tokens. Tokens over
+// maxBytes are either rejected, or, if discard is set, replaced with
+// DiscardedRefreshToken. Zero means no limit.
+func LimitRefreshToken(token *oauth2.Token, maxBytes int, discard bool) error {
+	if maxBytes <= 0 || len(token.RefreshToken) <= maxBytes {
+		return nil
+	}
+	if discard {
+		token.RefreshToken = DiscardedRefreshToken
+		return nil
+	}
+	return fmt.Errorf("identity provider returned a refresh token larger than %d bytes", maxBytes)
+}
+
 // FromOAuthToken converts a session oauth token to oauth2.Token.
 func FromOAuthToken(token *session.OAuthToken) *oauth2.Token {
 	expiry, _ := ptypes.Timestamp(token.GetExpiresAt())
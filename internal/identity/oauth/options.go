@@ -33,4 +33,8 @@ type Options struct {
 	// AuthCodeOptions specifies additional key value pairs query params to add
 	// to the request flow signin url.
 	AuthCodeOptions map[string]string
+
+	// AllowedAudiences are the client IDs, besides ClientID, that ID tokens
+	// may be issued for or to.
+	AllowedAudiences []string
 }
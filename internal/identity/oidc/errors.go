@@ -26,3 +26,12 @@ var ErrMissingRefreshToken = errors.New("identity/oidc: missing refresh token")
 
 // ErrMissingAccessToken is returned when no access token was found.
 var ErrMissingAccessToken = errors.New("identity/oidc: missing access token")
+
+// ErrInvalidAudience is returned when an id_token was issued for an audience
+// which isn't allowed.
+var ErrInvalidAudience = errors.New("identity/oidc: id_token has an invalid audience")
+
+// ErrInvalidAuthorizedParty is returned when an id_token was issued to an
+// authorized party which isn't allowed, or has several audiences but no
+// authorized party.
+var ErrInvalidAuthorizedParty = errors.New("identity/oidc: id_token has an invalid authorized party")
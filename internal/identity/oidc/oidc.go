@@ -51,6 +51,10 @@ type Provider struct {
 	// AuthCodeOptions specifies additional key value pairs query params to add
 	// to the request flow signin url.
 	AuthCodeOptions map[string]string
+
+	// AllowedAudiences are the client IDs that ID tokens may be issued for
+	// (aud) or to (azp).
+	AllowedAudiences []string
 }
 
 // New creates a new instance of a generic OpenID Connect provider.
@@ -68,7 +72,10 @@ func New(ctx context.Context, o *oauth.Options) (*Provider, error) {
 		return nil, fmt.Errorf("identity/oidc: could not connect to %s: %w", o.ProviderName, err)
 	}
 
-	p.Verifier = p.Provider.Verifier(&go_oidc.Config{ClientID: o.ClientID})
+	// the audience is checked against all the allowed client ids by
+	// verifyAudience instead
+	p.Verifier = p.Provider.Verifier(&go_oidc.Config{SkipClientIDCheck: true})
+	p.AllowedAudiences = append([]string{o.ClientID}, o.AllowedAudiences...)
 	p.Oauth = &oauth2.Config{
 		ClientID:     o.ClientID,
 		ClientSecret: o.ClientSecret,
@@ -181,7 +188,56 @@ func (p *Provider) getIDToken(ctx context.Context, t *oauth2.Token) (*go_oidc.ID
 	if !ok {
 		return nil, ErrMissingIDToken
 	}
-	return p.Verifier.Verify(ctx, rawIDToken)
+	idToken, err := p.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyAudience(idToken, p.AllowedAudiences); err != nil {
+		return nil, err
+	}
+	return idToken, nil
+}
+
+// verifyAudience checks that the id_token was issued for, and to, allowed
+// client ids only. Every audience must be allowed, and so must the authorized
+// party if there is one. Tokens with several audiences must name one.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func verifyAudience(idToken *go_oidc.IDToken, allowed []string) error {
+	isAllowed := func(clientID string) bool {
+		for _, a := range allowed {
+			if a != "" && a == clientID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(idToken.Audience) == 0 {
+		return ErrInvalidAudience
+	}
+	for _, aud := range idToken.Audience {
+		if !isAllowed(aud) {
+			return ErrInvalidAudience
+		}
+	}
+
+	var claims struct {
+		AuthorizedParty string `json:"azp"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return fmt.Errorf("identity/oidc: couldn't unmarshal azp claim: %w", err)
+	}
+	if claims.AuthorizedParty == "" {
+		if len(idToken.Audience) > 1 {
+			return ErrInvalidAuthorizedParty
+		}
+		return nil
+	}
+	if !isAllowed(claims.AuthorizedParty) {
+		return ErrInvalidAuthorizedParty
+	}
+	return nil
 }
 
 // Revoke enables a user to revoke her token. If the identity provider does not
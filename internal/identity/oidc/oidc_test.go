@@ -0,0 +1,91 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	go_oidc "github.com/coreos/go-oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+type testKeySet struct {
+	key *ecdsa.PublicKey
+}
+
+func (ks testKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(ks.key)
+}
+
+func TestProvider_getIDTokenAudience(t *testing.T) {
+	const issuer = "https://idp.example.com"
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+
+	p := &Provider{
+		Verifier: go_oidc.NewVerifier(issuer, testKeySet{key: &key.PublicKey}, &go_oidc.Config{
+			SkipClientIDCheck:    true,
+			SupportedSigningAlgs: []string{string(jose.ES256)},
+		}),
+		AllowedAudiences: []string{"CLIENT_ID", "OTHER_CLIENT_ID"},
+	}
+
+	tests := []struct {
+		name    string
+		aud     interface{}
+		azp     string
+		wantErr error
+	}{
+		{"client id", "CLIENT_ID", "", nil},
+		{"client id with azp", "CLIENT_ID", "CLIENT_ID", nil},
+		{"other allowed client id", "OTHER_CLIENT_ID", "", nil},
+		{"allowed audiences with azp", []string{"CLIENT_ID", "OTHER_CLIENT_ID"}, "OTHER_CLIENT_ID", nil},
+		{"mismatched aud", "ATTACKER_CLIENT_ID", "", ErrInvalidAudience},
+		{"untrusted additional aud", []string{"CLIENT_ID", "ATTACKER_CLIENT_ID"}, "CLIENT_ID", ErrInvalidAudience},
+		{"mismatched azp", "CLIENT_ID", "ATTACKER_CLIENT_ID", ErrInvalidAuthorizedParty},
+		{"several audiences without azp", []string{"CLIENT_ID", "OTHER_CLIENT_ID"}, "", ErrInvalidAuthorizedParty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{
+				"iss": issuer,
+				"sub": "USER_ID",
+				"aud": tt.aud,
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iat": time.Now().Unix(),
+			}
+			if tt.azp != "" {
+				claims["azp"] = tt.azp
+			}
+			payload, err := json.Marshal(claims)
+			require.NoError(t, err)
+			jws, err := signer.Sign(payload)
+			require.NoError(t, err)
+			rawIDToken, err := jws.CompactSerialize()
+			require.NoError(t, err)
+
+			token := (&oauth2.Token{AccessToken: "ACCESS_TOKEN"}).WithExtra(map[string]interface{}{
+				"id_token": rawIDToken,
+			})
+			idToken, err := p.getIDToken(context.Background(), token)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, "USER_ID", idToken.Subject)
+			}
+		})
+	}
+}
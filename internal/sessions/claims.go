@@ -0,0 +1,190 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// claimFuncs are the helper functions available to claim templates.
+var claimFuncs = template.FuncMap{
+	// emailDomain returns the domain portion of an email address.
+	"emailDomain": func(email string) string {
+		if i := strings.LastIndex(email, "@"); i >= 0 {
+			return email[i+1:]
+		}
+		return ""
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// ClaimTemplates derives additional session claims from the claims returned
+// by an identity provider. Each template is executed with the identity
+// provider's claims as its data, e.g. `{{ emailDomain .email }}`. Templates
+// without actions simply produce a static claim.
+type ClaimTemplates map[string]*template.Template
+
+// NewClaimTemplates parses a map of claim names to claim templates.
+func NewClaimTemplates(claims map[string]string) (ClaimTemplates, error) {
+	templates := make(ClaimTemplates, len(claims))
+	for name, text := range claims {
+		tmpl, err := template.New(name).Funcs(claimFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for claim %q: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// Execute evaluates every claim template against the given identity provider
+// claims. Claims whose templates fail to evaluate, for example because they
+// reference a claim the identity provider did not return, are omitted.
+func (ct ClaimTemplates) Execute(idpClaims map[string]interface{}) (map[string]string, error) {
+	if len(ct) == 0 {
+		return nil, nil
+	}
+
+	claims := make(map[string]string, len(ct))
+	var errs []string
+	for name, tmpl := range ct {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, idpClaims); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		claims[name] = sb.String()
+	}
+	if len(errs) > 0 {
+		return claims, fmt.Errorf("failed to evaluate claims: %s", strings.Join(errs, "; "))
+	}
+	return claims, nil
+}
+
+// ClaimsLimit bounds the custom claims carried by a session, so that a
+// misbehaving identity provider can't bloat session cookies and JWTs.
+type ClaimsLimit struct {
+	// MaxCount is the maximum number of claims. Zero means no limit.
+	MaxCount int
+	// MaxBytes is the maximum total size of the claims' names and values.
+	// Zero means no limit.
+	MaxBytes int
+	// Reject rejects sessions over the limit rather than truncating them.
+	Reject bool
+	// Priority lists the claims to keep first when truncating. The remaining
+	// claims are kept in alphabetical order.
+	Priority []string
+}
+
+// Apply enforces the limit on the given claims. If the claims are over the
+// limit they are either truncated, dropping the lowest priority claims, or
+// rejected with an error wrapping ErrClaimsLimitExceeded.
+func (l ClaimsLimit) Apply(claims map[string]string) (map[string]string, error) {
+	sizes := make(map[string]int, len(claims))
+	for k, v := range claims {
+		sizes[k] = len(k) + len(v)
+	}
+	keep, err := l.keep(sizes)
+	if err != nil {
+		return nil, err
+	} else if keep == nil {
+		return claims, nil
+	}
+	limited := make(map[string]string, len(keep))
+	for k := range keep {
+		limited[k] = claims[k]
+	}
+	return limited, nil
+}
+
+// ApplyAny enforces the limit on claims stored in the databroker, such as
+// the identity provider's claims of sessions and users, like Apply.
+func (l ClaimsLimit) ApplyAny(claims map[string]*anypb.Any) (map[string]*anypb.Any, error) {
+	sizes := make(map[string]int, len(claims))
+	for k, v := range claims {
+		sizes[k] = len(k) + len(v.GetValue())
+	}
+	keep, err := l.keep(sizes)
+	if err != nil {
+		return nil, err
+	} else if keep == nil {
+		return claims, nil
+	}
+	limited := make(map[string]*anypb.Any, len(keep))
+	for k := range keep {
+		limited[k] = claims[k]
+	}
+	return limited, nil
+}
+
+// ApplyJSON enforces the limit on JSON claims, such as those of a JWT
+// payload, like Apply. Claims are sized by their JSON encoding.
+func (l ClaimsLimit) ApplyJSON(claims map[string]interface{}) (map[string]interface{}, error) {
+	sizes := make(map[string]int, len(claims))
+	for k, v := range claims {
+		bs, _ := json.Marshal(v)
+		sizes[k] = len(k) + len(bs)
+	}
+	keep, err := l.keep(sizes)
+	if err != nil {
+		return nil, err
+	} else if keep == nil {
+		return claims, nil
+	}
+	limited := make(map[string]interface{}, len(keep))
+	for k := range keep {
+		limited[k] = claims[k]
+	}
+	return limited, nil
+}
+
+// keep returns the names of the claims to keep, given the size of each
+// claim, or nil if every claim is within the limit.
+func (l ClaimsLimit) keep(sizes map[string]int) (map[string]bool, error) {
+	var size int
+	for _, n := range sizes {
+		size += n
+	}
+	switch {
+	case l.MaxCount > 0 && len(sizes) > l.MaxCount:
+		if l.Reject {
+			return nil, fmt.Errorf("%w: %d claims, the maximum is %d", ErrClaimsLimitExceeded, len(sizes), l.MaxCount)
+		}
+	case l.MaxBytes > 0 && size > l.MaxBytes:
+		if l.Reject {
+			return nil, fmt.Errorf("%w: %d bytes of claims, the maximum is %d", ErrClaimsLimitExceeded, size, l.MaxBytes)
+		}
+	default:
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(sizes))
+	for k := range sizes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	names = append(append([]string{}, l.Priority...), names...)
+
+	keep := make(map[string]bool)
+	size = 0
+	for _, k := range names {
+		n, ok := sizes[k]
+		if !ok || keep[k] {
+			continue
+		}
+		if l.MaxCount > 0 && len(keep) >= l.MaxCount {
+			break
+		}
+		if l.MaxBytes > 0 && size+n > l.MaxBytes {
+			continue
+		}
+		size += n
+		keep[k] = true
+	}
+	return keep, nil
+}
@@ -0,0 +1,134 @@
+package sessions
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestClaimTemplates(t *testing.T) {
+	t.Parallel()
+
+	idpClaims := map[string]interface{}{
+		"email": "Bob@Example.com",
+		"sub":   "123",
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"static", map[string]string{"tenant": "acme"}, map[string]string{"tenant": "acme"}, false},
+		{"derived", map[string]string{"org": "{{ emailDomain .email | lower }}"}, map[string]string{"org": "example.com"}, false},
+		{"missing claim", map[string]string{"tenant": "acme", "team": "{{ .team }}"}, map[string]string{"tenant": "acme"}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ct, err := NewClaimTemplates(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ct.Execute(idpClaims)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClaimTemplates_invalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewClaimTemplates(map[string]string{"bad": "{{ .email "}); err == nil {
+		t.Error("expected an error for a malformed template")
+	}
+}
+
+func TestClaimsLimit(t *testing.T) {
+	t.Parallel()
+
+	claims := map[string]string{
+		"a":    "1",
+		"b":    "22",
+		"c":    "333",
+		"team": "platform",
+	}
+
+	tests := []struct {
+		name    string
+		limit   ClaimsLimit
+		want    map[string]string
+		wantErr bool
+	}{
+		{"no limit", ClaimsLimit{}, claims, false},
+		{"under limit", ClaimsLimit{MaxCount: 4, MaxBytes: 100}, claims, false},
+		{"truncate count", ClaimsLimit{MaxCount: 2}, map[string]string{"a": "1", "b": "22"}, false},
+		{"truncate count keeps priority", ClaimsLimit{MaxCount: 2, Priority: []string{"team", "missing"}}, map[string]string{"team": "platform", "a": "1"}, false},
+		{"truncate bytes", ClaimsLimit{MaxBytes: 6}, map[string]string{"a": "1", "b": "22"}, false},
+		{"truncate bytes keeps priority", ClaimsLimit{MaxBytes: 17, Priority: []string{"team"}}, map[string]string{"team": "platform", "a": "1", "b": "22"}, false},
+		{"reject count", ClaimsLimit{MaxCount: 3, Reject: true}, nil, true},
+		{"reject bytes", ClaimsLimit{MaxBytes: 10, Reject: true}, nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tt.limit.Apply(claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrClaimsLimitExceeded) {
+				t.Errorf("Apply() error = %v, want ErrClaimsLimitExceeded", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsLimit_ApplyAny(t *testing.T) {
+	t.Parallel()
+
+	claims := map[string]*anypb.Any{
+		"a":      {Value: []byte("1")},
+		"groups": {Value: []byte("a very long list of groups")},
+	}
+	got, err := ClaimsLimit{MaxBytes: 10}.ApplyAny(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]*anypb.Any{"a": claims["a"]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyAny() = %v, want %v", got, want)
+	}
+
+	if _, err := (ClaimsLimit{MaxCount: 1, Reject: true}).ApplyAny(claims); !errors.Is(err, ErrClaimsLimitExceeded) {
+		t.Errorf("ApplyAny() error = %v, want ErrClaimsLimitExceeded", err)
+	}
+}
+
+func TestClaimsLimit_ApplyJSON(t *testing.T) {
+	t.Parallel()
+
+	claims := map[string]interface{}{
+		"email":  "bob@example.com",
+		"groups": []string{"admins", "developers", "everyone"},
+	}
+	got, err := ClaimsLimit{MaxBytes: 30, Priority: []string{"email"}}.ApplyJSON(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"email": "bob@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyJSON() = %v, want %v", got, want)
+	}
+}
@@ -2,6 +2,7 @@
 package cookie
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,7 +10,9 @@ import (
 	"time"
 
 	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 )
 
 var _ sessions.SessionStore = &Store{}
@@ -31,6 +34,9 @@ const (
 	// MaxNumChunks limits the number of chunks to iterate through. Conservatively
 	// set to prevent any abuse.
 	MaxNumChunks = 5
+	// MaxSize is the size of the largest session value which can be loaded
+	// again once it's saved: the first cookie and all of its chunks.
+	MaxSize = MaxChunkSize * (MaxNumChunks + 1)
 )
 
 // Options holds options for Store
@@ -40,6 +46,14 @@ type Options struct {
 	Expire   time.Duration
 	HTTPOnly bool
 	Secure   bool
+
+	// SizeWarningThreshold is the fraction of MaxSize above which saving a
+	// session logs a warning and records a metric. Zero disables the warning.
+	SizeWarningThreshold float64
+
+	// ReadOnly makes saving and clearing sessions no-ops, so that the store
+	// never sets cookies. Sessions are still loaded.
+	ReadOnly bool
 }
 
 // A GetOptionsFunc is a getter for cookie options.
@@ -93,12 +107,27 @@ func (cs *Store) makeCookie(value string) *http.Cookie {
 	}
 }
 
-// ClearSession clears the session cookie from a request
+// ClearSession clears the session cookie, and any chunks of it, from a request
 func (cs *Store) ClearSession(w http.ResponseWriter, r *http.Request) {
+	if cs.getOptions().ReadOnly {
+		log.Debug().Msg("internal/sessions: cookie store is read-only, not clearing session")
+		return
+	}
+
 	c := cs.makeCookie("")
 	c.MaxAge = -1
 	c.Expires = timeNow().Add(-time.Hour)
 	http.SetCookie(w, c)
+
+	for i := 1; i <= MaxNumChunks; i++ {
+		name := fmt.Sprintf("%s_%d", c.Name, i)
+		if _, err := r.Cookie(name); err != nil {
+			break
+		}
+		nc := *c
+		nc.Name = name
+		http.SetCookie(w, &nc)
+	}
 }
 
 func getCookies(r *http.Request, name string) []*http.Cookie {
@@ -112,6 +141,11 @@ func getCookies(r *http.Request, name string) []*http.Cookie {
 	return matchedCookies
 }
 
+// Health reports the store as healthy, as it has no backend.
+func (cs *Store) Health(context.Context) sessions.LoaderHealth {
+	return sessions.LoaderHealth{Name: "cookie"}
+}
+
 // LoadSession returns a State from the cookie in the request.
 func (cs *Store) LoadSession(r *http.Request) (string, error) {
 	opts := cs.getOptions()
@@ -133,6 +167,11 @@ func (cs *Store) LoadSession(r *http.Request) (string, error) {
 
 // SaveSession saves a session state to a request's cookie store.
 func (cs *Store) SaveSession(w http.ResponseWriter, _ *http.Request, x interface{}) error {
+	if cs.getOptions().ReadOnly {
+		log.Debug().Msg("internal/sessions: cookie store is read-only, not saving session")
+		return nil
+	}
+
 	var value string
 	switch v := x.(type) {
 	case []byte:
@@ -155,9 +194,25 @@ func (cs *Store) SaveSession(w http.ResponseWriter, _ *http.Request, x interface
 }
 
 func (cs *Store) setSessionCookie(w http.ResponseWriter, val string) {
+	cs.warnSessionSize(len(val))
 	cs.setCookie(w, cs.makeCookie(val))
 }
 
+// warnSessionSize warns when a session approaches the largest size the cookie
+// store can hold, so a server-side store can be set up before sessions start
+// to break.
+func (cs *Store) warnSessionSize(size int) {
+	threshold := cs.getOptions().SizeWarningThreshold
+	if threshold <= 0 || float64(size) <= threshold*MaxSize {
+		return
+	}
+	metrics.RecordSessionSizeWarning(context.Background())
+	log.Warn().
+		Int("size", size).
+		Int("max_size", MaxSize).
+		Msg("internal/sessions: encoded session is close to the cookie size limit")
+}
+
 func (cs *Store) setCookie(w http.ResponseWriter, cookie *http.Cookie) {
 	if len(cookie.String()) <= MaxChunkSize {
 		http.SetCookie(w, cookie)
@@ -11,12 +11,15 @@ import (
 
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/encoding/ecjson"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/encoding/mock"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.opencensus.io/stats/view"
 )
 
 func TestNewStore(t *testing.T) {
@@ -170,6 +173,95 @@ func TestStore_SaveSession(t *testing.T) {
 			if !strings.Contains(x, "_pomerium=; Path=/;") {
 				t.Errorf(x)
 			}
+			cleared := map[string]bool{}
+			for _, cookie := range w.Result().Cookies() {
+				cleared[cookie.Name] = cookie.MaxAge < 0
+			}
+			for _, cookie := range r.Cookies() {
+				if !cleared[cookie.Name] {
+					t.Errorf("ClearSession() did not clear cookie %s", cookie.Name)
+				}
+			}
 		})
 	}
 }
+
+func TestStore_SaveSessionSizeWarning(t *testing.T) {
+	view.Unregister(metrics.SessionViews...)
+	if err := view.Register(metrics.SessionViews...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(metrics.SessionViews...)
+
+	warnings := func() int64 {
+		rows, err := view.RetrieveData(metrics.SessionSizeWarningsView.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) == 0 {
+			return 0
+		}
+		return rows[0].Data.(*view.CountData).Value
+	}
+
+	s := &Store{
+		getOptions: func() Options {
+			return Options{Name: "_pomerium", SizeWarningThreshold: 0.5}
+		},
+	}
+
+	s.SaveSession(httptest.NewRecorder(), nil, strings.Repeat("x", MaxSize/4))
+	if got := warnings(); got != 0 {
+		t.Errorf("small session recorded %d warnings, want 0", got)
+	}
+
+	s.SaveSession(httptest.NewRecorder(), nil, strings.Repeat("x", MaxSize*3/4))
+	if got := warnings(); got != 1 {
+		t.Errorf("large session recorded %d warnings, want 1", got)
+	}
+}
+
+func TestStore_ReadOnly(t *testing.T) {
+	encoder, err := jws.NewHS256Signer(cryptutil.NewKey(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newStore := func(readOnly bool) sessions.SessionStore {
+		s, err := NewStore(func() Options {
+			return Options{Name: "_pomerium", Expire: 10 * time.Second, ReadOnly: readOnly}
+		}, encoder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	state := &sessions.State{ID: "SESSION_ID", Subject: "user@example.com"}
+
+	w := httptest.NewRecorder()
+	if err := newStore(false).SaveSession(w, nil, state); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	s := newStore(true)
+	if _, err := s.LoadSession(r); err != nil {
+		t.Errorf("LoadSession() error = %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	if err := s.SaveSession(w, r, state); err != nil {
+		t.Errorf("SaveSession() error = %v", err)
+	}
+	if got := w.Header().Get("Set-Cookie"); got != "" {
+		t.Errorf("SaveSession() set cookie %q, want none", got)
+	}
+
+	w = httptest.NewRecorder()
+	s.ClearSession(w, r)
+	if got := w.Header().Get("Set-Cookie"); got != "" {
+		t.Errorf("ClearSession() set cookie %q, want none", got)
+	}
+}
@@ -25,4 +25,7 @@ var (
 
 	// ErrInvalidAudience indicated invalid aud claim.
 	ErrInvalidAudience = errors.New("internal/sessions: validation failed, invalid audience claim (aud)")
+
+	// ErrClaimsLimitExceeded indicates that a session carries more claims than allowed.
+	ErrClaimsLimitExceeded = errors.New("internal/sessions: validation failed, session claims exceed the limit")
 )
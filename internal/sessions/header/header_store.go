@@ -3,6 +3,7 @@
 package header
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -41,6 +42,11 @@ func NewStore(enc encoding.Unmarshaler, headerType string) *Store {
 	}
 }
 
+// Health reports the store as healthy, as it has no backend.
+func (as *Store) Health(context.Context) sessions.LoaderHealth {
+	return sessions.LoaderHealth{Name: "header"}
+}
+
 // LoadSession tries to retrieve the token string from the Authorization header.
 func (as *Store) LoadSession(r *http.Request) (string, error) {
 	jwt := TokenFromHeader(r, as.authHeader, as.authType)
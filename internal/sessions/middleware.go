@@ -20,11 +20,23 @@ func RetrieveSession(s ...SessionLoader) func(http.Handler) http.Handler {
 	}
 }
 
+// RetrieveSessionConcurrently is like RetrieveSession, but calls the session
+// loaders concurrently. See LoadConcurrently.
+func RetrieveSessionConcurrently(s ...SessionLoader) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return retrieveWith(LoadConcurrently, s...)(next)
+	}
+}
+
 func retrieve(s ...SessionLoader) func(http.Handler) http.Handler {
+	return retrieveWith(retrieveFromRequest, s...)
+}
+
+func retrieveWith(load func(*http.Request, ...SessionLoader) (string, error), s ...SessionLoader) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hfn := func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
-			jwt, err := retrieveFromRequest(r, s...)
+			jwt, err := load(r, s...)
 			ctx = NewContext(ctx, jwt, err)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
@@ -47,6 +59,54 @@ func retrieveFromRequest(r *http.Request, sessions ...SessionLoader) (string, er
 	return "", ErrNoSessionFound
 }
 
+// LoadConcurrently extracts session state from the request by calling the
+// session loaders concurrently, so a slow loader doesn't hold up the others.
+//
+// The result is the same as calling the loaders in the order they were
+// provided: when several loaders find a session, or fail, the earliest one
+// wins. As soon as the result is known the remaining loaders are canceled
+// through the request's context.
+func LoadConcurrently(r *http.Request, sessions ...SessionLoader) (string, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	type result struct {
+		jwt  string
+		err  error
+		done bool
+	}
+	type loaded struct {
+		idx int
+		result
+	}
+	// buffered so loaders that finish after we return don't block forever
+	ch := make(chan loaded, len(sessions))
+	for i, s := range sessions {
+		go func(i int, s SessionLoader) {
+			jwt, err := s.LoadSession(r)
+			ch <- loaded{idx: i, result: result{jwt: jwt, err: err, done: true}}
+		}(i, s)
+	}
+
+	results := make([]result, len(sessions))
+	next := 0
+	for range sessions {
+		res := <-ch
+		results[res.idx] = res.result
+		for ; next < len(results) && results[next].done; next++ {
+			jwt, err := results[next].jwt, results[next].err
+			if err != nil && !errors.Is(err, ErrNoSessionFound) {
+				return "", err
+			} else if err == nil {
+				return jwt, nil
+			}
+		}
+	}
+
+	return "", ErrNoSessionFound
+}
+
 // NewContext sets context values for the user session state and error.
 func NewContext(ctx context.Context, jwt string, err error) context.Context {
 	ctx = context.WithValue(ctx, SessionCtxKey, jwt)
@@ -113,3 +113,79 @@ func TestVerifier(t *testing.T) {
 		})
 	}
 }
+
+type loaderFunc func(*http.Request) (string, error)
+
+func (fn loaderFunc) LoadSession(r *http.Request) (string, error) { return fn(r) }
+
+func (fn loaderFunc) Health(context.Context) sessions.LoaderHealth {
+	return sessions.LoaderHealth{Name: "func"}
+}
+
+func TestLoadConcurrently(t *testing.T) {
+	found := func(jwt string) sessions.SessionLoader {
+		return loaderFunc(func(*http.Request) (string, error) { return jwt, nil })
+	}
+	notFound := loaderFunc(func(*http.Request) (string, error) { return "", sessions.ErrNoSessionFound })
+	failed := loaderFunc(func(*http.Request) (string, error) { return "", errors.New("err") })
+	delayed := func(jwt string) sessions.SessionLoader {
+		return loaderFunc(func(*http.Request) (string, error) {
+			time.Sleep(50 * time.Millisecond)
+			return jwt, nil
+		})
+	}
+
+	t.Run("slow loader canceled", func(t *testing.T) {
+		canceled := make(chan struct{})
+		slow := loaderFunc(func(r *http.Request) (string, error) {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+				return "", r.Context().Err()
+			case <-time.After(10 * time.Second):
+				return "slow", nil
+			}
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		jwt, err := sessions.LoadConcurrently(r, notFound, found("fast"), slow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jwt != "fast" {
+			t.Errorf("LoadConcurrently() = %q, want %q", jwt, "fast")
+		}
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Error("slow loader was not canceled")
+		}
+	})
+
+	tests := []struct {
+		name    string
+		loaders []sessions.SessionLoader
+		wantJWT string
+		wantErr bool
+	}{
+		{"first found wins", []sessions.SessionLoader{found("a"), found("b")}, "a", false},
+		{"earlier loader wins even if slower", []sessions.SessionLoader{delayed("a"), found("b")}, "a", false},
+		{"skips loaders without a session", []sessions.SessionLoader{notFound, delayed("b")}, "b", false},
+		{"earlier error wins", []sessions.SessionLoader{failed, found("b")}, "", true},
+		{"later error ignored", []sessions.SessionLoader{found("a"), failed}, "a", false},
+		{"no session", []sessions.SessionLoader{notFound, notFound}, "", true},
+		{"no loaders", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			jwt, err := sessions.LoadConcurrently(r, tt.loaders...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConcurrently() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if jwt != tt.wantJWT {
+				t.Errorf("LoadConcurrently() = %q, want %q", jwt, tt.wantJWT)
+			}
+		})
+	}
+}
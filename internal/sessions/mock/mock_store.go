@@ -2,6 +2,7 @@
 package mock
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/pomerium/pomerium/internal/encoding"
@@ -20,6 +21,7 @@ type Store struct {
 	LoadError       error
 	Secret          []byte
 	Encrypted       bool
+	HealthError     error
 }
 
 // ClearSession clears the ResponseSession
@@ -27,6 +29,11 @@ func (ms *Store) ClearSession(http.ResponseWriter, *http.Request) {
 	ms.ResponseSession = ""
 }
 
+// Health returns the health error.
+func (ms Store) Health(context.Context) sessions.LoaderHealth {
+	return sessions.LoaderHealth{Name: "mock", Err: ms.HealthError}
+}
+
 // LoadSession returns the session and a error
 func (ms Store) LoadSession(*http.Request) (string, error) {
 	var signer encoding.MarshalUnmarshaler
@@ -3,7 +3,10 @@
 package queryparam
 
 import (
+	"context"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/sessions"
@@ -19,9 +22,10 @@ const (
 // Store implements the load session store interface using http
 // query strings / query parameters.
 type Store struct {
-	queryParamKey string
-	encoder       encoding.Marshaler
-	decoder       encoding.Unmarshaler
+	queryParamKey   string
+	encoder         encoding.Marshaler
+	decoder         encoding.Unmarshaler
+	safeMethodsOnly bool
 }
 
 // NewStore returns a new query param store for loading sessions from
@@ -40,12 +44,36 @@ func NewStore(enc encoding.MarshalUnmarshaler, qp string) *Store {
 	}
 }
 
+// SafeMethodsOnly limits loading sessions to GET and HEAD requests. Tokens in
+// the query string of other requests are ignored, as they are more likely to
+// leak, for example through cross-site form posts.
+func (qp *Store) SafeMethodsOnly(enabled bool) *Store {
+	qp.safeMethodsOnly = enabled
+	return qp
+}
+
+// Health reports the store as healthy, as it has no backend.
+func (qp *Store) Health(context.Context) sessions.LoaderHealth {
+	return sessions.LoaderHealth{Name: "query param"}
+}
+
 // LoadSession tries to retrieve the token string from URL query parameters.
 func (qp *Store) LoadSession(r *http.Request) (string, error) {
+	if qp.safeMethodsOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", sessions.ErrNoSessionFound
+	}
 	jwt := r.URL.Query().Get(qp.queryParamKey)
 	if jwt == "" {
 		return "", sessions.ErrNoSessionFound
 	}
+	// Encoded sessions never contain a '%', so one here means an intermediary
+	// encoded the token a second time. Undo that once; if the value does not
+	// unescape cleanly it is passed through as is.
+	if strings.Contains(jwt, "%") {
+		if unescaped, err := url.PathUnescape(jwt); err == nil {
+			jwt = unescaped
+		}
+	}
 
 	return jwt, nil
 }
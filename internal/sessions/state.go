@@ -70,6 +70,10 @@ type State struct {
 	// Programmatic whether this state is used for machine-to-machine
 	// programatic access.
 	Programmatic bool `json:"programatic"`
+
+	// CustomClaims are operator defined claims derived from the identity
+	// provider's claims when the session was created.
+	CustomClaims map[string]string `json:"custom_claims,omitempty"`
 }
 
 // NewSession updates issuer, audience, and issuance timestamps but keeps
@@ -3,6 +3,7 @@
 package sessions
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -16,4 +17,15 @@ type SessionStore interface {
 // SessionLoader defines an interface for loading a session.
 type SessionLoader interface {
 	LoadSession(*http.Request) (string, error)
+	// Health reports whether the loader's backend is currently able to load
+	// sessions.
+	Health(context.Context) LoaderHealth
+}
+
+// LoaderHealth is the health of a session loader's backend.
+type LoaderHealth struct {
+	// Name identifies the loader, e.g. "cookie".
+	Name string
+	// Err is set when the loader's backend is unhealthy.
+	Err error
 }
@@ -0,0 +1,59 @@
+// Package subprotocol provides a websocket subprotocol based implementation
+// of a session loader.
+//
+// Browser websocket APIs can't set custom headers on the handshake, but they
+// can offer subprotocols, which are sent in the Sec-WebSocket-Protocol header.
+package subprotocol
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/sessions"
+)
+
+var _ sessions.SessionLoader = &Store{}
+
+const protocolHeader = "Sec-WebSocket-Protocol"
+
+// Store implements the load session store interface using the protocols
+// offered in a websocket handshake.
+type Store struct {
+	prefix string
+}
+
+// NewStore returns a new subprotocol store for loading sessions from the
+// protocol offered in a websocket handshake which starts with prefix.
+func NewStore(prefix string) *Store {
+	return &Store{prefix: prefix}
+}
+
+// Health reports the store as healthy, as it has no backend.
+func (ps *Store) Health(context.Context) sessions.LoaderHealth {
+	return sessions.LoaderHealth{Name: "websocket subprotocol"}
+}
+
+// LoadSession tries to retrieve the token string from the
+// Sec-WebSocket-Protocol header.
+func (ps *Store) LoadSession(r *http.Request) (string, error) {
+	jwt := TokenFromProtocols(r, ps.prefix)
+	if jwt == "" {
+		return "", sessions.ErrNoSessionFound
+	}
+	return jwt, nil
+}
+
+// TokenFromProtocols retrieves the token from the first protocol offered in
+// the request's Sec-WebSocket-Protocol headers which starts with prefix.
+func TokenFromProtocols(r *http.Request, prefix string) string {
+	for _, hdr := range r.Header.Values(protocolHeader) {
+		for _, protocol := range strings.Split(hdr, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, prefix) {
+				return protocol[len(prefix):]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package subprotocol
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/internal/sessions"
+)
+
+func TestStore_LoadSession(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocols []string
+		want      string
+		wantErr   error
+	}{
+		{"no header", nil, "", sessions.ErrNoSessionFound},
+		{"no token", []string{"graphql-ws"}, "", sessions.ErrNoSessionFound},
+		{"only token", []string{"pomerium.session.JWT"}, "JWT", nil},
+		{"token with protocol", []string{"graphql-ws, pomerium.session.JWT"}, "JWT", nil},
+		{"token in second header", []string{"graphql-ws", "pomerium.session.JWT"}, "JWT", nil},
+		{"empty token", []string{"pomerium.session."}, "", sessions.ErrNoSessionFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/ws", nil)
+			for _, p := range tt.protocols {
+				r.Header.Add("Sec-WebSocket-Protocol", p)
+			}
+			got, err := NewStore("pomerium.session.").LoadSession(r)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
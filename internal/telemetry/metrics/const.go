@@ -44,5 +44,6 @@ var (
 		HTTPServerViews,
 		InfoViews,
 		StorageViews,
+		SessionViews,
 	}
 )
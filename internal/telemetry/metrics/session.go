@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+var (
+	// SessionViews contains opencensus views for session metrics.
+	SessionViews = []*view.View{SessionSizeWarningsView}
+
+	sessionSizeWarnings = stats.Int64(
+		"session_size_warnings",
+		"Number of sessions saved whose encoded size is close to the cookie size limit",
+		"1")
+
+	// SessionSizeWarningsView is an OpenCensus view that counts the sessions
+	// saved whose encoded size exceeds the warning threshold.
+	SessionSizeWarningsView = &view.View{
+		Name:        "session_size_warnings_total",
+		Description: sessionSizeWarnings.Description(),
+		Measure:     sessionSizeWarnings,
+		Aggregation: view.Count(),
+	}
+)
+
+// RecordSessionSizeWarning records that a session close to the cookie size
+// limit was saved.
+func RecordSessionSizeWarning(ctx context.Context) {
+	stats.Record(ctx, sessionSizeWarnings.M(1))
+}
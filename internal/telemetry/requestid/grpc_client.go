@@ -42,5 +42,5 @@ func toMetadata(ctx context.Context) {
 		return
 	}
 
-	md.Set(headerName, requestID)
+	md.Set(HeaderName, requestID)
 }
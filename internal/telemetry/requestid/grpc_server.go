@@ -47,7 +47,7 @@ func fromMetadata(ctx context.Context) string {
 		return New()
 	}
 
-	headers := md.Get(headerName)
+	headers := md.Get(HeaderName)
 	if len(headers) == 0 || headers[0] == "" {
 		return New()
 	}
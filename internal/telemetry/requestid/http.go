@@ -13,8 +13,8 @@ func NewRoundTripper(base http.RoundTripper) http.RoundTripper {
 
 func (t *transport) RoundTrip(req *http.Request) (res *http.Response, err error) {
 	requestID := FromContext(req.Context())
-	if requestID != "" && req.Header.Get(headerName) == "" {
-		req.Header.Set(headerName, requestID)
+	if requestID != "" && req.Header.Get(HeaderName) == "" {
+		req.Header.Set(HeaderName, requestID)
 	}
 
 	return t.base.RoundTrip(req)
@@ -44,5 +44,5 @@ func (h httpMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // FromHTTPHeader returns the request id in the HTTP header. If no request id exists,
 // an empty string is returned.
 func FromHTTPHeader(hdr http.Header) string {
-	return hdr.Get(headerName)
+	return hdr.Get(HeaderName)
 }
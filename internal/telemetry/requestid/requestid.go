@@ -7,7 +7,8 @@ import (
 	shortuuid "github.com/lithammer/shortuuid/v3"
 )
 
-const headerName = "x-request-id"
+// HeaderName is the header used to pass the id of a request.
+const HeaderName = "x-request-id"
 
 var contextKey struct{}
 
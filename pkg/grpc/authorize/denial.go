@@ -0,0 +1,78 @@
+package authorize
+
+import (
+	"strings"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// denialDomain is the domain of the error info describing a denial.
+const denialDomain = "authorize.pomerium.io"
+
+// maxDenialDetailLength bounds the length of each sanitized denial detail.
+const maxDenialDetailLength = 256
+
+// DenialDetails describe why the authorize service denied a request.
+type DenialDetails struct {
+	// Reason is the reason the request was denied.
+	Reason string
+	// Route is the source of the route whose policy denied the request.
+	Route string
+}
+
+// AddDenialDetails adds the details of a denial to the response's status
+// details, which envoy ignores.
+func AddDenialDetails(res *envoy_service_auth_v2.CheckResponse, details DenialDetails) {
+	if res.Status == nil {
+		return
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   details.Reason,
+		Domain:   denialDomain,
+		Metadata: map[string]string{},
+	}
+	if details.Route != "" {
+		info.Metadata["route"] = details.Route
+	}
+	detail, _ := ptypes.MarshalAny(info)
+	res.Status.Details = append(res.Status.Details, detail)
+}
+
+// GetDenialDetails returns the details of the denial in res, if any.
+func GetDenialDetails(res *envoy_service_auth_v2.CheckResponse) (DenialDetails, bool) {
+	for _, detail := range res.GetStatus().GetDetails() {
+		var info errdetails.ErrorInfo
+		if ptypes.Is(detail, &info) && ptypes.UnmarshalAny(detail, &info) == nil && info.GetDomain() == denialDomain {
+			return DenialDetails{
+				Reason: info.GetReason(),
+				Route:  info.GetMetadata()["route"],
+			}, true
+		}
+	}
+	return DenialDetails{}, false
+}
+
+// Sanitize returns the details with everything but printable ASCII characters
+// dropped, and each detail truncated, so that they're safe to show to users
+// and to use as header values.
+func (details DenialDetails) Sanitize() DenialDetails {
+	return DenialDetails{
+		Reason: sanitizeDenialDetail(details.Reason),
+		Route:  sanitizeDenialDetail(details.Route),
+	}
+}
+
+func sanitizeDenialDetail(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > maxDenialDetailLength {
+		s = s[:maxDenialDetailLength]
+	}
+	return strings.TrimSpace(s)
+}
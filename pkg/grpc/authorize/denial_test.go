@@ -0,0 +1,40 @@
+package authorize
+
+import (
+	"strings"
+	"testing"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+func TestDenialDetails(t *testing.T) {
+	const key = "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w="
+
+	res := &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied)},
+	}
+	_, ok := GetDenialDetails(res)
+	assert.False(t, ok)
+
+	details := DenialDetails{Reason: "forbidden", Route: "https://from.example.com"}
+	AddDenialDetails(res, details)
+	req := &envoy_service_auth_v2.AttributeContext_Request{}
+	SignCheckResponse(key, req, res)
+
+	got, ok := GetDenialDetails(res)
+	assert.True(t, ok)
+	assert.Equal(t, details, got)
+	assert.NoError(t, VerifyCheckResponse(key, req, res))
+}
+
+func TestDenialDetails_Sanitize(t *testing.T) {
+	details := DenialDetails{
+		Reason: " not in\r\ngroupé ",
+		Route:  "https://" + strings.Repeat("a", 300),
+	}.Sanitize()
+	assert.Equal(t, "not ingroup", details.Reason)
+	assert.Len(t, details.Route, maxDenialDetailLength)
+}
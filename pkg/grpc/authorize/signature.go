@@ -0,0 +1,100 @@
+// Package authorize contains helpers for the envoy external authorization
+// gRPC service implemented by the authorize service.
+package authorize
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+var (
+	// ErrUnsigned is returned when a check response has no signature.
+	ErrUnsigned = errors.New("authorize: check response is not signed")
+	// ErrInvalidSignature is returned when a check response's signature does
+	// not match its contents.
+	ErrInvalidSignature = errors.New("authorize: check response signature is invalid")
+)
+
+// SignCheckResponse signs the decision in res, made for the request req,
+// with the shared key. The signature is added to the response's status
+// details, which envoy ignores.
+func SignCheckResponse(key string, req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {
+	if res.Status == nil {
+		return
+	}
+	sig, _ := ptypes.MarshalAny(&wrappers.BytesValue{
+		Value: cryptutil.GenerateHMAC(signaturePayload(req, res), key),
+	})
+	res.Status.Details = append(res.Status.Details, sig)
+}
+
+// VerifyCheckResponse verifies that res, the response to the request req, was
+// signed with the shared key.
+func VerifyCheckResponse(key string, req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) error {
+	var sig []byte
+	for _, detail := range res.GetStatus().GetDetails() {
+		var v wrappers.BytesValue
+		if ptypes.Is(detail, &v) && ptypes.UnmarshalAny(detail, &v) == nil {
+			sig = v.GetValue()
+		}
+	}
+	if sig == nil {
+		return ErrUnsigned
+	}
+	if !cryptutil.CheckHMAC(signaturePayload(req, res), sig, key) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signaturePayload serializes the parts of a request and response covered by
+// the signature. Every field is length prefixed so that distinct responses
+// can't produce the same payload.
+func signaturePayload(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) []byte {
+	var buf bytes.Buffer
+	write := func(s string) {
+		buf.WriteString(strconv.Itoa(len(s)))
+		buf.WriteByte(':')
+		buf.WriteString(s)
+	}
+
+	write(strconv.FormatInt(req.GetTime().GetSeconds(), 10))
+	write(strconv.FormatInt(int64(req.GetTime().GetNanos()), 10))
+	write(req.GetHttp().GetMethod())
+	write(req.GetHttp().GetHost())
+	write(req.GetHttp().GetPath())
+
+	write(strconv.FormatInt(int64(res.GetStatus().GetCode()), 10))
+	write(res.GetStatus().GetMessage())
+	var headers []*envoy_api_v2_core.HeaderValueOption
+	switch r := res.GetHttpResponse().(type) {
+	case *envoy_service_auth_v2.CheckResponse_OkResponse:
+		write("ok")
+		headers = r.OkResponse.GetHeaders()
+	case *envoy_service_auth_v2.CheckResponse_DeniedResponse:
+		write("denied")
+		write(strconv.FormatInt(int64(r.DeniedResponse.GetStatus().GetCode()), 10))
+		write(r.DeniedResponse.GetBody())
+		headers = r.DeniedResponse.GetHeaders()
+	}
+
+	hdrs := make([]string, 0, len(headers))
+	for _, hdr := range headers {
+		hdrs = append(hdrs, strconv.FormatBool(hdr.GetAppend().GetValue())+" "+
+			strconv.Itoa(len(hdr.GetHeader().GetKey()))+":"+hdr.GetHeader().GetKey()+hdr.GetHeader().GetValue())
+	}
+	sort.Strings(hdrs)
+	for _, hdr := range hdrs {
+		write(hdr)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,72 @@
+package authorize
+
+import (
+	"testing"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSignCheckResponse(t *testing.T) {
+	const key = "2p/Wi2Q6bYDfzmoSEbKqYKtg+DUoLWTEHHs7vOhvL7w="
+
+	newRequest := func() *envoy_service_auth_v2.AttributeContext_Request {
+		return &envoy_service_auth_v2.AttributeContext_Request{
+			Time: &timestamp.Timestamp{Seconds: 1600000000, Nanos: 42},
+			Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+				Method: "GET",
+				Host:   "example.com",
+				Path:   "/some/path",
+			},
+		}
+	}
+	newResponse := func() *envoy_service_auth_v2.CheckResponse {
+		return &envoy_service_auth_v2.CheckResponse{
+			Status: &status.Status{Code: int32(codes.OK)},
+			HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{
+				OkResponse: &envoy_service_auth_v2.OkHttpResponse{
+					Headers: []*envoy_api_v2_core.HeaderValueOption{{
+						Header: &envoy_api_v2_core.HeaderValue{Key: "x-pomerium-claim-email", Value: "user@example.com"},
+					}},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse)
+		key     string
+		wantErr error
+	}{
+		{"valid", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {}, key, nil},
+		{"wrong key", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {}, "zdjp1u0dBvH7GKQlNmdkFSK9Ms6k2oiSi6ZWswvc9Qo=", ErrInvalidSignature},
+		{"different request", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {
+			req.Http.Path = "/other/path"
+		}, key, ErrInvalidSignature},
+		{"replayed request", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {
+			req.Time.Nanos++
+		}, key, ErrInvalidSignature},
+		{"modified header", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {
+			res.GetOkResponse().Headers[0].Header.Value = "admin@example.com"
+		}, key, ErrInvalidSignature},
+		{"modified decision", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {
+			res.Status.Code = int32(codes.PermissionDenied)
+		}, key, ErrInvalidSignature},
+		{"unsigned", func(req *envoy_service_auth_v2.AttributeContext_Request, res *envoy_service_auth_v2.CheckResponse) {
+			res.Status.Details = nil
+		}, key, ErrUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, res := newRequest(), newResponse()
+			SignCheckResponse(key, req, res)
+			tt.modify(req, res)
+			assert.Equal(t, tt.wantErr, VerifyCheckResponse(tt.key, req, res))
+		})
+	}
+}
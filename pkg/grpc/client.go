@@ -45,6 +45,9 @@ type Options struct {
 	RequestTimeout time.Duration
 	// ClientDNSRoundRobin enables or disables DNS resolver based load balancing
 	ClientDNSRoundRobin bool
+	// ClientDNSStaleTTL specifies how long previously resolved addresses continue
+	// to be used while DNS resolution is failing. Only used with ClientDNSRoundRobin.
+	ClientDNSStaleTTL time.Duration
 
 	// WithInsecure disables transport security for this ClientConn.
 	// Note that transport security is required unless WithInsecure is set.
@@ -126,7 +129,11 @@ func NewGRPCClientConn(opts *Options) (*grpc.ClientConn, error) {
 	}
 
 	if opts.ClientDNSRoundRobin {
-		dialOptions = append(dialOptions, grpc.WithBalancerName(roundrobin.Name), grpc.WithDisableServiceConfig())
+		dialOptions = append(dialOptions,
+			grpc.WithBalancerName(roundrobin.Name),
+			grpc.WithDisableServiceConfig(),
+			grpc.WithResolvers(newDNSResolverBuilder(opts.ClientDNSStaleTTL)),
+		)
 		connAddr = fmt.Sprintf("dns:///%s", connAddr)
 	}
 	return grpc.Dial(
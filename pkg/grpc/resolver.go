@@ -0,0 +1,188 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const (
+	// dnsResolveInterval is how often a successfully resolved name is
+	// re-resolved to pick up added or removed endpoints.
+	dnsResolveInterval = 30 * time.Second
+	// dnsMinResolveInterval rate limits re-resolution requested by gRPC,
+	// which happens whenever a connection to an endpoint is lost.
+	dnsMinResolveInterval = 1 * time.Second
+	// dnsLookupTimeout bounds a single DNS lookup.
+	dnsLookupTimeout = 10 * time.Second
+)
+
+// dnsResolverBuilder builds resolvers for "dns:///host:port" targets. Unlike
+// the default gRPC DNS resolver, failed lookups are retried with an
+// exponential backoff and, for up to staleTTL after the last successful
+// lookup, the previously resolved addresses continue to be used so that a
+// brief DNS outage does not take down established connections.
+type dnsResolverBuilder struct {
+	lookupHost      func(ctx context.Context, host string) ([]string, error)
+	staleTTL        time.Duration
+	resolveInterval time.Duration
+}
+
+func newDNSResolverBuilder(staleTTL time.Duration) *dnsResolverBuilder {
+	return &dnsResolverBuilder{
+		lookupHost:      net.DefaultResolver.LookupHost,
+		staleTTL:        staleTTL,
+		resolveInterval: dnsResolveInterval,
+	}
+}
+
+// Scheme returns the scheme handled by this builder.
+func (b *dnsResolverBuilder) Scheme() string {
+	return "dns"
+}
+
+// Build starts resolving the target, reporting addresses to cc.
+func (b *dnsResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("internal/grpc: invalid dns target %q: %w", target.Endpoint, err)
+	}
+
+	// IP addresses don't need resolving
+	if net.ParseIP(host) != nil {
+		cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: target.Endpoint}}})
+		return nopResolver{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsResolver{
+		builder:    b,
+		host:       host,
+		port:       port,
+		cc:         cc,
+		ctx:        ctx,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+type dnsResolver struct {
+	builder    *dnsResolverBuilder
+	host, port string
+	cc         resolver.ClientConn
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	resolveNow chan struct{}
+	wg         sync.WaitGroup
+}
+
+// ResolveNow requests an immediate re-resolution.
+func (r *dnsResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the resolver.
+func (r *dnsResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *dnsResolver) watch() {
+	defer r.wg.Done()
+
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxInterval = r.builder.resolveInterval
+	bo.MaxElapsedTime = 0
+
+	var lastResolved time.Time
+	for {
+		addrs, err := r.lookup()
+		if err == nil {
+			lastResolved = time.Now()
+			bo.Reset()
+			r.cc.UpdateState(resolver.State{Addresses: addrs})
+
+			// wait for the next scheduled or requested re-resolution
+			t := time.NewTimer(r.builder.resolveInterval)
+			select {
+			case <-r.ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+				continue
+			case <-r.resolveNow:
+				t.Stop()
+			}
+			if !r.sleep(dnsMinResolveInterval - time.Since(lastResolved)) {
+				return
+			}
+			continue
+		}
+
+		// requests to re-resolve are ignored while backing off
+		retry := bo.NextBackOff()
+		if !lastResolved.IsZero() && time.Since(lastResolved) < r.builder.staleTTL {
+			log.Warn().Err(err).Str("host", r.host).Dur("retry", retry).
+				Msg("internal/grpc: dns resolution failed, using previously resolved addresses")
+		} else {
+			log.Error().Err(err).Str("host", r.host).Dur("retry", retry).
+				Msg("internal/grpc: dns resolution failed")
+			r.cc.ReportError(err)
+		}
+		if !r.sleep(retry) {
+			return
+		}
+	}
+}
+
+func (r *dnsResolver) lookup() ([]resolver.Address, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, dnsLookupTimeout)
+	defer cancel()
+
+	ips, err := r.builder.lookupHost(ctx, r.host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("internal/grpc: no addresses found for %s", r.host)
+	}
+	addrs := make([]resolver.Address, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, resolver.Address{Addr: net.JoinHostPort(ip, r.port)})
+	}
+	return addrs, nil
+}
+
+// sleep waits for d, returning false if the resolver was closed in the meantime.
+func (r *dnsResolver) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return r.ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+type nopResolver struct{}
+
+func (nopResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (nopResolver) Close()                                {}
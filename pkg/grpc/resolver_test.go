@@ -0,0 +1,140 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+)
+
+var errDNSUnavailable = errors.New("dns unavailable")
+
+func TestDNSResolver_recovers(t *testing.T) {
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	go srv.Serve(li)
+	defer srv.Stop()
+
+	// the first lookups fail, then DNS recovers
+	var lookups int32
+	b := newDNSResolverBuilder(0)
+	b.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if atomic.AddInt32(&lookups, 1) <= 2 {
+			return nil, errDNSUnavailable
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+
+	_, port, _ := net.SplitHostPort(li.Addr().String())
+	cc, err := grpc.Dial("dns:///authorize.example:"+port,
+		grpc.WithInsecure(),
+		grpc.WithBalancerName(roundrobin.Name),
+		grpc.WithResolvers(b),
+	)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for state := cc.GetState(); state != connectivity.Ready; state = cc.GetState() {
+		if !cc.WaitForStateChange(ctx, state) {
+			t.Fatalf("connection never became ready, last state: %s", state)
+		}
+	}
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&lookups), int32(3))
+}
+
+type testClientConn struct {
+	resolver.ClientConn
+
+	mu     sync.Mutex
+	states []resolver.State
+	errs   []error
+}
+
+func (cc *testClientConn) UpdateState(state resolver.State) {
+	cc.mu.Lock()
+	cc.states = append(cc.states, state)
+	cc.mu.Unlock()
+}
+
+func (cc *testClientConn) ReportError(err error) {
+	cc.mu.Lock()
+	cc.errs = append(cc.errs, err)
+	cc.mu.Unlock()
+}
+
+func (cc *testClientConn) counts() (states, errs int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.states), len(cc.errs)
+}
+
+func TestDNSResolver_staleTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		staleTTL time.Duration
+		wantErrs bool
+	}{
+		{"stale addresses used", time.Hour, false},
+		{"stale addresses expired", 0, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// the first lookup succeeds, every following one fails
+			var lookups int32
+			b := newDNSResolverBuilder(tt.staleTTL)
+			b.resolveInterval = 10 * time.Millisecond
+			b.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+				if atomic.AddInt32(&lookups, 1) == 1 {
+					return []string{"10.0.0.1"}, nil
+				}
+				return nil, errDNSUnavailable
+			}
+
+			cc := new(testClientConn)
+			r, err := b.Build(resolver.Target{Endpoint: "authorize.example:5443"}, cc, resolver.BuildOptions{})
+			require.NoError(t, err)
+			defer r.Close()
+
+			require.Eventually(t, func() bool {
+				return atomic.LoadInt32(&lookups) >= 3
+			}, 5*time.Second, 10*time.Millisecond)
+
+			states, errs := cc.counts()
+			assert.Equal(t, 1, states)
+			assert.Equal(t, tt.wantErrs, errs > 0)
+		})
+	}
+}
+
+func TestDNSResolver_ipAddress(t *testing.T) {
+	b := newDNSResolverBuilder(0)
+	b.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		t.Errorf("unexpected lookup of %s", host)
+		return nil, errDNSUnavailable
+	}
+
+	cc := new(testClientConn)
+	r, err := b.Build(resolver.Target{Endpoint: "127.0.0.1:5443"}, cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	if assert.Len(t, cc.states, 1) {
+		assert.Equal(t, []resolver.Address{{Addr: "127.0.0.1:5443"}}, cc.states[0].Addresses)
+	}
+}
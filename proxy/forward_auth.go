@@ -130,7 +130,7 @@ func (p *Proxy) Verify(verifyOnly bool) http.Handler {
 		_, err = sessions.FromContext(r.Context())
 		hasSession := err == nil
 		if hasSession && !unAuthenticated {
-			return httputil.NewError(http.StatusForbidden, errors.New("access denied"))
+			return httputil.NewError(http.StatusForbidden, state.denialError(w, ar))
 		}
 
 		if verifyOnly {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
@@ -23,6 +24,7 @@ import (
 	"github.com/pomerium/pomerium/internal/sessions"
 	mstore "github.com/pomerium/pomerium/internal/sessions/mock"
 	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/grpc/authorize"
 )
 
 type mockCheckClient struct {
@@ -139,3 +141,75 @@ func TestProxy_ForwardAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestProxy_ForwardAuthDenialDetails(t *testing.T) {
+	t.Parallel()
+
+	newDenyClient := func(reason string) *mockCheckClient {
+		res := &envoy_service_auth_v2.CheckResponse{
+			Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Access Denied"},
+			HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+				DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+					Status: &envoy_type.HttpStatus{Code: envoy_type.StatusCode_Forbidden},
+				},
+			},
+		}
+		authorize.AddDenialDetails(res, authorize.DenialDetails{
+			Reason: reason,
+			Route:  "https://some.domain.example",
+		})
+		return &mockCheckClient{response: res}
+	}
+
+	tests := []struct {
+		name       string
+		details    []config.DenialDetail
+		reason     string
+		wantBody   string
+		wantReason string
+		wantRoute  string
+	}{
+		{"disabled", nil, "user is not in group admins",
+			`{"Status":403,"Error":"Forbidden: access denied"}` + "\n", "", ""},
+		{"reason", []config.DenialDetail{config.DenialDetailReason}, "user is not in group admins",
+			`{"Status":403,"Error":"Forbidden: access denied: user is not in group admins"}` + "\n", "user is not in group admins", ""},
+		{"reason and route", []config.DenialDetail{config.DenialDetailReason, config.DenialDetailRoute}, "user is not in group admins",
+			`{"Status":403,"Error":"Forbidden: access denied: user is not in group admins (route https://some.domain.example)"}` + "\n", "user is not in group admins", "https://some.domain.example"},
+		{"unsafe reason", []config.DenialDetail{config.DenialDetailReason}, "denied\r\nSet-Cookie: x=y",
+			`{"Status":403,"Error":"Forbidden: access denied: deniedSet-Cookie: x=y"}` + "\n", "deniedSet-Cookie: x=y", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := testOptions(t)
+			opts.ProxyDenialDetails = tt.details
+			p, err := New(&config.Config{Options: opts})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.OnConfigChange(&config.Config{Options: opts})
+			state := p.state.Load()
+			state.authzClient = newDenyClient(tt.reason)
+			state.sessionStore = &mstore.Store{Session: &sessions.State{Expiry: jwt.NewNumericDate(time.Now().Add(10 * time.Minute))}}
+
+			r := httptest.NewRequest(http.MethodGet, "https://some.domain.example/?uri=https://some.domain.example", nil)
+			r.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			p.registerFwdAuthHandlers().ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("status code: got %d want %d", w.Code, http.StatusForbidden)
+			}
+			if diff := cmp.Diff(tt.wantBody, w.Body.String()); diff != "" {
+				t.Errorf("wrong body\n%s", diff)
+			}
+			if got := w.Header().Get(httputil.HeaderPomeriumDenialReason); got != tt.wantReason {
+				t.Errorf("reason header: got %q want %q", got, tt.wantReason)
+			}
+			if got := w.Header().Get(httputil.HeaderPomeriumDenialRoute); got != tt.wantRoute {
+				t.Errorf("route header: got %q want %q", got, tt.wantRoute)
+			}
+		})
+	}
+}
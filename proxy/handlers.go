@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/middleware"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
@@ -55,12 +56,17 @@ func (p *Proxy) RobotsTxt(w http.ResponseWriter, _ *http.Request) {
 func (p *Proxy) SignOut(w http.ResponseWriter, r *http.Request) {
 	state := p.state.Load()
 
+	options := p.currentOptions.Load()
 	redirectURL := &url.URL{Scheme: "https", Host: r.Host, Path: "/"}
-	if sru := p.currentOptions.Load().SignOutRedirectURL; sru != nil {
+	if sru := options.SignOutRedirectURL; sru != nil {
 		redirectURL = sru
 	}
 	if uri, err := urlutil.ParseAndValidateURL(r.FormValue(urlutil.QueryRedirectURI)); err == nil && uri.String() != "" {
-		redirectURL = uri
+		if options.IsAllowedRedirect(uri) {
+			redirectURL = uri
+		} else {
+			log.FromRequest(r).Warn().Str("redirect_uri", uri.String()).Msg("proxy: sign out redirect not allowed")
+		}
 	}
 
 	signoutURL := *state.authenticateSignoutURL
@@ -99,6 +105,9 @@ func (p *Proxy) Callback(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return httputil.NewError(http.StatusBadRequest, err)
 	}
+	if !p.currentOptions.Load().IsAllowedRedirect(redirectURL) {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("proxy: redirect not allowed: %s", redirectURL))
+	}
 
 	rawJWT, err := p.saveCallbackSession(w, r, encryptedSession)
 	if err != nil {
@@ -146,6 +155,9 @@ func (p *Proxy) ProgrammaticLogin(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return httputil.NewError(http.StatusBadRequest, err)
 	}
+	if !p.currentOptions.Load().IsAllowedRedirect(redirectURI) {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("proxy: redirect not allowed: %s", redirectURI))
+	}
 	signinURL := *state.authenticateSigninURL
 	callbackURI := urlutil.GetAbsoluteURL(r)
 	callbackURI.Path = dashboardPath + "/callback/"
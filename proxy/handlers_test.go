@@ -132,6 +132,8 @@ func TestProxy_SignOut(t *testing.T) {
 func TestProxy_Callback(t *testing.T) {
 	t.Parallel()
 	opts := testOptions(t)
+	allowlistOpts := testOptions(t)
+	allowlistOpts.RedirectAllowlist = []string{"portal.example"}
 	tests := []struct {
 		name    string
 		options *config.Options
@@ -178,6 +180,34 @@ func TestProxy_Callback(t *testing.T) {
 			http.StatusFound,
 			"",
 		},
+		{
+			"redirect in allowlist",
+			allowlistOpts,
+			http.MethodGet,
+			"http",
+			"portal.example",
+			"/",
+			nil,
+			map[string]string{urlutil.QueryCallbackURI: "ok", urlutil.QuerySessionEncrypted: goodEncryptionString},
+			&mock.Encoder{MarshalResponse: []byte("x")},
+			&mstore.Store{Session: &sessions.State{Expiry: jwt.NewNumericDate(time.Now().Add(10 * time.Minute))}},
+			http.StatusFound,
+			"",
+		},
+		{
+			"redirect not in allowlist",
+			allowlistOpts,
+			http.MethodGet,
+			"http",
+			"example.com",
+			"/",
+			nil,
+			map[string]string{urlutil.QueryCallbackURI: "ok", urlutil.QuerySessionEncrypted: goodEncryptionString},
+			&mock.Encoder{MarshalResponse: []byte("x")},
+			&mstore.Store{Session: &sessions.State{Expiry: jwt.NewNumericDate(time.Now().Add(10 * time.Minute))}},
+			http.StatusBadRequest,
+			"",
+		},
 		{
 			"bad decrypt",
 			opts,
@@ -291,6 +321,10 @@ func TestProxy_Callback(t *testing.T) {
 func TestProxy_ProgrammaticLogin(t *testing.T) {
 	t.Parallel()
 	opts := testOptions(t)
+	allowlistOpts := testOptions(t)
+	allowlistOpts.RedirectAllowlist = []string{"http://localhost"}
+	otherAllowlistOpts := testOptions(t)
+	otherAllowlistOpts.RedirectAllowlist = []string{"https://localhost"}
 	tests := []struct {
 		name    string
 		options *config.Options
@@ -310,6 +344,8 @@ func TestProxy_ProgrammaticLogin(t *testing.T) {
 		{"good body not checked", opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v1/login", nil, map[string]string{urlutil.QueryRedirectURI: "http://localhost"}, http.StatusOK, ""},
 		{"router miss, bad redirect_uri query", opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v1/login", nil, map[string]string{"bad_redirect_uri": "http://localhost"}, http.StatusNotFound, ""},
 		{"bad redirect_uri missing scheme", opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v1/login", nil, map[string]string{urlutil.QueryRedirectURI: "localhost"}, http.StatusBadRequest, "{\"Status\":400,\"Error\":\"Bad Request: localhost url does contain a valid scheme\"}\n"},
+		{"redirect_uri in allowlist", allowlistOpts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v1/login", nil, map[string]string{urlutil.QueryRedirectURI: "http://localhost"}, http.StatusOK, ""},
+		{"redirect_uri scheme not in allowlist", otherAllowlistOpts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v1/login", nil, map[string]string{urlutil.QueryRedirectURI: "http://localhost"}, http.StatusBadRequest, "{\"Status\":400,\"Error\":\"Bad Request: proxy: redirect not allowed: http://localhost\"}\n"},
 		{"bad http method", opts, http.MethodPost, "https", "corp.example.example", "/.pomerium/api/v1/login", nil, map[string]string{urlutil.QueryRedirectURI: "http://localhost"}, http.StatusMethodNotAllowed, ""},
 	}
 	for _, tt := range tests {
@@ -318,6 +354,7 @@ func TestProxy_ProgrammaticLogin(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			p.OnConfigChange(&config.Config{Options: tt.options})
 			redirectURI := &url.URL{Scheme: tt.scheme, Host: tt.host, Path: tt.path}
 			queryString := redirectURI.Query()
 			for k, v := range tt.qp {
@@ -1,8 +1,11 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -11,17 +14,34 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/pkg/grpc/authorize"
 )
 
 type authorizeResponse struct {
 	authorized bool
 	statusCode int32
+	headers    http.Header
+	denial     authorize.DenialDetails
 }
 
 func (p *Proxy) isAuthorized(w http.ResponseWriter, r *http.Request) (*authorizeResponse, error) {
+	ar, err := p.checkAuthorization(r)
+	if err != nil {
+		return nil, err
+	}
+	for k := range ar.headers {
+		w.Header().Set(k, ar.headers.Get(k))
+	}
+	return ar, nil
+}
+
+// checkAuthorization calls the authorize service's Check endpoint for the
+// given request.
+func (p *Proxy) checkAuthorization(r *http.Request) (*authorizeResponse, error) {
 	state := p.state.Load()
 
 	tm, err := ptypes.TimestampProto(time.Now())
@@ -45,34 +65,105 @@ func (p *Proxy) isAuthorized(w http.ResponseWriter, r *http.Request) (*authorize
 		httpAttrs.Path += "?" + r.URL.RawQuery
 	}
 
-	res, err := state.authzClient.Check(r.Context(), &envoy_service_auth_v2.CheckRequest{
-		Attributes: &envoy_service_auth_v2.AttributeContext{
-			Request: &envoy_service_auth_v2.AttributeContext_Request{
-				Time: tm,
-				Http: httpAttrs,
+	checkReq := &envoy_service_auth_v2.AttributeContext_Request{
+		Time: tm,
+		Http: httpAttrs,
+	}
+	var s sessions.State
+	if jwt, err := sessions.FromContext(r.Context()); err == nil {
+		_ = state.encoder.Unmarshal([]byte(jwt), &s)
+	}
+	requestURL := getRequestURL(r)
+	policy := p.getMatchingPolicy(requestURL)
+	authzClient := state.getAuthorizeClient(policy)
+	check := func(ctx context.Context) (interface{}, error) {
+		res, err := authzClient.Check(ctx, &envoy_service_auth_v2.CheckRequest{
+			Attributes: &envoy_service_auth_v2.AttributeContext{
+				Request: checkReq,
 			},
-		},
-	})
+		})
+		if err != nil {
+			return nil, err
+		}
+		if state.authzSigning {
+			if err := authorize.VerifyCheckResponse(state.sharedKey, checkReq, res); err != nil {
+				log.FromRequest(r).Error().Err(err).Msg("proxy: rejecting authorize response")
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	var v interface{}
+	if state.dedupeAuthzChecks && s.ID != "" {
+		// identical concurrent checks share a single call to authorize. The
+		// call must not be canceled if the request which started it is, as
+		// the others still wait for its result.
+		v, err, _ = p.authzChecks.Do(getAuthorizeCheckKey(s.ID, r.Method, requestURL), func() (interface{}, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), authorizeCheckTimeout)
+			defer cancel()
+			return check(ctx)
+		})
+	} else {
+		v, err = check(r.Context())
+	}
 	if err != nil {
 		return nil, httputil.NewError(http.StatusInternalServerError, err)
 	}
+	res := v.(*envoy_service_auth_v2.CheckResponse)
 
-	ar := &authorizeResponse{}
+	ar := &authorizeResponse{headers: make(http.Header)}
 	switch res.HttpResponse.(type) {
 	case *envoy_service_auth_v2.CheckResponse_OkResponse:
 		for _, hdr := range res.GetOkResponse().GetHeaders() {
-			w.Header().Set(hdr.GetHeader().GetKey(), hdr.GetHeader().GetValue())
+			ar.headers.Set(hdr.GetHeader().GetKey(), hdr.GetHeader().GetValue())
 		}
 		ar.authorized = true
 		ar.statusCode = res.GetStatus().Code
 	case *envoy_service_auth_v2.CheckResponse_DeniedResponse:
 		ar.statusCode = int32(res.GetDeniedResponse().GetStatus().Code)
+		ar.denial, _ = authorize.GetDenialDetails(res)
 	default:
 		ar.statusCode = http.StatusInternalServerError
 	}
+
 	return ar, nil
 }
 
+// denialError returns the error for a request the authorize service denied.
+// The configured details of the denial are added to the error's message and
+// to the response's headers.
+func (state *proxyState) denialError(w http.ResponseWriter, ar *authorizeResponse) error {
+	msg := "access denied"
+	denial := ar.denial.Sanitize()
+	for _, detail := range state.denialDetails {
+		switch detail {
+		case config.DenialDetailReason:
+			if reason := denial.Reason; reason != "" {
+				w.Header().Set(httputil.HeaderPomeriumDenialReason, reason)
+				msg += ": " + reason
+			}
+		case config.DenialDetailRoute:
+			if route := denial.Route; route != "" {
+				w.Header().Set(httputil.HeaderPomeriumDenialRoute, route)
+				msg += " (route " + route + ")"
+			}
+		}
+	}
+	return errors.New(msg)
+}
+
+// authorizeCheckTimeout bounds deduplicated authorize checks, which aren't
+// tied to the context of any single request.
+const authorizeCheckTimeout = 10 * time.Second
+
+// getAuthorizeCheckKey returns the key under which concurrent authorize
+// checks are deduplicated. Only requests for the same session, method and
+// full url, including the query string, share a check.
+func getAuthorizeCheckKey(sessionID, method string, requestURL *url.URL) string {
+	return fmt.Sprintf("%s\x00%s\x00%s", sessionID, method, requestURL.String())
+}
+
 // jwtClaimMiddleware logs and propagates JWT claim information via request headers
 //
 // if returnJWTInfo is set to true, it will also return JWT claim information in the response
@@ -89,7 +180,7 @@ func (p *Proxy) jwtClaimMiddleware(returnJWTInfo bool) mux.MiddlewareFunc {
 				return nil // best effort decoding
 			}
 
-			formattedJWTClaims, err := p.getFormatedJWTClaims([]byte(jwt))
+			formattedJWTClaims, err := p.getFormatedJWTClaims([]byte(jwt), state.jwtClaimHeadersFormat)
 			if err != nil {
 				log.Error().Err(err).Msg("proxy: failed to format jwt claims")
 				return nil // best effort formatting
@@ -100,19 +191,22 @@ func (p *Proxy) jwtClaimMiddleware(returnJWTInfo bool) mux.MiddlewareFunc {
 			for _, claimName := range []string{"groups", "email", "user"} {
 
 				l.UpdateContext(func(c zerolog.Context) zerolog.Context {
-					return c.Str(claimName, fmt.Sprintf("%v", formattedJWTClaims[claimName]))
+					return c.Str(claimName, strings.Join(formattedJWTClaims[claimName], ","))
 				})
 
 			}
 
 			// set headers for any claims specified by config
 			for _, claimName := range state.jwtClaimHeaders {
-				if _, ok := formattedJWTClaims[claimName]; ok {
+				if values, ok := formattedJWTClaims[claimName]; ok {
 
 					headerName := fmt.Sprintf("x-pomerium-claim-%s", claimName)
-					r.Header.Set(headerName, formattedJWTClaims[claimName])
-					if returnJWTInfo {
-						w.Header().Add(headerName, formattedJWTClaims[claimName])
+					r.Header.Del(headerName)
+					for _, value := range values {
+						r.Header.Add(headerName, value)
+						if returnJWTInfo {
+							w.Header().Add(headerName, value)
+						}
 					}
 				}
 			}
@@ -122,11 +216,12 @@ func (p *Proxy) jwtClaimMiddleware(returnJWTInfo bool) mux.MiddlewareFunc {
 	}
 }
 
-// getFormatJWTClaims reformats jwtClaims into something resembling map[string]string
-func (p *Proxy) getFormatedJWTClaims(jwt []byte) (map[string]string, error) {
+// getFormatJWTClaims reformats jwtClaims into header values, formatting claims
+// with multiple values according to format.
+func (p *Proxy) getFormatedJWTClaims(jwt []byte, format config.ClaimHeaderFormat) (map[string][]string, error) {
 	state := p.state.Load()
 
-	formattedJWTClaims := make(map[string]string)
+	formattedJWTClaims := make(map[string][]string)
 
 	var jwtClaims map[string]interface{}
 	if err := state.encoder.Unmarshal(jwt, &jwtClaims); err != nil {
@@ -134,18 +229,11 @@ func (p *Proxy) getFormatedJWTClaims(jwt []byte) (map[string]string, error) {
 	}
 
 	for claim, value := range jwtClaims {
-		var formattedClaim string
 		if cv, ok := value.([]interface{}); ok {
-			elements := make([]string, len(cv))
-
-			for i, v := range cv {
-				elements[i] = fmt.Sprintf("%v", v)
-			}
-			formattedClaim = strings.Join(elements, ",")
+			formattedJWTClaims[claim] = format.FormatValues(cv)
 		} else {
-			formattedClaim = fmt.Sprintf("%v", value)
+			formattedJWTClaims[claim] = []string{fmt.Sprintf("%v", value)}
 		}
-		formattedJWTClaims[claim] = formattedClaim
 	}
 
 	return formattedJWTClaims, nil
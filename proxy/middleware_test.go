@@ -1,15 +1,28 @@
 package proxy
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"gopkg.in/square/go-jose.v2/jwt"
 
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/pkg/grpc/authorize"
 )
 
 func Test_jwtClaimMiddleware(t *testing.T) {
@@ -53,3 +66,334 @@ func Test_jwtClaimMiddleware(t *testing.T) {
 	})
 
 }
+
+type signingCheckClient struct {
+	key string
+}
+
+func (c *signingCheckClient) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, opts ...grpc.CallOption) (*envoy_service_auth_v2.CheckResponse, error) {
+	res := &envoy_service_auth_v2.CheckResponse{
+		Status:       &status.Status{Code: int32(codes.OK), Message: "OK"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{},
+	}
+	if c.key != "" {
+		authorize.SignCheckResponse(c.key, in.GetAttributes().GetRequest(), res)
+	}
+	return res, nil
+}
+
+func TestProxy_checkAuthorization_signature(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions(t)
+	tests := []struct {
+		name       string
+		signing    bool
+		key        string
+		wantErr    bool
+		authorized bool
+	}{
+		{"signed", true, opts.SharedKey, false, true},
+		{"unsigned", true, "", true, false},
+		{"signed with the wrong key", true, "zdjp1u0dBvH7GKQlNmdkFSK9Ms6k2oiSi6ZWswvc9Qo=", true, false},
+		{"unsigned without signing", false, "", false, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := New(&config.Config{Options: opts})
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := p.state.Load()
+			state.authzSigning = tt.signing
+			state.authzClient = &signingCheckClient{key: tt.key}
+
+			ar, err := p.checkAuthorization(httptest.NewRequest(http.MethodGet, "https://corp.example.example/", nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkAuthorization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := ar != nil && ar.authorized; got != tt.authorized {
+				t.Errorf("authorized = %v, want %v", got, tt.authorized)
+			}
+		})
+	}
+}
+
+type statusCheckClient struct {
+	allow bool
+}
+
+func (c statusCheckClient) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, opts ...grpc.CallOption) (*envoy_service_auth_v2.CheckResponse, error) {
+	if c.allow {
+		return &envoy_service_auth_v2.CheckResponse{
+			Status:       &status.Status{Code: int32(codes.OK), Message: "OK"},
+			HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{},
+		}, nil
+	}
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Access Denied"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status: &envoy_type.HttpStatus{Code: envoy_type.StatusCode_Forbidden},
+			},
+		},
+	}, nil
+}
+
+type blockingCheckClient struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (c *blockingCheckClient) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, opts ...grpc.CallOption) (*envoy_service_auth_v2.CheckResponse, error) {
+	atomic.AddInt32(&c.calls, 1)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return statusCheckClient{allow: true}.Check(ctx, in, opts...)
+}
+
+func TestProxy_checkAuthorization_dedupe(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions(t)
+	opts.ProxyDeduplicateAuthorizeChecks = true
+	p, err := New(&config.Config{Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.currentOptions.Store(opts)
+	client := &blockingCheckClient{release: make(chan struct{})}
+	p.state.Load().authzClient = client
+
+	rawJWT, err := p.state.Load().encoder.Marshal(&sessions.State{ID: "session-1", Subject: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]*authorizeResponse, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "https://corp.example.example/", nil)
+			r = r.WithContext(sessions.NewContext(r.Context(), string(rawJWT), nil))
+			results[i], errs[i] = p.checkAuthorization(r)
+		}(i)
+	}
+	// give every check time to join the one in flight
+	time.Sleep(100 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&client.calls); calls != 1 {
+		t.Errorf("got %d authorize calls, want 1", calls)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if !results[i].authorized {
+			t.Errorf("check %d: not authorized", i)
+		}
+	}
+}
+
+func TestProxy_checkAuthorization_dedupeKey(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions(t)
+	opts.ProxyDeduplicateAuthorizeChecks = true
+	p, err := New(&config.Config{Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.currentOptions.Store(opts)
+	client := &blockingCheckClient{release: make(chan struct{})}
+	p.state.Load().authzClient = client
+
+	newRequest := func(ctx context.Context, sessionID, target string) *http.Request {
+		rawJWT, err := p.state.Load().encoder.Marshal(&sessions.State{ID: sessionID, Subject: "user-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		return r.WithContext(sessions.NewContext(r.Context(), string(rawJWT), nil))
+	}
+
+	// the first request gives up before authorize responds, which must not
+	// fail the requests sharing its check
+	canceled, cancel := context.WithCancel(context.Background())
+	reqs := []*http.Request{
+		newRequest(canceled, "session-1", "https://corp.example.example/a"),
+		newRequest(context.Background(), "session-1", "https://corp.example.example/a"),
+		newRequest(context.Background(), "session-1", "https://corp.example.example/a?x=1"),
+		newRequest(context.Background(), "session-1", "https://corp.example.example/b"),
+		newRequest(context.Background(), "session-2", "https://corp.example.example/a"),
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(reqs))
+	for i, r := range reqs {
+		wg.Add(1)
+		go func(i int, r *http.Request) {
+			defer wg.Done()
+			_, errs[i] = p.checkAuthorization(r)
+		}(i, r)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&client.calls); calls != 4 {
+		t.Errorf("got %d authorize calls, want 4", calls)
+	}
+	for i := range reqs {
+		if errs[i] != nil {
+			t.Errorf("check %d: %v", i, errs[i])
+		}
+	}
+}
+
+type recordingCheckClient struct {
+	hosts []string
+}
+
+func (c *recordingCheckClient) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, opts ...grpc.CallOption) (*envoy_service_auth_v2.CheckResponse, error) {
+	c.hosts = append(c.hosts, in.GetAttributes().GetRequest().GetHttp().GetHost())
+	return &envoy_service_auth_v2.CheckResponse{
+		Status:       &status.Status{Code: int32(codes.OK), Message: "OK"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{},
+	}, nil
+}
+
+func TestProxy_checkAuthorization_routeAuthorizeURL(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions(t)
+	opts.Policies = append(opts.Policies, config.Policy{
+		From:               "https://tenant.example.example",
+		To:                 "https://tenant.example",
+		AuthorizeURLString: "https://authorize.tenant.example",
+	})
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(&config.Config{Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.currentOptions.Store(opts)
+	state := p.state.Load()
+	if _, ok := state.routeAuthzClients["https://authorize.tenant.example"]; !ok {
+		t.Fatalf("no authorize client for the tenant route, got %v", state.routeAuthzClients)
+	}
+	defaultClient := &recordingCheckClient{}
+	tenantClient := &recordingCheckClient{}
+	state.authzClient = defaultClient
+	state.routeAuthzClients["https://authorize.tenant.example"] = tenantClient
+
+	for _, u := range []string{"https://corp.example.example/", "https://tenant.example.example/", "https://unknown.example.example/"} {
+		if _, err := p.checkAuthorization(httptest.NewRequest(http.MethodGet, u, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"corp.example.example", "unknown.example.example"}; !reflect.DeepEqual(defaultClient.hosts, want) {
+		t.Errorf("default authorize checked %v, want %v", defaultClient.hosts, want)
+	}
+	if want := []string{"tenant.example.example"}; !reflect.DeepEqual(tenantClient.hosts, want) {
+		t.Errorf("tenant authorize checked %v, want %v", tenantClient.hosts, want)
+	}
+}
+
+func Test_newAuthorizeClientConn(t *testing.T) {
+	opts := testOptions(t)
+	cfg := &config.Config{Options: opts}
+
+	defaultConn, err := newAuthorizeClientConn(cfg, opts.GetAuthorizeURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantConn, err := newAuthorizeClientConn(cfg, &url.URL{Scheme: "https", Host: "authorize.tenant.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherTenantConn, err := newAuthorizeClientConn(cfg, &url.URL{Scheme: "https", Host: "authorize.other-tenant.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultConn == tenantConn || tenantConn == otherTenantConn {
+		t.Fatal("expected a separate connection for each authorize service")
+	}
+
+	// the route overrides mustn't have replaced, and closed, the others
+	for _, conn := range []*grpc.ClientConn{defaultConn, tenantConn} {
+		if conn.GetState() == connectivity.Shutdown {
+			t.Errorf("connection to %s was closed", conn.Target())
+		}
+	}
+	if conn, err := newAuthorizeClientConn(cfg, opts.GetAuthorizeURL()); err != nil {
+		t.Fatal(err)
+	} else if conn != defaultConn {
+		t.Error("expected the default connection to be reused")
+	}
+}
+
+func Test_jwtClaimMiddleware_formats(t *testing.T) {
+	sharedKey := "80ldlrU2d7w+wVpKNfevk6fmb8otEx6CqOfshj2LwhQ="
+	encoder, _ := jws.NewHS256Signer([]byte(sharedKey), "https://authenticate.pomerium.example")
+	rawJWT, err := encoder.Marshal(map[string]interface{}{
+		"email":  "user@example.com",
+		"groups": []string{"admin", "dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format     config.ClaimHeaderFormat
+		wantGroups []string
+	}{
+		{"", []string{"admin,dev"}},
+		{config.ClaimHeaderFormatCSV, []string{"admin,dev"}},
+		{config.ClaimHeaderFormatJSON, []string{`["admin","dev"]`}},
+		{config.ClaimHeaderFormatRepeated, []string{"admin", "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			p := Proxy{
+				state: newAtomicProxyState(&proxyState{
+					encoder:               encoder,
+					jwtClaimHeaders:       []string{"email", "groups"},
+					jwtClaimHeadersFormat: tt.format,
+				}),
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("x-pomerium-claim-groups", "spoofed")
+			r = r.WithContext(sessions.NewContext(r.Context(), string(rawJWT), nil))
+			w := httptest.NewRecorder()
+			p.jwtClaimMiddleware(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+			if got := r.Header.Values("x-pomerium-claim-groups"); !reflect.DeepEqual(got, tt.wantGroups) {
+				t.Errorf("request groups header = %q, want %q", got, tt.wantGroups)
+			}
+			if got := w.Header().Values("x-pomerium-claim-groups"); !reflect.DeepEqual(got, tt.wantGroups) {
+				t.Errorf("response groups header = %q, want %q", got, tt.wantGroups)
+			}
+			if got := r.Header.Values("x-pomerium-claim-email"); !reflect.DeepEqual(got, []string{"user@example.com"}) {
+				t.Errorf("request email header = %q", got)
+			}
+		})
+	}
+}
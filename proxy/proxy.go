@@ -5,12 +5,17 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/frontend"
@@ -27,11 +32,20 @@ const (
 	signinURL     = "/.pomerium/sign_in"
 	signoutURL    = "/.pomerium/sign_out"
 	refreshURL    = "/.pomerium/refresh"
+
+	// notReadyRetryAfter is the delay clients are asked to wait before
+	// retrying a request that arrived before the proxy was ready.
+	notReadyRetryAfter = 5 * time.Second
 )
 
+var errNotReady = errors.New("proxy: not ready")
+
 // ValidateOptions checks that proper configuration settings are set to create
 // a proper Proxy instance
 func ValidateOptions(o *config.Options) error {
+	if o.SharedKey == "" {
+		return errors.New("proxy: 'SHARED_SECRET' is required")
+	}
 	if _, err := cryptutil.NewAEADCipherFromBase64(o.SharedKey); err != nil {
 		return fmt.Errorf("proxy: invalid 'SHARED_SECRET': %w", err)
 	}
@@ -56,14 +70,20 @@ type Proxy struct {
 	state          *atomicProxyState
 	currentOptions *config.AtomicOptions
 	currentRouter  atomic.Value
+	authzChecks    singleflight.Group
 }
 
 // New takes a Proxy service from options and a validation function.
-// Function returns an error if options fail to validate.
+// Function returns an error if options fail to validate. If the proxy's state
+// otherwise fails to load, it is created without one, and replies to requests
+// with a 503 until a later configuration change loads successfully.
 func New(cfg *config.Config) (*Proxy, error) {
+	if err := ValidateOptions(cfg.Options); err != nil {
+		return nil, err
+	}
 	state, err := newProxyStateFromConfig(cfg)
 	if err != nil {
-		return nil, err
+		log.Error().Err(err).Msg("proxy: failed to load proxy state from configuration settings")
 	}
 
 	p := &Proxy{
@@ -119,6 +139,46 @@ func (p *Proxy) setHandlers(opts *config.Options) {
 	p.currentRouter.Store(r)
 }
 
+// getRequestURL returns the absolute url of the request, without modifying
+// the request.
+func getRequestURL(r *http.Request) *url.URL {
+	u := *r.URL
+	u.Host = r.Host
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	return &u
+}
+
+// getMatchingPolicy returns the first policy matching the given url, if any.
+func (p *Proxy) getMatchingPolicy(requestURL *url.URL) *config.Policy {
+	options := p.currentOptions.Load()
+
+	for _, policy := range options.Policies {
+		if policy.Matches(requestURL) {
+			return &policy
+		}
+	}
+
+	return nil
+}
+
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	p.currentRouter.Load().(*mux.Router).ServeHTTP(w, r)
+	if p.currentOptions.Load().JSONErrors {
+		r = r.WithContext(httputil.WithJSONErrors(r.Context()))
+	}
+	p.requireState(p.currentRouter.Load().(*mux.Router)).ServeHTTP(w, r)
+}
+
+// requireState wraps next, replying with a 503 and a Retry-After header until
+// the proxy's state has been successfully loaded from its configuration.
+func (p *Proxy) requireState(next http.Handler) http.Handler {
+	return httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if p.state.Load() == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(notReadyRetryAfter.Seconds())))
+			return httputil.NewError(http.StatusServiceUnavailable, errNotReady)
+		}
+		next.ServeHTTP(w, r)
+		return nil
+	})
 }
@@ -52,6 +52,10 @@ func TestOptions_Validate(t *testing.T) {
 	badSharedKey.SharedKey = ""
 	sharedKeyBadBas64 := testOptions(t)
 	sharedKeyBadBas64.SharedKey = "%(*@389"
+	shortSharedKey := testOptions(t)
+	shortSharedKey.SharedKey = "gN3xnvfsAwfCXxnJorGLKUG4l2wC8sS8nfLMhcStPg=="
+	longSharedKey := testOptions(t)
+	longSharedKey.SharedKey = "gN3xnvfsAwfCXxnJorGLKUG4l2wC8sS8nfLMhcStPgGjKy3bgPw="
 	missingPolicy := testOptions(t)
 	missingPolicy.Policies = []config.Policy{}
 
@@ -71,6 +75,8 @@ func TestOptions_Validate(t *testing.T) {
 		{"short cookie secret", shortCookieLength, true},
 		{"no shared secret", badSharedKey, true},
 		{"shared secret bad base64", sharedKeyBadBas64, true},
+		{"short shared secret", shortSharedKey, true},
+		{"long shared secret", longSharedKey, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -115,6 +121,34 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_sharedKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		sharedKey string
+		wantErr   string
+	}{
+		{"empty", "", "proxy: 'SHARED_SECRET' is required"},
+		{"wrong length", "gN3xnvfsAwfCXxnJorGLKUG4l2wC8sS8nfLMhcStPg==", "proxy: invalid 'SHARED_SECRET': cryptutil: got 31 bytes but want 32"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := testOptions(t)
+			opts.SharedKey = tt.sharedKey
+			p, err := New(&config.Config{Options: opts})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("New() error = %v, want %q", err, tt.wantErr)
+			}
+			if p != nil {
+				t.Errorf("New() = %v, want nil", p)
+			}
+		})
+	}
+}
+
 func Test_UpdateOptions(t *testing.T) {
 	t.Parallel()
 
@@ -212,3 +246,25 @@ func Test_UpdateOptions(t *testing.T) {
 	var p *Proxy
 	p.OnConfigChange(&config.Config{})
 }
+
+func TestProxy_ServeHTTP_notReady(t *testing.T) {
+	t.Parallel()
+
+	// a bad certificate authority fails to load the state, but not New
+	opts := testOptions(t)
+	opts.GRPCInsecure = false
+	opts.CA = "bm90IGEgY2VydGlmaWNhdGU="
+	p, err := New(&config.Config{Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://corp.example.example/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want %q", got, "5")
+	}
+}
@@ -3,11 +3,13 @@ package proxy
 import (
 	"crypto/cipher"
 	"encoding/base64"
+	"fmt"
 	"net/url"
 	"sync/atomic"
 	"time"
 
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	googlegrpc "google.golang.org/grpc"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding"
@@ -33,13 +35,21 @@ type proxyState struct {
 	authenticateSignoutURL   *url.URL
 	authenticateRefreshURL   *url.URL
 
-	encoder         encoding.MarshalUnmarshaler
-	cookieSecret    []byte
-	refreshCooldown time.Duration
-	sessionStore    sessions.SessionStore
-	sessionLoaders  []sessions.SessionLoader
-	jwtClaimHeaders []string
-	authzClient     envoy_service_auth_v2.AuthorizationClient
+	encoder               encoding.MarshalUnmarshaler
+	cookieSecret          []byte
+	refreshCooldown       time.Duration
+	sessionStore          sessions.SessionStore
+	sessionLoaders        []sessions.SessionLoader
+	jwtClaimHeaders       []string
+	jwtClaimHeadersFormat config.ClaimHeaderFormat
+	authzClient           envoy_service_auth_v2.AuthorizationClient
+	authzSigning          bool
+	dedupeAuthzChecks     bool
+	denialDetails         []config.DenialDetail
+
+	// routeAuthzClients are the clients for routes that override the
+	// authorize service, keyed by the authorize service url
+	routeAuthzClients map[string]envoy_service_auth_v2.AuthorizationClient
 }
 
 func newProxyStateFromConfig(cfg *config.Config) (*proxyState, error) {
@@ -50,7 +60,10 @@ func newProxyStateFromConfig(cfg *config.Config) (*proxyState, error) {
 
 	state := new(proxyState)
 	state.sharedKey = cfg.Options.SharedKey
-	state.sharedCipher, _ = cryptutil.NewAEADCipherFromBase64(cfg.Options.SharedKey)
+	state.sharedCipher, err = cryptutil.NewAEADCipherFromBase64(cfg.Options.SharedKey)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid 'SHARED_SECRET': %w", err)
+	}
 	state.cookieSecret, _ = base64.StdEncoding.DecodeString(cfg.Options.CookieSecret)
 
 	// used to load and verify JWT tokens signed by the authenticate service
@@ -61,6 +74,10 @@ func newProxyStateFromConfig(cfg *config.Config) (*proxyState, error) {
 
 	state.refreshCooldown = cfg.Options.RefreshCooldown
 	state.jwtClaimHeaders = cfg.Options.JWTClaimsHeaders
+	state.jwtClaimHeadersFormat = cfg.Options.JWTClaimsHeadersFormat
+	state.authzSigning = cfg.Options.AuthorizeResponseSigning
+	state.dedupeAuthzChecks = cfg.Options.ProxyDeduplicateAuthorizeChecks
+	state.denialDetails = cfg.Options.ProxyDenialDetails
 
 	// errors checked in ValidateOptions
 	state.authorizeURL, _ = urlutil.DeepCopy(cfg.Options.AuthorizeURL)
@@ -77,6 +94,9 @@ func newProxyStateFromConfig(cfg *config.Config) (*proxyState, error) {
 			Secure:   cfg.Options.CookieSecure,
 			HTTPOnly: cfg.Options.CookieHTTPOnly,
 			Expire:   cfg.Options.CookieExpire,
+
+			SizeWarningThreshold: cfg.Options.SessionSizeWarningThreshold,
+			ReadOnly:             cfg.Options.ProxyCookieReadOnly,
 		}
 	}, state.encoder)
 	if err != nil {
@@ -85,24 +105,69 @@ func newProxyStateFromConfig(cfg *config.Config) (*proxyState, error) {
 	state.sessionLoaders = []sessions.SessionLoader{
 		state.sessionStore,
 		header.NewStore(state.encoder, httputil.AuthorizationTypePomerium),
-		queryparam.NewStore(state.encoder, "pomerium_session")}
+		queryparam.NewStore(state.encoder, "pomerium_session").SafeMethodsOnly(!cfg.Options.QueryParamSessionAllMethods)}
+
+	state.authzClient, err = newAuthorizeClient(cfg, state.authorizeURL)
+	if err != nil {
+		return nil, err
+	}
+	state.routeAuthzClients = make(map[string]envoy_service_auth_v2.AuthorizationClient)
+	for _, policy := range cfg.Options.Policies {
+		if policy.AuthorizeURL == nil {
+			continue
+		}
+		if _, ok := state.routeAuthzClients[policy.AuthorizeURL.String()]; ok {
+			continue
+		}
+		client, err := newAuthorizeClient(cfg, policy.AuthorizeURL)
+		if err != nil {
+			return nil, err
+		}
+		state.routeAuthzClients[policy.AuthorizeURL.String()] = client
+	}
+
+	return state, nil
+}
 
-	authzConn, err := grpc.GetGRPCClientConn("authorize", &grpc.Options{
-		Addr:                    state.authorizeURL,
+func newAuthorizeClient(cfg *config.Config, authorizeURL *url.URL) (envoy_service_auth_v2.AuthorizationClient, error) {
+	authzConn, err := newAuthorizeClientConn(cfg, authorizeURL)
+	if err != nil {
+		return nil, err
+	}
+	return envoy_service_auth_v2.NewAuthorizationClient(authzConn), nil
+}
+
+// newAuthorizeClientConn returns the connection to the authorize service at
+// the given URL. Connections are cached by name and replaced when their
+// options change, so route overrides are named by their URL to keep them from
+// replacing the connection to the global authorize service, or each other.
+func newAuthorizeClientConn(cfg *config.Config, authorizeURL *url.URL) (*googlegrpc.ClientConn, error) {
+	name := "authorize"
+	if authorizeURL.String() != cfg.Options.GetAuthorizeURL().String() {
+		name = "authorize:" + authorizeURL.String()
+	}
+	return grpc.GetGRPCClientConn(name, &grpc.Options{
+		Addr:                    authorizeURL,
 		OverrideCertificateName: cfg.Options.OverrideCertificateName,
 		CA:                      cfg.Options.CA,
 		CAFile:                  cfg.Options.CAFile,
 		RequestTimeout:          cfg.Options.GRPCClientTimeout,
 		ClientDNSRoundRobin:     cfg.Options.GRPCClientDNSRoundRobin,
+		ClientDNSStaleTTL:       cfg.Options.GRPCClientDNSStaleTTL,
 		WithInsecure:            cfg.Options.GRPCInsecure,
 		ServiceName:             cfg.Options.Services,
 	})
-	if err != nil {
-		return nil, err
-	}
-	state.authzClient = envoy_service_auth_v2.NewAuthorizationClient(authzConn)
+}
 
-	return state, nil
+// getAuthorizeClient returns the client for the authorize service that
+// checks requests to the given route.
+func (state *proxyState) getAuthorizeClient(policy *config.Policy) envoy_service_auth_v2.AuthorizationClient {
+	if policy != nil && policy.AuthorizeURL != nil {
+		if client, ok := state.routeAuthzClients[policy.AuthorizeURL.String()]; ok {
+			return client
+		}
+	}
+	return state.authzClient
 }
 
 type atomicProxyState struct {
@@ -111,12 +176,16 @@ type atomicProxyState struct {
 
 func newAtomicProxyState(state *proxyState) *atomicProxyState {
 	aps := new(atomicProxyState)
-	aps.Store(state)
+	if state != nil {
+		aps.Store(state)
+	}
 	return aps
 }
 
+// Load returns the current state, or nil if no state has been stored yet.
 func (aps *atomicProxyState) Load() *proxyState {
-	return aps.value.Load().(*proxyState)
+	state, _ := aps.value.Load().(*proxyState)
+	return state
 }
 
 func (aps *atomicProxyState) Store(state *proxyState) {